@@ -0,0 +1,100 @@
+// Copyright (c) 2017 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package safehtml
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/safehtml/internal/safehtmlutil"
+)
+
+// CSPSources returns the distinct Content-Security-Policy source expressions
+// that match the origins of urls, in the order in which they first occur, so
+// that a directive such as script-src can be kept in sync with the resources
+// that a page loads. See https://www.w3.org/TR/CSP3/#source-lists.
+//
+// Absolute URLs with the http, https, ws or wss scheme contribute their
+// lowercase scheme, host and port, as in "https://example.com:8443". Default
+// ports are omitted, since they are implied by the scheme, and any userinfo,
+// path, query or fragment is ignored. Internationalized domain names are
+// converted to their ASCII form. Scheme-relative URLs such as
+// "//example.com/a.js" contribute a source without a scheme, such as
+// "example.com", which matches the scheme of the page. Relative URLs such as
+// "/a.js" contribute "'self'".
+//
+// It returns an error if a URL has another scheme, or if its host is not a
+// domain name or IPv4 address, since CSP source expressions cannot contain
+// IPv6 addresses.
+func CSPSources(urls ...TrustedResourceURL) ([]string, error) {
+	var sources []string
+	seen := make(map[string]bool)
+	for _, u := range urls {
+		source, err := cspSource(u.str)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[source] {
+			seen[source] = true
+			sources = append(sources, source)
+		}
+	}
+	return sources, nil
+}
+
+// cspSourceSchemes contains the schemes of absolute URLs accepted by CSPSources.
+var cspSourceSchemes = map[string]bool{"http": true, "https": true, "ws": true, "wss": true}
+
+// cspHostPattern matches the lowercase hosts allowed in CSP source expressions.
+// See https://www.w3.org/TR/CSP3/#grammardef-host-source.
+var cspHostPattern = regexp.MustCompile(`^[a-z0-9-]+(?:\.[a-z0-9-]+)*$`)
+
+// cspSource returns the CSP source expression matching the origin of url, as
+// described in CSPSources.
+func cspSource(url string) (string, error) {
+	scheme, authority, ok := urlAuthority(url)
+	if scheme != "" && !cspSourceSchemes[scheme] {
+		return "", fmt.Errorf("cannot derive a CSP source from URL %q with scheme %q", url, scheme)
+	}
+	if !ok {
+		return "'self'", nil
+	}
+	if i := strings.LastIndexByte(authority, '@'); i != -1 {
+		authority = authority[i+1:]
+	}
+	host, port := authority, ""
+	if i := strings.LastIndexByte(authority, ':'); i != -1 {
+		host, port = authority[:i], authority[i+1:]
+		if port == "" || strings.Trim(port, "0123456789") != "" {
+			return "", fmt.Errorf("cannot derive a CSP source from URL %q, since its port %q is not a number", url, port)
+		}
+		// Browsers ignore leading zeros in ports.
+		if port = strings.TrimLeft(port, "0"); port == "" {
+			port = "0"
+		}
+	}
+	if !isASCII(host) {
+		ascii, err := idnaProfile.ToASCII(host)
+		if err != nil {
+			return "", fmt.Errorf("cannot derive a CSP source from URL %q: %v", url, err)
+		}
+		host = ascii
+	}
+	host = strings.ToLower(host)
+	if !cspHostPattern.MatchString(host) {
+		return "", fmt.Errorf("cannot derive a CSP source from URL %q, since its host %q is not a domain name or IPv4 address", url, host)
+	}
+	source := host
+	if scheme != "" {
+		source = scheme + "://" + host
+	}
+	if port != "" && port != safehtmlutil.DefaultPort(scheme) {
+		source += ":" + port
+	}
+	return source, nil
+}
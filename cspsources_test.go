@@ -0,0 +1,96 @@
+// Copyright (c) 2017 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package safehtml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCSPSources(t *testing.T) {
+	for _, test := range [...]struct {
+		desc string
+		urls []string
+		want []string
+	}{
+		{
+			desc: "no URLs",
+		},
+		{
+			desc: "origins",
+			urls: []string{
+				"https://cdn.example.com/lib.js",
+				"https://static.example.com:8443/app.js?v=1",
+				"/js/main.js",
+				"https://CDN.example.com/other.js#frag",
+				"https://user@static.example.com:8443/",
+				"main.js",
+				"https://api.example.com:443/",
+				"http://legacy.example.com:80/a.js",
+				"wss://socket.example.com:0080",
+			},
+			want: []string{
+				"https://cdn.example.com",
+				"https://static.example.com:8443",
+				"'self'",
+				"https://api.example.com",
+				"http://legacy.example.com",
+				"wss://socket.example.com:80",
+			},
+		},
+		{
+			desc: "scheme-relative",
+			urls: []string{"//cdn.example.com/lib.js", `\\cdn.example.com\lib.js`, "//cdn.example.com:8080/lib.js", "//192.0.2.1/lib.js"},
+			want: []string{"cdn.example.com", "cdn.example.com:8080", "192.0.2.1"},
+		},
+		{
+			desc: "internationalized domain name",
+			urls: []string{"https://bücher.example/lib.js", "https://xn--bcher-kva.example/other.js"},
+			want: []string{"https://xn--bcher-kva.example"},
+		},
+	} {
+		urls := make([]TrustedResourceURL, len(test.urls))
+		for i, u := range test.urls {
+			urls[i] = TrustedResourceURL{u}
+		}
+		got, err := CSPSources(urls...)
+		if err != nil {
+			t.Errorf("%s: CSPSources(%q) failed: %v", test.desc, test.urls, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: CSPSources(%q) = %q, want %q", test.desc, test.urls, got, test.want)
+		}
+	}
+}
+
+func TestCSPSourcesError(t *testing.T) {
+	for _, test := range [...]struct {
+		url  string
+		want string
+	}{
+		{"about:blank", `with scheme "about"`},
+		{"data:text/javascript,alert(1)", `with scheme "data"`},
+		{"ftp://example.com/a.js", `with scheme "ftp"`},
+		{"https://[::1]/a.js", `is not a number`},
+		{"https://[::1]:8080/a.js", `is not a domain name or IPv4 address`},
+		{"https://example.com:/a.js", `is not a number`},
+		{"https://example.com:http/a.js", `is not a number`},
+		{"https://ex*mple.com/a.js", `is not a domain name or IPv4 address`},
+		{"https://?q", `is not a domain name or IPv4 address`},
+	} {
+		_, err := CSPSources(TrustedResourceURL{"/a.js"}, TrustedResourceURL{test.url})
+		if err == nil {
+			t.Errorf("CSPSources(%q) succeeded, want error", test.url)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("CSPSources(%q) returned error %q, want error containing %q", test.url, err, test.want)
+		}
+	}
+}
@@ -1,5 +1,8 @@
 module github.com/google/safehtml
 
-go 1.14
+go 1.16
 
-require golang.org/x/text v0.3.3
+require (
+	golang.org/x/net v0.0.0-20200822124328-c89045814202
+	golang.org/x/text v0.3.3
+)
@@ -36,6 +36,19 @@ type HTMLer interface {
 	HTML() HTML
 }
 
+// HTMLFromConstant constructs an HTML with its underlying markup set to the
+// given html, which must be an untyped string constant.
+//
+// No runtime validation or sanitization is performed on html; being under
+// application control, it is simply assumed to comply with the HTML contract.
+// html must therefore be reviewed with the same care as template source: it
+// must not contain script or markup whose behavior depends on untrusted data,
+// such as inline event handlers or script elements that read from the page.
+// For markup that contains dynamic values, use the template package instead.
+func HTMLFromConstant(html stringConstant) HTML {
+	return HTML{string(html)}
+}
+
 // HTMLEscaped returns an HTML whose value is text, with the characters [&<>"'] escaped.
 //
 // text is coerced to interchange valid, so the resulting HTML contains only
@@ -21,6 +21,16 @@ func TestHTMLEscaped(t *testing.T) {
 	}
 }
 
+func TestHTMLFromConstant(t *testing.T) {
+	const icon = `<svg viewBox="0 0 16 16"><path d="M0 0h16v16H0z"/></svg>`
+	if got := HTMLFromConstant(icon); got.String() != icon {
+		t.Errorf("HTMLFromConstant(%q) == %q, want %q", icon, got.String(), icon)
+	}
+	if got := HTMLConcat(HTMLFromConstant(`<b>`), HTMLEscaped("<i>"), HTMLFromConstant(`</b>`)); got.String() != `<b>&lt;i&gt;</b>` {
+		t.Errorf("HTMLConcat of constant and escaped HTML == %q, want %q", got.String(), `<b>&lt;i&gt;</b>`)
+	}
+}
+
 func TestHTMLConcat(t *testing.T) {
 	for _, test := range [...]struct {
 		in   []string
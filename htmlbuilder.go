@@ -0,0 +1,105 @@
+// Copyright (c) 2017 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package safehtml
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// An HTMLBuilder is used to efficiently build an HTML from a mix of HTML
+// values and text. HTML values are appended unchanged, while text is escaped
+// as by HTMLEscaped, so the result is equivalent to HTMLConcat of the HTML
+// values and the HTMLEscaped forms of the text. The zero value is ready to use.
+// Do not copy a non-zero HTMLBuilder.
+type HTMLBuilder struct {
+	b strings.Builder
+	// partial holds a prefix of a UTF-8 encoded rune at the end of the last
+	// call to Write, to be completed by the following call.
+	partial []byte
+}
+
+// WriteHTML appends h to the HTML being built.
+func (b *HTMLBuilder) WriteHTML(h HTML) {
+	b.flushPartial()
+	b.b.WriteString(h.String())
+}
+
+// WriteString appends s to the HTML being built after escaping it as by
+// HTMLEscaped. It returns the length of s and a nil error.
+func (b *HTMLBuilder) WriteString(s string) (int, error) {
+	b.flushPartial()
+	b.b.WriteString(escapeAndCoerceToInterchangeValid(s))
+	return len(s), nil
+}
+
+// Write appends p to the HTML being built after escaping it as by HTMLEscaped,
+// so that HTMLBuilder can be used as an io.Writer of text. It returns the
+// length of p and a nil error.
+//
+// A UTF-8 encoded rune may be split across consecutive calls to Write. An
+// incomplete encoded rune at the end of p is escaped once it is completed or
+// once any other method is called.
+func (b *HTMLBuilder) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(b.partial) > 0 {
+		p = append(b.partial, p...)
+		b.partial = nil
+	}
+	if i := incompleteRuneStart(p); i < len(p) {
+		b.partial = append([]byte(nil), p[i:]...)
+		p = p[:i]
+	}
+	b.b.WriteString(escapeAndCoerceToInterchangeValid(string(p)))
+	return n, nil
+}
+
+// HTML returns the HTML built so far.
+func (b *HTMLBuilder) HTML() HTML {
+	b.flushPartial()
+	return HTML{b.b.String()}
+}
+
+// Len returns the number of bytes in the HTML built so far, excluding any
+// incomplete rune written by Write.
+func (b *HTMLBuilder) Len() int {
+	return b.b.Len()
+}
+
+// Grow grows b's capacity, if necessary, to guarantee space for another n
+// bytes. It panics if n is negative.
+func (b *HTMLBuilder) Grow(n int) {
+	b.b.Grow(n)
+}
+
+// Reset resets b to be empty.
+func (b *HTMLBuilder) Reset() {
+	b.b.Reset()
+	b.partial = nil
+}
+
+// flushPartial escapes and appends any incomplete rune written by Write.
+func (b *HTMLBuilder) flushPartial() {
+	if len(b.partial) > 0 {
+		b.b.WriteString(escapeAndCoerceToInterchangeValid(string(b.partial)))
+		b.partial = nil
+	}
+}
+
+// incompleteRuneStart returns the index of the start of an incomplete but
+// possibly valid UTF-8 encoded rune at the end of p, or len(p) if there is none.
+func incompleteRuneStart(p []byte) int {
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax+1; i-- {
+		if utf8.RuneStart(p[i]) {
+			if !utf8.FullRune(p[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(p)
+}
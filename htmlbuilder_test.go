@@ -0,0 +1,105 @@
+// Copyright (c) 2017 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package safehtml
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestHTMLBuilder(t *testing.T) {
+	var b HTMLBuilder
+	if got := b.HTML().String(); got != "" {
+		t.Errorf("zero HTMLBuilder: got %q, want empty HTML", got)
+	}
+	b.WriteHTML(HTMLFromConstant(`<p class="greeting">`))
+	b.WriteString(`Hello, <script>alert("1")</script> & 'friends'`)
+	b.WriteHTML(HTMLFromConstant(`</p>`))
+	fmt.Fprintf(&b, " %d<%s>", 42, "b")
+	const want = `<p class="greeting">Hello, &lt;script&gt;alert(&#34;1&#34;)&lt;/script&gt; &amp; &#39;friends&#39;</p> 42&lt;b&gt;`
+	if got := b.HTML().String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if b.Len() != len(want) {
+		t.Errorf("Len() = %d, want %d", b.Len(), len(want))
+	}
+	concat := HTMLConcat(HTMLFromConstant(`<p class="greeting">`), HTMLEscaped(`Hello, <script>alert("1")</script> & 'friends'`),
+		HTMLFromConstant(`</p>`), HTMLEscaped(" 42<b>"))
+	if b.HTML() != concat {
+		t.Errorf("got %q, want the same result as HTMLConcat, %q", b.HTML(), concat)
+	}
+	b.Reset()
+	if got := b.HTML().String(); got != "" {
+		t.Errorf("after Reset: got %q, want empty HTML", got)
+	}
+}
+
+func TestHTMLBuilderCoercesToInterchangeValid(t *testing.T) {
+	var b HTMLBuilder
+	b.WriteString("a\x00b\xffc")
+	b.Write([]byte("\x01d"))
+	if got, want := b.HTML().String(), "a�b�c�d"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestHTMLBuilderWriteSplitRunes(t *testing.T) {
+	const text = "€ <naïve> 😀"
+	for chunk := 1; chunk <= len(text); chunk++ {
+		var b HTMLBuilder
+		r := strings.NewReader(text)
+		buf := make([]byte, chunk)
+		if _, err := io.CopyBuffer(onlyWriter{&b}, onlyReader{r}, buf); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := b.HTML(), HTMLEscaped(text); got != want {
+			t.Errorf("chunk size %d: got %q, want %q", chunk, got, want)
+		}
+	}
+
+	// Incomplete runes are escaped when any other method is called.
+	var b HTMLBuilder
+	b.Write([]byte("a\xe2\x82"))
+	b.WriteHTML(HTMLFromConstant("<br>"))
+	b.Write([]byte("\xac"))
+	if got, want := b.HTML().String(), HTMLEscaped("a\xe2\x82").String()+"<br>�"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// onlyWriter and onlyReader hide any io.ReaderFrom and io.WriterTo
+// implementations so that io.CopyBuffer uses the given buffer.
+type onlyWriter struct{ io.Writer }
+type onlyReader struct{ io.Reader }
+
+func BenchmarkHTMLBuilder(b *testing.B) {
+	const n = 100
+	li, endLI := HTMLFromConstant("<li>"), HTMLFromConstant("</li>")
+	b.Run("HTMLBuilder", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var hb HTMLBuilder
+			for j := 0; j < n; j++ {
+				hb.WriteHTML(li)
+				hb.WriteString("item <b>")
+				hb.WriteHTML(endLI)
+			}
+			hb.HTML()
+		}
+	})
+	b.Run("HTMLConcat", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var h HTML
+			for j := 0; j < n; j++ {
+				h = HTMLConcat(h, li, HTMLEscaped("item <b>"), endLI)
+			}
+		}
+	})
+}
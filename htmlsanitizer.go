@@ -0,0 +1,239 @@
+// Copyright (c) 2017 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package safehtml
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// A SanitizerPolicy specifies the elements and attributes kept by
+// HTMLSanitized. Element and attribute names must be in lower case.
+//
+// A policy cannot allow elements or attributes that would let the sanitized
+// HTML run scripts, load stylesheets or change how the rest of the document is
+// interpreted: script, style, iframe, object, embed, base, link, meta, form
+// controls that hold raw text such as textarea, and SVG and MathML content
+// are always removed along with their content, and event handler attributes
+// such as onclick, style, srcset and srcdoc are always removed.
+type SanitizerPolicy struct {
+	// Elements contains the names of the elements to keep. Other elements
+	// are removed, but their content is kept.
+	Elements map[string]bool
+	// Attributes contains the names of the attributes to keep on the kept
+	// elements. The values of URL attributes, such as href and src, are
+	// sanitized with URLSanitized.
+	Attributes map[string]bool
+}
+
+// DefaultSanitizerPolicy returns a new SanitizerPolicy that keeps elements and
+// attributes for formatted text with links, such as p, a, strong, em, ul, li
+// and blockquote, and href, title, lang and dir. It does not keep img elements.
+//
+// The returned policy may be modified, for example to also allow images:
+//
+//	p := safehtml.DefaultSanitizerPolicy()
+//	p.Elements["img"] = true
+//	p.Attributes["src"] = true
+//	p.Attributes["alt"] = true
+func DefaultSanitizerPolicy() *SanitizerPolicy {
+	p := &SanitizerPolicy{
+		Elements:   make(map[string]bool),
+		Attributes: make(map[string]bool),
+	}
+	for _, e := range []string{
+		"a", "abbr", "b", "blockquote", "br", "cite", "code", "del", "div",
+		"em", "h1", "h2", "h3", "h4", "h5", "h6", "hr", "i", "ins", "kbd",
+		"li", "ol", "p", "pre", "q", "s", "small", "span", "strong", "sub",
+		"sup", "u", "ul",
+	} {
+		p.Elements[e] = true
+	}
+	for _, a := range []string{"cite", "dir", "href", "lang", "title"} {
+		p.Attributes[a] = true
+	}
+	return p
+}
+
+// HTMLSanitized returns an HTML containing html, an untrusted HTML fragment,
+// with all elements and attributes not allowed by policy removed. If policy is
+// nil, DefaultSanitizerPolicy is used.
+//
+// html is parsed as the content of a body element, as a browser would parse
+// it, and the parsed elements are then serialized again, so the result is
+// well-formed even if html is not. Text and attribute values are escaped and
+// coerced to interchange valid as by HTMLEscaped. Comments and doctypes are
+// removed.
+//
+// For example, with the default policy,
+//
+//	<p onclick="evil()">Hi <a href="javascript:evil()">there</a><script>evil()</script>
+//
+// is sanitized to
+//
+//	<p>Hi <a href="about:invalid#zGoSafez">there</a></p>
+func HTMLSanitized(html string, policy *SanitizerPolicy) HTML {
+	if policy == nil {
+		policy = defaultSanitizerPolicy
+	}
+	return HTML{sanitizeHTML(html, policy)}
+}
+
+// defaultSanitizerPolicy is used by HTMLSanitized if no policy is given.
+var defaultSanitizerPolicy = DefaultSanitizerPolicy()
+
+// sanitizeHTML returns the HTML fragment src sanitized according to policy.
+func sanitizeHTML(src string, policy *SanitizerPolicy) string {
+	nodes, err := html.ParseFragment(strings.NewReader(src), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		// Reading from a strings.Reader does not fail.
+		return ""
+	}
+	var b strings.Builder
+	for _, n := range nodes {
+		writeSanitizedHTMLNode(&b, n, policy)
+	}
+	return b.String()
+}
+
+// writeSanitizedHTMLNode writes n and its descendants to b, removing elements
+// and attributes not allowed by policy.
+func writeSanitizedHTMLNode(b *strings.Builder, n *html.Node, policy *SanitizerPolicy) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(escapeAndCoerceToInterchangeValid(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		// Drop comments, doctypes and any other nodes.
+		return
+	}
+	if n.Namespace != "" || htmlSanitizerRemovedElements[n.Data] {
+		// Drop the element and everything it contains.
+		return
+	}
+	keep := policy.Elements[n.Data]
+	if keep {
+		b.WriteString("<" + n.Data)
+		for _, attr := range n.Attr {
+			if value, ok := sanitizeHTMLAttribute(attr, policy); ok {
+				b.WriteString(" " + attr.Key + `="`)
+				b.WriteString(escapeAndCoerceToInterchangeValid(value))
+				b.WriteString(`"`)
+			}
+		}
+		b.WriteString(">")
+		if htmlVoidElements[n.Data] {
+			return
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeSanitizedHTMLNode(b, c, policy)
+	}
+	if keep {
+		b.WriteString("</" + n.Data + ">")
+	}
+}
+
+// sanitizeHTMLAttribute returns the value with which attr should be written to
+// a sanitized HTML fragment, or false if attr should be dropped.
+func sanitizeHTMLAttribute(attr html.Attribute, policy *SanitizerPolicy) (string, bool) {
+	switch {
+	case attr.Namespace != "",
+		!policy.Attributes[attr.Key],
+		strings.HasPrefix(attr.Key, "on"),
+		htmlSanitizerRemovedAttributes[attr.Key]:
+		return "", false
+	case htmlURLAttributes[attr.Key]:
+		return URLSanitized(attr.Val).String(), true
+	}
+	return attr.Val, true
+}
+
+// htmlSanitizerRemovedElements contains the names of elements that
+// HTMLSanitized always removes along with their content, whatever the policy.
+// It includes elements that run scripts or embed other documents; that load
+// or contain stylesheets; that change the interpretation of other elements or
+// URLs in the document; and whose content is parsed as raw text, which would
+// not be escaped when serialized.
+var htmlSanitizerRemovedElements = map[string]bool{
+	"applet":    true,
+	"base":      true,
+	"basefont":  true,
+	"embed":     true,
+	"frame":     true,
+	"frameset":  true,
+	"iframe":    true,
+	"link":      true,
+	"math":      true,
+	"meta":      true,
+	"noembed":   true,
+	"noframes":  true,
+	"noscript":  true,
+	"object":    true,
+	"param":     true,
+	"plaintext": true,
+	"portal":    true,
+	"script":    true,
+	"select":    true,
+	"style":     true,
+	"svg":       true,
+	"template":  true,
+	"textarea":  true,
+	"title":     true,
+	"xmp":       true,
+}
+
+// htmlSanitizerRemovedAttributes contains the names of attributes that
+// HTMLSanitized always removes, whatever the policy, in addition to event
+// handler attributes. It includes attributes that contain stylesheets,
+// documents or lists of URLs that are not sanitized, and attributes that
+// submit forms to other URLs.
+var htmlSanitizerRemovedAttributes = map[string]bool{
+	"action":     true,
+	"formaction": true,
+	"ping":       true,
+	"srcdoc":     true,
+	"srcset":     true,
+	"style":      true,
+}
+
+// htmlURLAttributes contains the names of attributes whose values HTMLSanitized
+// sanitizes with URLSanitized.
+var htmlURLAttributes = map[string]bool{
+	"background": true,
+	"cite":       true,
+	"href":       true,
+	"longdesc":   true,
+	"poster":     true,
+	"src":        true,
+}
+
+// htmlVoidElements contains the names of elements that have no content and
+// are written without an end tag.
+//
+// See https://html.spec.whatwg.org/multipage/syntax.html#void-elements.
+var htmlVoidElements = map[string]bool{
+	"area":   true,
+	"br":     true,
+	"col":    true,
+	"embed":  true,
+	"hr":     true,
+	"img":    true,
+	"input":  true,
+	"link":   true,
+	"meta":   true,
+	"source": true,
+	"track":  true,
+	"wbr":    true,
+}
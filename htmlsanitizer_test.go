@@ -0,0 +1,187 @@
+// Copyright (c) 2017 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package safehtml
+
+import (
+	"testing"
+)
+
+func TestHTMLSanitized(t *testing.T) {
+	for _, test := range [...]struct {
+		desc, input, want string
+	}{
+		{
+			"allowed markup",
+			`<p>Hello, <strong>world</strong>! <a href="https://example.com/?a=1&amp;b=2" title="Example">link</a></p>`,
+			`<p>Hello, <strong>world</strong>! <a href="https://example.com/?a=1&amp;b=2" title="Example">link</a></p>`,
+		},
+		{
+			"script element",
+			`<p>a</p><script>alert(1)</script><p>b</p>`,
+			`<p>a</p><p>b</p>`,
+		},
+		{
+			"script in disallowed element",
+			`<article><script>alert(1)</script>text</article>`,
+			`text`,
+		},
+		{
+			"style and iframe",
+			`<style>body{display:none}</style><iframe src="https://evil.com/"></iframe>ok`,
+			`ok`,
+		},
+		{
+			"event handlers",
+			`<p onclick="alert(1)" ONMOUSEOVER="alert(2)">x</p><a href="/" onfocus="alert(3)">y</a>`,
+			`<p>x</p><a href="/">y</a>`,
+		},
+		{
+			"javascript href",
+			`<a href="javascript:alert(1)">x</a>`,
+			`<a href="about:invalid#zGoSafez">x</a>`,
+		},
+		{
+			"javascript href with entities and whitespace",
+			`<a href=" java&#115;cript&colon;alert(1)">x</a><a href="JaVaScRiPt:alert(1)">y</a>`,
+			`<a href="about:invalid#zGoSafez">x</a><a href="about:invalid#zGoSafez">y</a>`,
+		},
+		{
+			"data href",
+			`<a href="data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==">x</a>`,
+			`<a href="about:invalid#zGoSafez">x</a>`,
+		},
+		{
+			"disallowed element keeps content",
+			`<font color="red"><b>bold</b></font>`,
+			`<b>bold</b>`,
+		},
+		{
+			"disallowed attributes",
+			`<p style="color:red" class="x" id="y">x</p>`,
+			`<p>x</p>`,
+		},
+		{
+			"img not allowed by default",
+			`<img src="x" onerror="alert(1)">text`,
+			`text`,
+		},
+		{
+			"void elements",
+			`a<br>b<hr/>c`,
+			`a<br>b<hr>c`,
+		},
+		{
+			"comments and doctype",
+			`<!DOCTYPE html><!-- <script>alert(1)</script> -->text<!--x-->`,
+			`text`,
+		},
+		{
+			"text is escaped",
+			`1 &lt; 2 &amp;&amp; "a" > 'b'`,
+			`1 &lt; 2 &amp;&amp; &#34;a&#34; &gt; &#39;b&#39;`,
+		},
+		{
+			"attribute values are escaped",
+			`<a title='"><script>alert(1)</script>'>x</a>`,
+			`<a title="&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;">x</a>`,
+		},
+		{
+			"unclosed elements",
+			`<p><em>a<p>b`,
+			`<p><em>a</em></p><p><em>b</em></p>`,
+		},
+		{
+			"stray end tags",
+			`</p></div>a</script>`,
+			`<p></p>a`,
+		},
+		{
+			"svg content",
+			`<svg><script>alert(1)</script></svg><math><mi xlink:href="javascript:alert(1)">x</mi></math>ok`,
+			`ok`,
+		},
+		{
+			"noscript mutation",
+			`<noscript><p title="</noscript><img src=x onerror=alert(1)>"></noscript>`,
+			`&#34;&gt;`,
+		},
+		{
+			"textarea content",
+			`<textarea></textarea><script>alert(1)</script></textarea>ok`,
+			`ok`,
+		},
+		{
+			"invalid UTF-8",
+			"a\xffb\x00c",
+			"a�bc",
+		},
+	} {
+		got := HTMLSanitized(test.input, nil)
+		if got.String() != test.want {
+			t.Errorf("%s: HTMLSanitized(%q) = %q, want %q", test.desc, test.input, got, test.want)
+		}
+		if again := HTMLSanitized(got.String(), nil); again != got {
+			t.Errorf("%s: HTMLSanitized(%q) = %q, want %q", test.desc, got, again, got)
+		}
+	}
+}
+
+func TestHTMLSanitizedPolicy(t *testing.T) {
+	p := DefaultSanitizerPolicy()
+	p.Elements["img"] = true
+	p.Elements["script"] = true
+	p.Elements["style"] = true
+	p.Attributes["src"] = true
+	p.Attributes["alt"] = true
+	p.Attributes["onerror"] = true
+	p.Attributes["style"] = true
+	p.Attributes["srcset"] = true
+	for _, test := range [...]struct {
+		desc, input, want string
+	}{
+		{
+			"allowed image",
+			`<img src="https://example.com/a.png" alt="A">`,
+			`<img src="https://example.com/a.png" alt="A">`,
+		},
+		{
+			"event handler cannot be allowed",
+			`<img src="a.png" onerror="alert(1)">`,
+			`<img src="a.png">`,
+		},
+		{
+			"src is sanitized",
+			`<img src="javascript:alert(1)" srcset="javascript:alert(1) 1x">`,
+			`<img src="about:invalid#zGoSafez">`,
+		},
+		{
+			"script cannot be allowed",
+			`<script>alert(1)</script><style>*{}</style>ok`,
+			`ok`,
+		},
+		{
+			"style cannot be allowed",
+			`<p style="background:url(https://evil.com/)">x</p>`,
+			`<p>x</p>`,
+		},
+	} {
+		got := HTMLSanitized(test.input, p)
+		if got.String() != test.want {
+			t.Errorf("%s: HTMLSanitized(%q) = %q, want %q", test.desc, test.input, got, test.want)
+		}
+	}
+
+	// Modifying a DefaultSanitizerPolicy does not change the default policy.
+	if got, want := HTMLSanitized(`<img src="a.png">`, nil).String(), ""; got != want {
+		t.Errorf("HTMLSanitized with nil policy = %q, want %q", got, want)
+	}
+
+	empty := &SanitizerPolicy{}
+	if got, want := HTMLSanitized(`<p><a href="/">a</a> <b>b</b></p>`, empty).String(), "a b"; got != want {
+		t.Errorf("HTMLSanitized with empty policy = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2017 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package safehtml
+
+import (
+	"strings"
+)
+
+// HTMLTable returns an HTML <table> element with a <tbody> containing a <tr>
+// row for each element of rows, and a <td> cell for each string in a row. The
+// content of each cell is escaped as by HTMLEscaped.
+//
+// The table has a class attribute listing the given classes, if any. Empty
+// identifiers, and identifiers that are not well-formed, which can only be
+// created through unchecked conversions, are ignored.
+//
+// For tables with more complex structure, use the template package instead.
+func HTMLTable(rows [][]string, classes ...Identifier) HTML {
+	var b strings.Builder
+	b.WriteString("<table")
+	writeClassAttribute(&b, classes)
+	b.WriteString("><tbody>")
+	for _, row := range rows {
+		b.WriteString("<tr>")
+		for _, cell := range row {
+			b.WriteString("<td>")
+			b.WriteString(escapeAndCoerceToInterchangeValid(cell))
+			b.WriteString("</td>")
+		}
+		b.WriteString("</tr>")
+	}
+	b.WriteString("</tbody></table>")
+	return HTML{b.String()}
+}
+
+// HTMLUnorderedList returns an HTML <ul> element with an <li> element for each
+// of items, whose content is escaped as by HTMLEscaped.
+//
+// The list has a class attribute listing the given classes, if any. Empty
+// identifiers, and identifiers that are not well-formed, which can only be
+// created through unchecked conversions, are ignored.
+func HTMLUnorderedList(items []string, classes ...Identifier) HTML {
+	return htmlList("ul", items, classes)
+}
+
+// HTMLOrderedList is like HTMLUnorderedList, but returns an HTML <ol> element.
+func HTMLOrderedList(items []string, classes ...Identifier) HTML {
+	return htmlList("ol", items, classes)
+}
+
+// htmlList returns an HTML list element with the given tag name, containing
+// an escaped <li> element for each of items.
+func htmlList(tag string, items []string, classes []Identifier) HTML {
+	var b strings.Builder
+	b.WriteString("<" + tag)
+	writeClassAttribute(&b, classes)
+	b.WriteString(">")
+	for _, item := range items {
+		b.WriteString("<li>")
+		b.WriteString(escapeAndCoerceToInterchangeValid(item))
+		b.WriteString("</li>")
+	}
+	b.WriteString("</" + tag + ">")
+	return HTML{b.String()}
+}
+
+// writeClassAttribute writes a class attribute listing the non-empty classes
+// to b, if there are any. Classes that are not well-formed identifiers are
+// ignored, so the attribute value never needs escaping.
+func writeClassAttribute(b *strings.Builder, classes []Identifier) {
+	first := true
+	for _, class := range classes {
+		// Identifiers created through unchecked conversions are not guaranteed to be well-formed.
+		if class.String() == "" || !onlyAlphanumericsOrHyphenPattern.MatchString(class.String()) {
+			continue
+		}
+		if first {
+			b.WriteString(` class="`)
+			first = false
+		} else {
+			b.WriteByte(' ')
+		}
+		b.WriteString(class.String())
+	}
+	if !first {
+		b.WriteByte('"')
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2017 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package safehtml
+
+import (
+	"testing"
+)
+
+func TestHTMLTable(t *testing.T) {
+	for _, test := range [...]struct {
+		desc    string
+		rows    [][]string
+		classes []Identifier
+		want    string
+	}{
+		{
+			"empty",
+			nil,
+			nil,
+			`<table><tbody></tbody></table>`,
+		},
+		{
+			"escaped cells",
+			[][]string{{"Name", "Comment"}, {"<script>alert(1)</script>", `"quoted" & 'single'`}},
+			nil,
+			`<table><tbody><tr><td>Name</td><td>Comment</td></tr>` +
+				`<tr><td>&lt;script&gt;alert(1)&lt;/script&gt;</td><td>&#34;quoted&#34; &amp; &#39;single&#39;</td></tr></tbody></table>`,
+		},
+		{
+			"ragged rows and empty cells",
+			[][]string{{"a"}, {}, {"", "b"}},
+			nil,
+			`<table><tbody><tr><td>a</td></tr><tr></tr><tr><td></td><td>b</td></tr></tbody></table>`,
+		},
+		{
+			"classes",
+			[][]string{{"</td></tr></tbody></table><img src=x onerror=alert(1)>"}},
+			[]Identifier{IdentifierFromConstant("data-table"), {}, IdentifierFromConstantPrefix("theme", "dark")},
+			`<table class="data-table theme-dark"><tbody><tr><td>&lt;/td&gt;&lt;/tr&gt;&lt;/tbody&gt;&lt;/table&gt;&lt;img src=x onerror=alert(1)&gt;</td></tr></tbody></table>`,
+		},
+		{
+			"malformed classes",
+			nil,
+			[]Identifier{{`x" onclick="alert(1)`}, IdentifierFromConstant("ok"), {"a b"}},
+			`<table class="ok"><tbody></tbody></table>`,
+		},
+		{
+			"invalid UTF-8",
+			[][]string{{"a\xffb"}},
+			nil,
+			"<table><tbody><tr><td>a�b</td></tr></tbody></table>",
+		},
+	} {
+		if got := HTMLTable(test.rows, test.classes...).String(); got != test.want {
+			t.Errorf("%s: HTMLTable(%q) = %q, want %q", test.desc, test.rows, got, test.want)
+		}
+	}
+}
+
+func TestHTMLList(t *testing.T) {
+	items := []string{"first", "<script>alert(1)</script>", ""}
+	const wantItems = `<li>first</li><li>&lt;script&gt;alert(1)&lt;/script&gt;</li><li></li>`
+	for _, test := range [...]struct {
+		got, want string
+	}{
+		{HTMLUnorderedList(items).String(), `<ul>` + wantItems + `</ul>`},
+		{HTMLOrderedList(items).String(), `<ol>` + wantItems + `</ol>`},
+		{HTMLUnorderedList(nil, IdentifierFromConstant("menu")).String(), `<ul class="menu"></ul>`},
+		{HTMLOrderedList([]string{"</li></ol><b>"}, IdentifierFromConstant("steps"), IdentifierFromConstant("compact")).String(),
+			`<ol class="steps compact"><li>&lt;/li&gt;&lt;/ol&gt;&lt;b&gt;</li></ol>`},
+		{HTMLUnorderedList([]string{"a"}, Identifier{}).String(), `<ul><li>a</li></ul>`},
+		{HTMLOrderedList(nil, Identifier{`x"><script>alert(1)</script>`}).String(), `<ol></ol>`},
+	} {
+		if test.got != test.want {
+			t.Errorf("got %q, want %q", test.got, test.want)
+		}
+	}
+}
@@ -94,6 +94,10 @@ func TestIdentifierFromConstantPrefix(t *testing.T) {
 		{"foo", "b ar", "", "contains non-alphanumeric runes"},
 		{"foo", "bar ", "", "contains non-alphanumeric runes"},
 		{"foo", "bar\t", "", "contains non-alphanumeric runes"},
+		{"foo", `bar"`, "", "contains non-alphanumeric runes"},
+		{"foo", `bar' onclick='alert(1)`, "", "contains non-alphanumeric runes"},
+		{"foo", "<script>", "", "contains non-alphanumeric runes"},
+		{"foo", "bar></div>", "", "contains non-alphanumeric runes"},
 	} {
 		id, panicMsg := tryIdentifierFromConstantPrefix(test.prefix, test.value)
 		inputs := fmt.Sprintf("prefix %q, value %q", test.prefix, test.value)
@@ -0,0 +1,88 @@
+// Copyright (c) 2021 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+// Package audit records calls to the unchecked conversion functions in package
+// safehtml/uncheckedconversions and package safehtml/template/uncheckedconversions
+// when built with the safehtml_audit build tag. audit must be imported only by
+// these two packages.
+package audit
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Conversion describes a call to an unchecked conversion function.
+type Conversion struct {
+	// Func is the fully-qualified name of the conversion function, e.g.
+	// "github.com/google/safehtml/uncheckedconversions.HTMLFromStringKnownToSatisfyTypeContract".
+	Func string
+	// Stack is the stack trace of the call, starting at the caller of the
+	// conversion function, in the format of runtime/debug.Stack without the
+	// goroutine header.
+	Stack string
+}
+
+var (
+	mu          sync.Mutex
+	conversions []Conversion
+	hook        func(Conversion)
+)
+
+// Record records a call to the conversion function that called Record, if
+// Enabled is true. Otherwise, it does nothing.
+func Record() {
+	if !Enabled {
+		return
+	}
+	pcs := make([]uintptr, 64)
+	// Skip runtime.Callers and Record, so that the first frame is the
+	// conversion function.
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	fn, _ := frames.Next()
+	var b strings.Builder
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&b, "%s(...)\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	c := Conversion{Func: fn.Function, Stack: b.String()}
+	mu.Lock()
+	conversions = append(conversions, c)
+	h := hook
+	mu.Unlock()
+	if h != nil {
+		h(c)
+	}
+}
+
+// Conversions returns the conversions recorded since the program started or
+// Reset was last called, in the order in which they were made.
+func Conversions() []Conversion {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]Conversion(nil), conversions...)
+}
+
+// Reset discards all recorded conversions.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	conversions = nil
+}
+
+// SetHook sets a function that is called with every recorded conversion. A nil
+// hook disables the previous hook.
+func SetHook(h func(Conversion)) {
+	mu.Lock()
+	defer mu.Unlock()
+	hook = h
+}
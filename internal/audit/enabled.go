@@ -0,0 +1,13 @@
+// Copyright (c) 2021 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+//go:build safehtml_audit
+// +build safehtml_audit
+
+package audit
+
+// Enabled reports whether unchecked conversions are recorded.
+const Enabled = true
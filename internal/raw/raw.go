@@ -5,8 +5,9 @@
 // https://developers.google.com/open-source/licenses/bsd
 
 // Package raw provides a coordination point for package safehtml, package
-// uncheckedconversions, package legacyconversions, and package testconversions.
-// raw must only be imported by these four packages.
+// uncheckedconversions, package legacyconversions, package testconversions,
+// and package safehtml/template. raw must only be imported by these five
+// packages.
 package raw
 
 // HTML is the raw constructor for a safehtml.HTML.
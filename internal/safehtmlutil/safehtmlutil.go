@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"strings"
 )
 
 // IsSafeTrustedResourceURLPrefix returns whether the given prefix is safe to use as a
@@ -51,7 +52,17 @@ var urlDoubleDotSegmentPattern = regexp.MustCompile(`(?i)(?:\.|%2e)(?:\.|%2e)`)
 // QueryEscapeURL produces an output that can be embedded in a URL query.
 // The output can be embedded in an HTML attribute without further escaping.
 func QueryEscapeURL(args ...interface{}) string {
-	return urlProcessor(false, Stringify(args...))
+	return urlProcessor(urlModeQueryEscape, Stringify(args...))
+}
+
+// EscapeURLPathSegment produces an output that can be embedded in a URL path
+// as part of a single path segment. Unlike QueryEscapeURL, it leaves the
+// sub-delimiters and ':' and '@', which may appear in path segments unencoded,
+// unchanged, but it escapes '/', '?' and '#', so that the output cannot
+// introduce further path segments, a query or a fragment.
+// The output can be embedded in an HTML attribute after escaping '&' to '&amp;'.
+func EscapeURLPathSegment(args ...interface{}) string {
+	return urlProcessor(urlModePathSegmentEscape, Stringify(args...))
 }
 
 // NormalizeURL normalizes URL content so it can be embedded in a quote-delimited
@@ -60,12 +71,25 @@ func QueryEscapeURL(args ...interface{}) string {
 // encode '&' so correct embedding in an HTML attribute requires escaping of
 // '&' to '&amp;'.
 func NormalizeURL(args ...interface{}) string {
-	return urlProcessor(true, Stringify(args...))
+	return urlProcessor(urlModeNormalize, Stringify(args...))
 }
 
-// urlProcessor normalizes (when norm is true) or escapes its input to produce
+// urlMode determines how urlProcessor processes its input.
+type urlMode int
+
+const (
+	// urlModeQueryEscape escapes all reserved characters.
+	urlModeQueryEscape urlMode = iota
+	// urlModeNormalize leaves reserved characters and valid escapes unchanged.
+	urlModeNormalize
+	// urlModePathSegmentEscape escapes the reserved characters that delimit
+	// path segments and other URL components.
+	urlModePathSegmentEscape
+)
+
+// urlProcessor normalizes or escapes its input, depending on mode, to produce
 // a valid hierarchical or opaque URL part.
-func urlProcessor(norm bool, s string) string {
+func urlProcessor(mode urlMode, s string) string {
 	var b bytes.Buffer
 	written := 0
 	// The byte loop below assumes that all URLs use UTF-8 as the
@@ -84,7 +108,11 @@ func urlProcessor(norm bool, s string) string {
 		// the obsolete "mark" rule in an appendix in RFC 3986
 		// so can be safely encoded.
 		case '!', '#', '$', '&', '*', '+', ',', '/', ':', ';', '=', '?', '@', '[', ']':
-			if norm {
+			if mode == urlModeNormalize {
+				continue
+			}
+			// RFC 3986 sec 3.3 permits sub-delims, ':' and '@' in path segments.
+			if mode == urlModePathSegmentEscape && c != '#' && c != '/' && c != '?' && c != '[' && c != ']' {
 				continue
 			}
 		// Unreserved according to RFC 3986 sec 2.3
@@ -96,7 +124,7 @@ func urlProcessor(norm bool, s string) string {
 			continue
 		case '%':
 			// When normalizing do not re-encode valid escapes.
-			if norm && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			if mode == urlModeNormalize && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
 				continue
 			}
 		default:
@@ -127,6 +155,38 @@ func isHex(c byte) bool {
 	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
 }
 
+// IsURLSlash reports whether c is treated as a path separator by browsers in
+// URLs with special schemes.
+func IsURLSlash(c byte) bool {
+	return c == '/' || c == '\\'
+}
+
+// DefaultPort returns the default port of the given lowercase URL scheme, or
+// "" if the scheme has none.
+func DefaultPort(scheme string) string {
+	return defaultPorts[scheme]
+}
+
+// defaultPorts contains the default ports of the special schemes that have one.
+// See https://url.spec.whatwg.org/#special-scheme.
+var defaultPorts = map[string]string{"ftp": "21", "http": "80", "https": "443", "ws": "80", "wss": "443"}
+
+// NormalizeHost returns host, which may include a port, in lowercase and
+// without its port if the port is empty or the default port of the given
+// lowercase URL scheme.
+func NormalizeHost(scheme, host string) string {
+	host = strings.ToLower(host)
+	i := strings.LastIndexByte(host, ':')
+	if i == -1 || strings.LastIndexByte(host, ']') > i {
+		// No port, or an IPv6 address without a port.
+		return host
+	}
+	if port := host[i+1:]; port == "" || port == DefaultPort(scheme) {
+		return host[:i]
+	}
+	return host
+}
+
 // Stringify converts its arguments to a string. It is equivalent to
 // fmt.Sprint(args...), except that it deferences all pointers.
 func Stringify(args ...interface{}) string {
@@ -134,6 +134,28 @@ func TestNormalizeURL(t *testing.T) {
 	}
 }
 
+func TestNormalizeHost(t *testing.T) {
+	for _, test := range [...]struct {
+		scheme, host, want string
+	}{
+		{"https", "Example.COM", "example.com"},
+		{"https", "example.com:443", "example.com"},
+		{"https", "example.com:", "example.com"},
+		{"https", "example.com:80", "example.com:80"},
+		{"http", "example.com:80", "example.com"},
+		{"http", "example.com:8080", "example.com:8080"},
+		{"wss", "example.com:443", "example.com"},
+		{"https", "[::1]", "[::1]"},
+		{"https", "[::1]:443", "[::1]"},
+		{"https", "[::1]:8443", "[::1]:8443"},
+		{"mailto", "example.com:80", "example.com:80"},
+	} {
+		if got := NormalizeHost(test.scheme, test.host); got != test.want {
+			t.Errorf("NormalizeHost(%q, %q) = %q, want %q", test.scheme, test.host, got, test.want)
+		}
+	}
+}
+
 func TestQueryEscapeURL(t *testing.T) {
 	const input = "\x00\x01\x02\x03\x04\x05\x06\x07\x08\t\n\x0b\x0c\r\x0e\x0f" +
 		"\x10\x11\x12\x13\x14\x15\x16\x17\x18\x19\x1a\x1b\x1c\x1d\x1e\x1f" +
@@ -158,6 +180,29 @@ func TestQueryEscapeURL(t *testing.T) {
 	}
 }
 
+func TestEscapeURLPathSegment(t *testing.T) {
+	for _, test := range [...]struct {
+		in, want string
+	}{
+		{"", ""},
+		{"alice", "alice"},
+		{"a/b", "a%2fb"},
+		{"a?b=c", "a%3fb=c"},
+		{"a#b", "a%23b"},
+		{"a b", "a%20b"},
+		{"a%20b", "a%2520b"},
+		{"..", ".."},
+		{"[::1]", "%5b::1%5d"},
+		{"!$&*+,;=:@-._~", "!$&*+,;=:@-._~"},
+		{`'()"<>\` + "`{|}^", "%27%28%29%22%3c%3e%5c%60%7b%7c%7d%5e"},
+		{"café\n", "caf%c3%a9%0a"},
+	} {
+		if got := EscapeURLPathSegment(test.in); got != test.want {
+			t.Errorf("EscapeURLPathSegment(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
 func BenchmarkQueryEscapeURL(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		QueryEscapeURL("http://example.com:80/foo?q=bar%20&baz=x+y#frag")
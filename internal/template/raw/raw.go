@@ -14,3 +14,6 @@ var TrustedSource interface{}
 
 // TrustedTemplate is the raw constructor for a template.TrustedTemplate.
 var TrustedTemplate interface{}
+
+// TrustedFS is the raw constructor for a template.TrustedFS.
+var TrustedFS interface{}
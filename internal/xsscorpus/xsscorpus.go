@@ -0,0 +1,139 @@
+// Copyright (c) 2017 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+// Package xsscorpus reads the corpus of known XSS payloads used by the tests of
+// package safehtml and safehtml/template, and contains helpers shared by the
+// test harnesses that check that the payloads are neutralized.
+//
+// The corpus is a text file in which each line that is neither empty nor a
+// comment starting with '#' holds a payload quoted as a Go string literal. The
+// payload may be followed by tab-separated skip directives of the form
+//
+//	skip <harness>: <tracking note>
+//
+// which record that the named test harness does not yet neutralize the payload.
+// A harness must report skipped payloads rather than silently ignore them.
+package xsscorpus
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// A Payload is an entry of the corpus.
+type Payload struct {
+	// Value is the unquoted payload.
+	Value string
+	// Line is the line number of the payload in the corpus file.
+	Line int
+	// Skip maps the names of harnesses that do not yet neutralize the payload
+	// to the tracking note explaining why.
+	Skip map[string]string
+}
+
+// Load returns the payloads in the corpus file at path, in file order. It
+// returns an error if the file cannot be read, is malformed, or contains
+// duplicate payloads.
+func Load(path string) ([]Payload, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var ret []Payload
+	seen := map[string]int{}
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		text := s.Text()
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		value, err := strconv.Unquote(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: malformed payload %s: %v", path, line, fields[0], err)
+		}
+		if prev, ok := seen[value]; ok {
+			return nil, fmt.Errorf("%s:%d: payload %s duplicates line %d", path, line, fields[0], prev)
+		}
+		seen[value] = line
+		p := Payload{Value: value, Line: line}
+		for _, directive := range fields[1:] {
+			harness, note, ok := parseSkip(directive)
+			if !ok {
+				return nil, fmt.Errorf("%s:%d: malformed directive %q, want \"skip <harness>: <tracking note>\"", path, line, directive)
+			}
+			if p.Skip == nil {
+				p.Skip = map[string]string{}
+			}
+			p.Skip[harness] = note
+		}
+		ret = append(ret, p)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// parseSkip parses a skip directive, returning the harness name and tracking
+// note. Both must be non-empty.
+func parseSkip(directive string) (harness, note string, ok bool) {
+	rest := strings.TrimPrefix(directive, "skip ")
+	if rest == directive {
+		return "", "", false
+	}
+	i := strings.Index(rest, ":")
+	if i < 0 {
+		return "", "", false
+	}
+	harness, note = strings.TrimSpace(rest[:i]), strings.TrimSpace(rest[i+1:])
+	if harness == "" || strings.ContainsAny(harness, " ") || note == "" {
+		return "", "", false
+	}
+	return harness, note, true
+}
+
+// IsBrowserSafeURL reports whether a browser that parses url as described in
+// https://url.spec.whatwg.org/#concept-basic-url-parser would resolve it to a
+// relative URL or to a URL whose scheme is known not to run script, such as
+// http or a data URL with an image, audio or video media type other than SVG.
+// Other schemes are conservatively reported as unsafe. url is expected to be
+// HTML-escaped when it is written to a document, so character references in url
+// are not decoded.
+func IsBrowserSafeURL(url string) bool {
+	url = strings.TrimFunc(url, func(r rune) bool { return r <= ' ' })
+	url = strings.NewReplacer("\t", "", "\n", "", "\r", "").Replace(url)
+	if url == "" || !isASCIIAlpha(url[0]) {
+		return true
+	}
+	i := 1
+	for i < len(url) && (isASCIIAlpha(url[i]) || '0' <= url[i] && url[i] <= '9' || strings.IndexByte("+-.", url[i]) >= 0) {
+		i++
+	}
+	if i == len(url) || url[i] != ':' {
+		// Relative URL.
+		return true
+	}
+	switch strings.ToLower(url[:i]) {
+	case "http", "https", "mailto", "ftp":
+		return true
+	case "data":
+		mediaType := strings.ToLower(url[i+1:])
+		if j := strings.IndexAny(mediaType, ";,"); j >= 0 {
+			mediaType = mediaType[:j]
+		}
+		return mediaType != "image/svg+xml" && (strings.HasPrefix(mediaType, "image/") || strings.HasPrefix(mediaType, "audio/") || strings.HasPrefix(mediaType, "video/"))
+	}
+	return false
+}
+
+func isASCIIAlpha(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
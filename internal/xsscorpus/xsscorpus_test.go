@@ -0,0 +1,90 @@
+// Copyright (c) 2017 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package xsscorpus
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "corpus.txt")
+	const corpus = "# Comment.\n" +
+		"\n" +
+		`"javascript:alert(1)"` + "\n" +
+		`"\x00<script>"` + "\tskip url: not yet rejected\tskip template: also not yet escaped\n"
+	if err := ioutil.WriteFile(path, []byte(corpus), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Payload{
+		{Value: "javascript:alert(1)", Line: 3},
+		{Value: "\x00<script>", Line: 4, Skip: map[string]string{
+			"url":      "not yet rejected",
+			"template": "also not yet escaped",
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load = %#v, want %#v", got, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, test := range [...]struct {
+		corpus, err string
+	}{
+		{"javascript:alert(1)\n", "malformed payload"},
+		{`"a"` + "\n" + `"a"` + "\n", "duplicates line 1"},
+		{`"a"` + "\tskip: no harness\n", "malformed directive"},
+		{`"a"` + "\tskip url\n", "malformed directive"},
+		{`"a"` + "\tskip url:\n", "malformed directive"},
+		{`"a"` + "\tignore url: note\n", "malformed directive"},
+	} {
+		path := filepath.Join(t.TempDir(), "corpus.txt")
+		if err := ioutil.WriteFile(path, []byte(test.corpus), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := Load(path)
+		if err == nil {
+			t.Errorf("Load(%q) succeeded, want error containing %q", test.corpus, test.err)
+		} else if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Load(%q) error = %q, want error containing %q", test.corpus, err, test.err)
+		}
+	}
+}
+
+func TestIsBrowserSafeURL(t *testing.T) {
+	for _, test := range [...]struct {
+		url  string
+		want bool
+	}{
+		{"", true},
+		{"/path", true},
+		{"a/b:c", true},
+		{"http://example.com/", true},
+		{"MAILTO:a@example.com", true},
+		{"data:image/png;base64,AAAA", true},
+		{"data:video/mp4,AAAA", true},
+		{"javascript:alert(1)", false},
+		{" \x01java\tscript:alert(1)", false},
+		{"VBScript:msgbox(1)", false},
+		{"data:text/html,<script>", false},
+		{"data:image/svg+xml;base64,AAAA", false},
+		{"tel:+1", false},
+		{"javascript&colon;alert(1)", true},
+	} {
+		if got := IsBrowserSafeURL(test.url); got != test.want {
+			t.Errorf("IsBrowserSafeURL(%q) = %t, want %t", test.url, got, test.want)
+		}
+	}
+}
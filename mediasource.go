@@ -0,0 +1,122 @@
+// Copyright (c) 2017 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package safehtml
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// A MediaSource is a resource of a picture, audio or video element, with an
+// optional MIME type and media query that browsers use to choose among the
+// resources of the element.
+type MediaSource struct {
+	URL TrustedResourceURL
+	// Type is the MIME type of the resource, optionally with parameters, such
+	// as "image/webp" or `video/mp4; codecs="avc1.42E01E"`. If empty, the
+	// type attribute is omitted.
+	Type string
+	// Media is a media query, such as "(min-width: 800px)" or
+	// "screen and (orientation: landscape)". If empty, the media attribute is
+	// omitted.
+	Media string
+}
+
+// mediaTypePattern matches MIME types with optional parameters, whose values
+// are tokens or quoted strings without escapes.
+//
+// See https://mimesniff.spec.whatwg.org/#parsing-a-mime-type.
+var mediaTypePattern = regexp.MustCompile(`^(?i:` + mimeToken + `/` + mimeToken +
+	`(?:` + cssWhitespace + `;` + cssWhitespace + mimeToken + `=(?:` + mimeToken + `|"[^"\\\x00-\x1f\x7f]*"))*)$`)
+
+// mimeToken matches a type, subtype or parameter name or value of a MIME type.
+const mimeToken = `[a-z0-9!#$&^_.+-]+`
+
+// mediaQueryPattern matches the subset of media query lists accepted in
+// MediaSource.Media: a comma-separated list of queries, each of which is a
+// media type, optionally preceded by not or only and followed by media features
+// joined by and, or media features joined by and. A media feature is a name,
+// optionally followed by a number with an optional unit, a ratio or an
+// identifier, in parentheses.
+//
+// See https://drafts.csswg.org/mediaqueries/#mq-syntax.
+var mediaQueryPattern = regexp.MustCompile(`^(?i:` + cssWhitespace + mediaQuery + `(?:` + cssWhitespace + `,` + cssWhitespace + mediaQuery + `)*` + cssWhitespace + `)$`)
+
+const (
+	cssWhitespace    = `[\t\n\f\r ]*`
+	mediaIdent       = `[a-z][a-z0-9-]*`
+	mediaNumber      = `(?:[0-9]+(?:\.[0-9]+)?|\.[0-9]+)`
+	mediaValue       = `(?:-?` + mediaNumber + `(?:[a-z]+|%)?(?:` + cssWhitespace + `/` + cssWhitespace + mediaNumber + `)?|` + mediaIdent + `)`
+	mediaFeature     = `\(` + cssWhitespace + mediaIdent + `(?:` + cssWhitespace + `:` + cssWhitespace + mediaValue + `)?` + cssWhitespace + `\)`
+	mediaAndFeatures = `(?:[\t\n\f\r ]+and[\t\n\f\r ]+` + mediaFeature + `)*`
+	mediaQuery       = `(?:(?:(?:not|only)[\t\n\f\r ]+)?(?:all|print|screen)` + mediaAndFeatures + `|` + mediaFeature + mediaAndFeatures + `)`
+)
+
+// HTMLPictureSources returns a <source> element for each of sources, in
+// order, for use in a <picture> element. Each element has a srcset attribute
+// containing the URL of the source, in which whitespace, and commas at its
+// start or end, are percent-encoded as by URLSetFromTrustedResourceURLs, and
+// type and media attributes if Type and Media are set.
+//
+// It returns an error if a URL is empty, if a Type is not a MIME type, or if
+// a Media is not in the subset of media queries described below. Media
+// queries are restricted to a comma-separated list of queries such as
+// "screen", "not print", "only screen and (min-width: 800px)" or
+// "(min-resolution: 2dppx) and (orientation: landscape)", made up of a media
+// type (all, print or screen), optionally preceded by not or only, and media
+// features in parentheses joined by and.
+func HTMLPictureSources(sources ...MediaSource) (HTML, error) {
+	return htmlSources("srcset", sources)
+}
+
+// HTMLMediaSources is like HTMLPictureSources, but the <source> elements have
+// a src attribute containing the URL of the source, for use in an <audio> or
+// <video> element.
+func HTMLMediaSources(sources ...MediaSource) (HTML, error) {
+	return htmlSources("src", sources)
+}
+
+// htmlSources returns a <source> element for each of sources, with the URL of
+// the source in the given attribute, which is src or srcset.
+func htmlSources(urlAttr string, sources []MediaSource) (HTML, error) {
+	var b strings.Builder
+	for _, s := range sources {
+		url := s.URL.String()
+		if url == "" {
+			return HTML{}, fmt.Errorf("source with type %q and media %q has an empty URL", s.Type, s.Media)
+		}
+		if s.Type != "" && !mediaTypePattern.MatchString(s.Type) {
+			return HTML{}, fmt.Errorf("source %q has an invalid type %q", url, s.Type)
+		}
+		if s.Media != "" && !mediaQueryPattern.MatchString(s.Media) {
+			return HTML{}, fmt.Errorf("source %q has an unsupported media query %q", url, s.Media)
+		}
+		if urlAttr == "srcset" {
+			set, err := URLSetFromTrustedResourceURLs(ImageCandidate{URL: s.URL})
+			if err != nil {
+				return HTML{}, err
+			}
+			url = set.String()
+		}
+		b.WriteString("<source " + urlAttr + `="`)
+		b.WriteString(escapeAndCoerceToInterchangeValid(url))
+		b.WriteByte('"')
+		if s.Type != "" {
+			b.WriteString(` type="`)
+			b.WriteString(escapeAndCoerceToInterchangeValid(s.Type))
+			b.WriteByte('"')
+		}
+		if s.Media != "" {
+			b.WriteString(` media="`)
+			b.WriteString(escapeAndCoerceToInterchangeValid(s.Media))
+			b.WriteByte('"')
+		}
+		b.WriteByte('>')
+	}
+	return HTML{b.String()}, nil
+}
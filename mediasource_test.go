@@ -0,0 +1,114 @@
+// Copyright (c) 2017 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package safehtml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTMLPictureSources(t *testing.T) {
+	for _, test := range [...]struct {
+		desc    string
+		sources []MediaSource
+		want    string
+	}{
+		{
+			desc: "no sources",
+		},
+		{
+			desc: "multiple sources",
+			sources: []MediaSource{
+				{URL: TrustedResourceURL{"https://example.com/wide.avif"}, Type: "image/avif", Media: "(min-width: 800px)"},
+				{URL: TrustedResourceURL{"https://example.com/wide.webp"}, Type: "image/webp", Media: "screen and (min-width: 800px) and (orientation: landscape)"},
+				{URL: TrustedResourceURL{"https://example.com/narrow.png"}},
+			},
+			want: `<source srcset="https://example.com/wide.avif" type="image/avif" media="(min-width: 800px)">` +
+				`<source srcset="https://example.com/wide.webp" type="image/webp" media="screen and (min-width: 800px) and (orientation: landscape)">` +
+				`<source srcset="https://example.com/narrow.png">`,
+		},
+		{
+			desc: "media query list",
+			sources: []MediaSource{
+				{URL: TrustedResourceURL{"/a.png"}, Media: "only screen and (min-resolution: 2dppx), print"},
+				{URL: TrustedResourceURL{"/b.png"}, Media: "NOT all and (aspect-ratio: 16 / 9)"},
+				{URL: TrustedResourceURL{"/c.png"}, Media: "(prefers-color-scheme: dark) and (monochrome)"},
+			},
+			want: `<source srcset="/a.png" media="only screen and (min-resolution: 2dppx), print">` +
+				`<source srcset="/b.png" media="NOT all and (aspect-ratio: 16 / 9)">` +
+				`<source srcset="/c.png" media="(prefers-color-scheme: dark) and (monochrome)">`,
+		},
+		{
+			desc: "escaped URL",
+			sources: []MediaSource{
+				{URL: TrustedResourceURL{`/a b,.png?x="1"&y=<2>,`}},
+			},
+			want: `<source srcset="/a%20b,.png?x=&#34;1&#34;&amp;y=&lt;2&gt;%2c">`,
+		},
+	} {
+		got, err := HTMLPictureSources(test.sources...)
+		if err != nil {
+			t.Errorf("%s: HTMLPictureSources failed: %v", test.desc, err)
+			continue
+		}
+		if got.String() != test.want {
+			t.Errorf("%s: HTMLPictureSources = %q, want %q", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestHTMLMediaSources(t *testing.T) {
+	got, err := HTMLMediaSources(
+		MediaSource{URL: TrustedResourceURL{"https://example.com/a.webm"}, Type: "video/webm"},
+		MediaSource{URL: TrustedResourceURL{"https://example.com/a.mp4"}, Type: `video/mp4; codecs="avc1.42E01E, mp4a.40.2"`, Media: "(max-width: 600px)"},
+	)
+	if err != nil {
+		t.Fatalf("HTMLMediaSources failed: %v", err)
+	}
+	want := `<source src="https://example.com/a.webm" type="video/webm">` +
+		`<source src="https://example.com/a.mp4" type="video/mp4; codecs=&#34;avc1.42E01E, mp4a.40.2&#34;" media="(max-width: 600px)">`
+	if got.String() != want {
+		t.Errorf("HTMLMediaSources = %q, want %q", got, want)
+	}
+}
+
+func TestHTMLPictureSourcesErrors(t *testing.T) {
+	url := TrustedResourceURL{"https://example.com/a.png"}
+	for _, test := range [...]struct {
+		desc   string
+		source MediaSource
+		want   string
+	}{
+		{"empty URL", MediaSource{Type: "image/png"}, "empty URL"},
+		{"type without subtype", MediaSource{URL: url, Type: "image"}, "invalid type"},
+		{"type breakout", MediaSource{URL: url, Type: `image/png" onerror="alert(1)`}, "invalid type"},
+		{"type with quote in parameter", MediaSource{URL: url, Type: `image/png; a="b"c"`}, "invalid type"},
+		{"media breakout", MediaSource{URL: url, Media: `(min-width: 1px)" onerror="alert(1)`}, "unsupported media query"},
+		{"media markup", MediaSource{URL: url, Media: `screen><script>alert(1)</script>`}, "unsupported media query"},
+		{"media function", MediaSource{URL: url, Media: `(min-width: calc(1px + 2px))`}, "unsupported media query"},
+		{"media url", MediaSource{URL: url, Media: `(min-width: url(https://evil.com/))`}, "unsupported media query"},
+		{"media at-rule", MediaSource{URL: url, Media: `screen; @import "https://evil.com/"`}, "unsupported media query"},
+		{"media comment", MediaSource{URL: url, Media: `screen /* comment */`}, "unsupported media query"},
+		{"media unknown type", MediaSource{URL: url, Media: `tv`}, "unsupported media query"},
+		{"media missing space", MediaSource{URL: url, Media: `screen and(min-width: 1px)`}, "unsupported media query"},
+		{"media empty query", MediaSource{URL: url, Media: `screen,`}, "unsupported media query"},
+	} {
+		for _, f := range []func(...MediaSource) (HTML, error){HTMLPictureSources, HTMLMediaSources} {
+			got, err := f(MediaSource{URL: url}, test.source)
+			if err == nil {
+				t.Errorf("%s: got %q, want error", test.desc, got)
+				continue
+			}
+			if !strings.Contains(err.Error(), test.want) {
+				t.Errorf("%s: error %q does not contain %q", test.desc, err, test.want)
+			}
+			if got.String() != "" {
+				t.Errorf("%s: got %q with error, want empty HTML", test.desc, got)
+			}
+		}
+	}
+}
@@ -61,6 +61,19 @@ func ScriptFromConstant(script stringConstant) Script {
 // must both be untyped string constants. It returns an error if name is not a
 // valid Javascript identifier or JSON encoding fails.
 //
+// The JSON encoding escapes '<', '>' and '&', as well as U+2028 LINE SEPARATOR
+// and U+2029 PARAGRAPH SEPARATOR, which are line terminators in some
+// Javascript engines, wherever they occur in data, including in the output of
+// json.Marshaler implementations. Hence, data cannot contain sequences such as
+// "</script" or "<!--" that end or alter the enclosing script element. At the
+// top level of a script element, the variable is a property of window, so this
+// is suitable for passing server-side data to client-side code, for example
+// for hydration:
+//
+//	ScriptFromDataAndConstant("__DATA__", data, "")
+//
+// produces a Script that sets window.__DATA__ to data.
+//
 // No runtime validation or sanitization is performed on script; being under
 // application control, it is simply assumed to comply with the Script
 // contract.
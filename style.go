@@ -10,6 +10,7 @@ import (
 	"bytes"
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -146,6 +147,14 @@ type StyleProperties struct {
 	// Note: this property might allow clickjacking, but the risk is limited without
 	// the ability to set the position property to "absolute" or "fixed".
 	ZIndex string
+	// CustomProperties maps the names of CSS custom properties to their values.
+	// See https://www.w3.org/TR/css-variables-1/#defining-variables.
+	// Names must consist of "--" followed by one or more ASCII alphanumeric or
+	// '-' runes; properties with non-conforming names are omitted. Values are
+	// validated like BackgroundColor and the subsequent properties above.
+	// Custom properties are written in sorted order by name, after all other
+	// properties.
+	CustomProperties map[string]string
 }
 
 // identifierPattern matches a subset of valid <ident-token> values defined in
@@ -153,6 +162,10 @@ type StyleProperties struct {
 // keywords defined in https://drafts.csswg.org/css-fonts-3/#family-name-value.
 var identifierPattern = regexp.MustCompile(`^[a-zA-Z][-a-zA-Z]+$`)
 
+// customPropertyNamePattern matches the names of CSS custom properties accepted
+// by StyleFromProperties.
+var customPropertyNamePattern = regexp.MustCompile(`^--[a-zA-Z0-9-]+$`)
+
 // StyleFromProperties constructs a Style containining properties whose values
 // are set in properties. The contents of the returned Style will be of the form
 //
@@ -243,6 +256,18 @@ func StyleFromProperties(properties StyleProperties) Style {
 	if properties.ZIndex != "" {
 		fmt.Fprintf(&buf, "z-index:%s;", filter(properties.ZIndex, safeRegularPropertyValuePattern))
 	}
+	if len(properties.CustomProperties) > 0 {
+		names := make([]string, 0, len(properties.CustomProperties))
+		for name := range properties.CustomProperties {
+			if customPropertyNamePattern.MatchString(name) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(&buf, "%s:%s;", name, filter(properties.CustomProperties[name], safeRegularPropertyValuePattern))
+		}
+	}
 
 	return Style{buf.String()}
 }
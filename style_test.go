@@ -89,9 +89,16 @@ func TestStyleFromProperties(t *testing.T) {
 		{
 			desc: "BackgroundImageURLs invalid runes in URL escaped",
 			input: StyleProperties{
-				BackgroundImageURLs: []string{"http://goodUrl.com/a\"\\\n"},
+				BackgroundImageURLs: []string{"http://goodUrl.com/a\"\\"},
 			},
-			want: `background-image:url("http://goodUrl.com/a\000022\00005C\00000A");`,
+			want: `background-image:url("http://goodUrl.com/a\000022\00005C");`,
+		},
+		{
+			desc: "BackgroundImageURLs control characters in URL rejected",
+			input: StyleProperties{
+				BackgroundImageURLs: []string{"http://goodUrl.com/a\n"},
+			},
+			want: `background-image:url("about:invalid#zGoSafez");`,
 		},
 		{
 			desc: "FontFamily unquoted names",
@@ -219,6 +226,36 @@ func TestStyleFromProperties(t *testing.T) {
 			},
 			want: "z-index:-2;",
 		},
+		{
+			desc: "CustomProperties",
+			input: StyleProperties{
+				CustomProperties: map[string]string{"--accent-color": "#ff0", "--Spacing-2": "4px", "--0": "1"},
+			},
+			want: "--0:1;--Spacing-2:4px;--accent-color:#ff0;",
+		},
+		{
+			desc: "CustomProperties invalid names omitted",
+			input: StyleProperties{
+				CustomProperties: map[string]string{"--": "a", "-a": "a", "color": "red", "--a:b": "c", "--a;b": "c",
+					"--a b": "c", "--a}.evil{": "c", "--é": "c", "--a\\": "c", "--ok": "d"},
+			},
+			want: "--ok:d;",
+		},
+		{
+			desc: "CustomProperties invalid values replaced",
+			input: StyleProperties{
+				CustomProperties: map[string]string{
+					"--a": "red} .evil {color:red",
+					"--b": "url(javascript:alert(1))",
+					"--c": "url(\"https://example.com/\")",
+					"--d": "red;color:blue",
+					"--e": "/* comment */",
+					"--f": "</style>",
+				},
+			},
+			want: "--a:zGoSafezInvalidPropertyValue;--b:zGoSafezInvalidPropertyValue;--c:zGoSafezInvalidPropertyValue;" +
+				"--d:zGoSafezInvalidPropertyValue;--e:zGoSafezInvalidPropertyValue;--f:zGoSafezInvalidPropertyValue;",
+		},
 		{
 			desc: "multiple properties",
 			input: StyleProperties{
@@ -288,6 +325,36 @@ func TestStyleFromProperties(t *testing.T) {
 			},
 			want: "background-size:zGoSafezInvalidPropertyValue;",
 		},
+		{
+			desc: "sanitize property injection in regular value",
+			input: StyleProperties{
+				Color: "red;background-image:url(http://evil.com)",
+				Width: "1px;",
+			},
+			want: "color:zGoSafezInvalidPropertyValue;width:zGoSafezInvalidPropertyValue;",
+		},
+		{
+			desc: "sanitize attribute breakout in regular and enum values",
+			input: StyleProperties{
+				Display:         `none"onclick="alert(1)`,
+				BackgroundColor: `red' onmouseover='alert(1)`,
+			},
+			want: "display:zGoSafezInvalidPropertyValue;background-color:zGoSafezInvalidPropertyValue;",
+		},
+		{
+			desc: "sanitize expression in regular value",
+			input: StyleProperties{
+				Width: "expression(alert(1))",
+			},
+			want: "width:zGoSafezInvalidPropertyValue;",
+		},
+		{
+			desc: "escape quotes and semicolons in font family names",
+			input: StyleProperties{
+				FontFamily: []string{`a";color:red;"`, `b'`},
+			},
+			want: `font-family:"a\000022;color:red;\000022", "b'";`,
+		},
 		{
 			desc: "sanitize invalid enum value",
 			input: StyleProperties{
@@ -388,6 +455,12 @@ func TestStyleFromPropertiesAllFieldsValidated(t *testing.T) {
 			} else {
 				t.Fatalf("unknown slice type for field %q in StyleProperties", v.Type().Field(i).Name)
 			}
+		case reflect.Map:
+			if f.Type().Key().Kind() == reflect.String && f.Type().Elem().Kind() == reflect.String {
+				f.Set(reflect.ValueOf(map[string]string{"--a": badValue}))
+			} else {
+				t.Fatalf("unknown map type for field %q in StyleProperties", v.Type().Field(i).Name)
+			}
 		default:
 			t.Fatalf("unknown %s field %q in StyleProperties", f.Type().Kind(), v.Type().Field(i).Name)
 		}
@@ -408,7 +481,8 @@ func TestStyleFromPropertiesAllFieldsValidated(t *testing.T) {
 		`bottom:zGoSafezInvalidPropertyValue;` +
 		`font-weight:zGoSafezInvalidPropertyValue;` +
 		`padding:zGoSafezInvalidPropertyValue;` +
-		`z-index:zGoSafezInvalidPropertyValue;`
+		`z-index:zGoSafezInvalidPropertyValue;` +
+		`--a:zGoSafezInvalidPropertyValue;`
 	got := StyleFromProperties(style).String()
 	if got != want {
 		t.Errorf("got:\n\t%s\nwant\n\t%s", got, want)
@@ -7,6 +7,7 @@
 package safehtml
 
 import (
+	"bytes"
 	"container/list"
 	"fmt"
 	"regexp"
@@ -38,6 +39,124 @@ func StyleSheetFromConstant(styleSheet stringConstant) StyleSheet {
 	return StyleSheet{string(styleSheet)}
 }
 
+// StyleSheetConcat returns a StyleSheet which contains, in order, the string
+// representations of the given styleSheets.
+func StyleSheetConcat(styleSheets ...StyleSheet) StyleSheet {
+	var b bytes.Buffer
+	for _, styleSheet := range styleSheets {
+		b.WriteString(styleSheet.String())
+	}
+	return StyleSheet{b.String()}
+}
+
+// StyleSheetFormatFromConstant constructs a StyleSheet from a format string,
+// which must be an untyped string constant, and typed arguments.
+//
+// Arguments are specified as a map of labels, which must contain only alphanumeric
+// and '_' runes, to values. Each `%{<label>}` marker in the format string is
+// replaced by the value identified by <label>, which must be one of:
+//   - an Identifier, which is inserted verbatim and is suitable for use as a
+//     class or id name in a selector;
+//   - a URL, which is CSS-escaped and inserted as a CSS <url> of the form
+//     url("value").
+//
+// For example,
+//
+//	StyleSheetFormatFromConstant(`.%{cls}{background-image:%{bg};}`, map[string]interface{}{
+//		"cls": IdentifierFromConstantPrefix(`banner`, id),
+//		"bg":  URLSanitized(imageURL),
+//	})
+//
+// It returns an error if an argument is missing or has an unsupported type, or if
+// an Identifier argument contains runes other than alphanumerics, '-' and '_'.
+// Neither kind of argument can therefore close the style element, end the
+// enclosing rule or start a new at-rule. Arguments that do not match any label
+// in the format string are ignored.
+//
+// No runtime validation or sanitization is performed on format; being under
+// application control, it is simply assumed to comply with the StyleSheet
+// contract.
+func StyleSheetFormatFromConstant(format stringConstant, args map[string]interface{}) (StyleSheet, error) {
+	var err error
+	ret := styleSheetFormatMarkerPattern.ReplaceAllStringFunc(string(format), func(match string) string {
+		argName := match[len("%{") : len(match)-len("}")]
+		arg, ok := args[argName]
+		if !ok {
+			if err == nil {
+				// Report an error for the first missing argument.
+				err = fmt.Errorf("expected argument named %q", argName)
+			}
+			return ""
+		}
+		switch argVal := arg.(type) {
+		case Identifier:
+			if !onlyAlphanumericsOrHyphenPattern.MatchString(argVal.str) {
+				// Identifiers created through unchecked conversions are not guaranteed to be well-formed.
+				if err == nil {
+					err = fmt.Errorf("argument %q with value %q is not a valid identifier", argName, argVal.str)
+				}
+				return ""
+			}
+			return argVal.str
+		case URL:
+			return `url("` + cssEscapeString(argVal.str) + `")`
+		default:
+			if err == nil {
+				err = fmt.Errorf("argument %q has unsupported type %T", argName, arg)
+			}
+			return ""
+		}
+	})
+	if err != nil {
+		return StyleSheet{}, err
+	}
+	return StyleSheet{ret}, nil
+}
+
+// styleSheetFormatMarkerPattern matches markers in StyleSheetFormatFromConstant
+// format strings.
+var styleSheetFormatMarkerPattern = regexp.MustCompile(`%{[[:word:]]+}`)
+
+// StyleSheetFromConstantAndTrustedResourceURLs constructs a StyleSheet from a
+// format string, which must be an untyped string constant, and a list of
+// TrustedResourceURLs.
+//
+// Each `url(%s)` marker in the format string is replaced, in order, by the
+// next element of urls, which is CSS-escaped and inserted as a CSS <url> of the
+// form url("value"). This is typically used to build @import rules, e.g.
+//
+//	StyleSheetFromConstantAndTrustedResourceURLs(`@import url(%s);@import url(%s) print;`, base, print)
+//
+// It returns an error if format contains a %s placeholder that is not the
+// entire argument of a url() token, or if the number of markers does not match
+// the number of urls.
+//
+// No runtime validation or sanitization is performed on format; being under
+// application control, it is simply assumed to comply with the StyleSheet
+// contract.
+func StyleSheetFromConstantAndTrustedResourceURLs(format stringConstant, urls ...TrustedResourceURL) (StyleSheet, error) {
+	parts := strings.Split(string(format), styleSheetURLMarker)
+	for _, part := range parts {
+		if strings.Contains(part, "%s") {
+			return StyleSheet{}, fmt.Errorf("format %q contains a %%s placeholder outside of a %s token", format, styleSheetURLMarker)
+		}
+	}
+	if got, want := len(urls), len(parts)-1; got != want {
+		return StyleSheet{}, fmt.Errorf("format %q expects %d TrustedResourceURLs, got %d", format, want, got)
+	}
+	var b bytes.Buffer
+	b.WriteString(parts[0])
+	for i, u := range urls {
+		b.WriteString(`url("` + cssEscapeString(u.str) + `")`)
+		b.WriteString(parts[i+1])
+	}
+	return StyleSheet{b.String()}, nil
+}
+
+// styleSheetURLMarker is the marker replaced by TrustedResourceURLs in
+// StyleSheetFromConstantAndTrustedResourceURLs format strings.
+const styleSheetURLMarker = "url(%s)"
+
 // CSSRule constructs a StyleSheet containng a CSS rule of the form:
 //
 //	selector{style}
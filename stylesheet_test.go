@@ -103,3 +103,156 @@ func TestCSSRule(t *testing.T) {
 		}
 	}
 }
+
+func TestStyleSheetConcat(t *testing.T) {
+	for _, test := range [...]struct {
+		in   []StyleSheet
+		want string
+	}{
+		{nil, ""},
+		{[]StyleSheet{StyleSheetFromConstant(`a{}`)}, `a{}`},
+		{[]StyleSheet{StyleSheetFromConstant(`a{}`), {}, StyleSheetFromConstant(`b{color:red}`)}, `a{}b{color:red}`},
+	} {
+		if got := StyleSheetConcat(test.in...).String(); got != test.want {
+			t.Errorf("StyleSheetConcat(%v) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestStyleSheetFormatFromConstant(t *testing.T) {
+	for _, test := range [...]struct {
+		desc      string
+		format    stringConstant
+		args      map[string]interface{}
+		want, err string
+	}{
+		{
+			"identifier and URL",
+			`.%{cls}{background-image:%{bg};}`,
+			map[string]interface{}{
+				"cls": IdentifierFromConstantPrefix(`banner`, "top"),
+				"bg":  URLSanitized("https://example.com/a.png"),
+			},
+			`.banner-top{background-image:url("https://example.com/a.png");}`, ``,
+		},
+		{
+			"URL breaking out of string and style element escaped",
+			`a{background:%{bg}}`,
+			map[string]interface{}{"bg": URLSanitized(`/a");}</style><script>`)},
+			`a{background:url("/a\000022);}\00003C/style>\00003Cscript>")}`, ``,
+		},
+		{
+			"unsafe URL sanitized",
+			`a{background:%{bg}}`,
+			map[string]interface{}{"bg": URLSanitized(`javascript:alert(1)`)},
+			`a{background:url("about:invalid#zGoSafez")}`, ``,
+		},
+		{
+			"extra arg ignored",
+			`#%{id}{}`,
+			map[string]interface{}{"id": IdentifierFromConstant(`main`), "unused": 1},
+			`#main{}`, ``,
+		},
+		{
+			"malformed identifier rejected",
+			`.%{cls}{}`,
+			map[string]interface{}{"cls": Identifier{`x}@import "evil.css";.y`}},
+			``, `argument "cls" with value "x}@import \"evil.css\";.y" is not a valid identifier`,
+		},
+		{
+			"string arg rejected",
+			`.%{cls}{}`,
+			map[string]interface{}{"cls": "x{}"},
+			``, `argument "cls" has unsupported type string`,
+		},
+		{
+			"missing arg",
+			`.%{cls}{}`,
+			nil,
+			``, `expected argument named "cls"`,
+		},
+	} {
+		got, err := StyleSheetFormatFromConstant(test.format, test.args)
+		if test.err != "" && err == nil {
+			t.Errorf("%s: expected error, unexpectedly got output: %s", test.desc, got)
+		} else if test.err == "" && err != nil {
+			t.Errorf("%s: unexpected error: %s", test.desc, err)
+		} else if test.err != "" && err.Error() != test.err {
+			t.Errorf("%s: got error:\n\t%s\nwant:\n\t%s", test.desc, err, test.err)
+		} else if test.err == "" && got.String() != test.want {
+			t.Errorf("%s: got:\n\t%s\nwant:\n\t%s", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestStyleSheetFromConstantAndTrustedResourceURLs(t *testing.T) {
+	for _, test := range [...]struct {
+		desc      string
+		format    stringConstant
+		urls      []TrustedResourceURL
+		want, err string
+	}{
+		{
+			"import rules",
+			`@import url(%s);@import url(%s) print;`,
+			[]TrustedResourceURL{
+				TrustedResourceURLFromConstant(`https://example.com/base.css`),
+				TrustedResourceURLFromConstant(`/print.css`),
+			},
+			`@import url("https://example.com/base.css");@import url("/print.css") print;`, ``,
+		},
+		{
+			"URL breaking out of string and style element escaped",
+			`@import url(%s);`,
+			[]TrustedResourceURL{TrustedResourceURL{`/a");}</style><script>`}},
+			`@import url("/a\000022);}\00003C/style>\00003Cscript>");`, ``,
+		},
+		{
+			"no placeholders",
+			`a{color:red}`,
+			nil,
+			`a{color:red}`, ``,
+		},
+		{
+			"placeholder at top level",
+			`@import url(%s);%s`,
+			[]TrustedResourceURL{TrustedResourceURLFromConstant(`/a.css`), TrustedResourceURLFromConstant(`/b.css`)},
+			``, `format "@import url(%s);%s" contains a %s placeholder outside of a url(%s) token`,
+		},
+		{
+			"placeholder inside quoted url",
+			`@import url("%s");`,
+			[]TrustedResourceURL{TrustedResourceURLFromConstant(`/a.css`)},
+			``, `format "@import url(\"%s\");" contains a %s placeholder outside of a url(%s) token`,
+		},
+		{
+			"placeholder as url prefix",
+			`@import url(%s/a.css);`,
+			[]TrustedResourceURL{TrustedResourceURLFromConstant(`/b`)},
+			``, `format "@import url(%s/a.css);" contains a %s placeholder outside of a url(%s) token`,
+		},
+		{
+			"too few URLs",
+			`@import url(%s);@import url(%s);`,
+			[]TrustedResourceURL{TrustedResourceURLFromConstant(`/a.css`)},
+			``, `format "@import url(%s);@import url(%s);" expects 2 TrustedResourceURLs, got 1`,
+		},
+		{
+			"too many URLs",
+			`@import url(%s);`,
+			[]TrustedResourceURL{TrustedResourceURLFromConstant(`/a.css`), TrustedResourceURLFromConstant(`/b.css`)},
+			``, `format "@import url(%s);" expects 1 TrustedResourceURLs, got 2`,
+		},
+	} {
+		got, err := StyleSheetFromConstantAndTrustedResourceURLs(test.format, test.urls...)
+		if test.err != "" && err == nil {
+			t.Errorf("%s: expected error, unexpectedly got output: %s", test.desc, got)
+		} else if test.err == "" && err != nil {
+			t.Errorf("%s: unexpected error: %s", test.desc, err)
+		} else if test.err != "" && err.Error() != test.err {
+			t.Errorf("%s: got error:\n\t%s\nwant:\n\t%s", test.desc, err, test.err)
+		} else if got.String() != test.want {
+			t.Errorf("%s: got:\n\t%s\nwant:\n\t%s", test.desc, got, test.want)
+		}
+	}
+}
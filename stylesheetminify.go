@@ -0,0 +1,218 @@
+// Copyright (c) 2017 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package safehtml
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Minified returns a StyleSheet equivalent to s with comments removed and
+// whitespace collapsed.
+//
+// s is split into tokens as described in
+// https://www.w3.org/TR/css-syntax-3/#tokenization, so that string literals,
+// unquoted url() arguments and escape sequences are copied unchanged, even if
+// they contain "/*" or whitespace. Each run of whitespace and comments
+// containing whitespace is replaced by a single space, which is omitted at the
+// start and end of s, around '{', '}', ';' and ',', after '(' and before ')'.
+// Each other run of comments is removed if the adjacent tokens cannot merge,
+// and is otherwise replaced by the empty comment "/**/", so that, for example,
+// "a/**/b" does not become the single identifier "ab".
+//
+// Minified never joins or splits any other tokens, so the result has the same
+// meaning as s, and fulfills the StyleSheet contract if s does.
+func (s StyleSheet) Minified() StyleSheet {
+	return StyleSheet{minifyCSS(s.str)}
+}
+
+// minifyCSS implements StyleSheet.Minified.
+func minifyCSS(css string) string {
+	var b strings.Builder
+	b.Grow(len(css))
+	for i := 0; i < len(css); {
+		c := css[i]
+		var n int
+		switch {
+		case isCSSWhitespace(c) || strings.HasPrefix(css[i:], "/*"):
+			var hasWhitespace bool
+			n, hasWhitespace = cssWhitespaceAndCommentsLen(css[i:])
+			atEdge := b.Len() == 0 || i+n == len(css)
+			var prev, next byte
+			if !atEdge {
+				prev, next = b.String()[b.Len()-1], css[i+n]
+			}
+			switch {
+			case atEdge:
+				// Omit whitespace and comments at the start and end of css.
+			case hasWhitespace:
+				if strings.IndexByte("{};,(", prev) == -1 && strings.IndexByte("{};,)", next) == -1 {
+					b.WriteByte(' ')
+				}
+			case strings.IndexByte("{}()[];,:", prev) == -1 && strings.IndexByte(`{})[];,:"'`, next) == -1:
+				b.WriteString("/**/")
+			}
+			i += n
+			continue
+		case c == '"' || c == '\'':
+			var endsAtNewline bool
+			n, endsAtNewline = cssStringLen(css[i:])
+			if endsAtNewline {
+				// Keep the newline that ends an unterminated string, since
+				// the string would otherwise extend further.
+				if strings.HasPrefix(css[i+n:], "\r\n") {
+					n++
+				}
+				n++
+			}
+		case c == '\\' || isCSSNameByte(c):
+			var name string
+			n, name = cssNameLen(css[i:])
+			if strings.EqualFold(name, "url") && i+n < len(css) && css[i+n] == '(' {
+				n += cssUnquotedURLLen(css[i+n:])
+			}
+		default:
+			n = 1
+		}
+		b.WriteString(css[i : i+n])
+		i += n
+	}
+	return b.String()
+}
+
+// isCSSWhitespace reports whether c is a CSS whitespace character.
+func isCSSWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// isCSSNameByte reports whether c is an ASCII name code point or part of a
+// non-ASCII name code point, as defined in
+// https://www.w3.org/TR/css-syntax-3/#name-code-point.
+func isCSSNameByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c >= 0x80
+}
+
+// cssWhitespaceAndCommentsLen returns the length of the run of whitespace and
+// comments at the start of css, and whether it contains whitespace. An
+// unterminated comment extends to the end of css.
+func cssWhitespaceAndCommentsLen(css string) (n int, hasWhitespace bool) {
+	for n < len(css) {
+		switch {
+		case isCSSWhitespace(css[n]):
+			hasWhitespace = true
+			n++
+		case strings.HasPrefix(css[n:], "/*"):
+			end := strings.Index(css[n+len("/*"):], "*/")
+			if end == -1 {
+				return len(css), hasWhitespace
+			}
+			n += len("/*") + end + len("*/")
+		default:
+			return n, hasWhitespace
+		}
+	}
+	return n, hasWhitespace
+}
+
+// cssStringLen returns the length of the string token at the start of css,
+// which starts with a quote. The string ends after the matching unescaped
+// quote, or, if it is unterminated, before an unescaped newline, in which case
+// cssStringLen also returns true, or at the end of css.
+func cssStringLen(css string) (int, bool) {
+	quote := css[0]
+	for i := 1; i < len(css); i++ {
+		switch css[i] {
+		case quote:
+			return i + 1, false
+		case '\n', '\r', '\f':
+			return i, true
+		case '\\':
+			// Skip the escaped character, which may be an escaped newline.
+			if strings.HasPrefix(css[i+1:], "\r\n") {
+				i++
+			}
+			i++
+		}
+	}
+	return len(css), false
+}
+
+// cssNameLen returns the length of the run of name code points and escape
+// sequences at the start of css, and its value with escape sequences decoded.
+// If css starts with a '\' that does not start an escape sequence, it returns
+// 1 and the empty string.
+func cssNameLen(css string) (int, string) {
+	var name strings.Builder
+	i := 0
+	for i < len(css) {
+		c := css[i]
+		switch {
+		case isCSSNameByte(c):
+			name.WriteByte(c)
+			i++
+		case c == '\\' && i+1 < len(css) && css[i+1] != '\n' && css[i+1] != '\r' && css[i+1] != '\f':
+			n, r := cssEscapeLen(css[i:])
+			name.WriteRune(r)
+			i += n
+		default:
+			if i == 0 {
+				return 1, ""
+			}
+			return i, name.String()
+		}
+	}
+	return i, name.String()
+}
+
+// cssEscapeLen returns the length and value of the valid escape sequence at the
+// start of css. A hexadecimal escape sequence consists of up to six hex digits
+// followed by an optional whitespace character, "\r\n" counting as one.
+func cssEscapeLen(css string) (int, rune) {
+	i := 1
+	for i < len(css) && i <= 6 && isHex(css[i]) {
+		i++
+	}
+	if i == 1 {
+		r, size := utf8.DecodeRuneInString(css[1:])
+		return 1 + size, r
+	}
+	var r rune
+	for _, c := range []byte(css[1:i]) {
+		r = r<<4 | rune(unhex(c))
+	}
+	switch {
+	case strings.HasPrefix(css[i:], "\r\n"):
+		i += 2
+	case i < len(css) && isCSSWhitespace(css[i]):
+		i++
+	}
+	return i, r
+}
+
+// cssUnquotedURLLen returns the length of the argument of a url() token,
+// including the enclosing parentheses, at the start of css, which starts with
+// '('. If the argument is a quoted string, url( is a function token rather
+// than a url() token, and cssUnquotedURLLen returns 0. An unterminated url()
+// token extends to the end of css.
+func cssUnquotedURLLen(css string) int {
+	i := 1
+	for i < len(css) && isCSSWhitespace(css[i]) {
+		i++
+	}
+	if i < len(css) && (css[i] == '"' || css[i] == '\'') {
+		return 0
+	}
+	for ; i < len(css); i++ {
+		switch css[i] {
+		case ')':
+			return i + 1
+		case '\\':
+			i++
+		}
+	}
+	return len(css)
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2017 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package safehtml
+
+import (
+	"testing"
+)
+
+func TestStyleSheetMinified(t *testing.T) {
+	for _, test := range [...]struct {
+		desc, in, want string
+	}{
+		{"empty", "", ""},
+		{"only whitespace and comments", " \n/* a */\t", ""},
+		{"rule", "a {\n  color: red;\n  margin: 0 1px;\n}\n", "a{color: red;margin: 0 1px;}"},
+		{"selector list", "a ,\n b > c , d\t~ e {}", "a,b > c,d ~ e{}"},
+		{"descendant combinator", "a :hover, .x .y {}", "a :hover,.x .y{}"},
+		{"comments", "/* header */\na { /* x */ color: /* y */red /* z */; }/**/", "a{color: red;}"},
+		{"comment between compound selectors", "a/* x */.b, a/**/b, #x/**/[y] {}", "a/**/.b,a/**/b,#x[y]{}"},
+		{"comment next to punctuation", "a{color:/* x */red;/* y */}", "a{color:red;}"},
+		{"comment in number", "a{width:1/**/px}", "a{width:1/**/px}"},
+		{"comment before parenthesis", "@media screen and/**/(min-width:1px){}", "@media screen and/**/(min-width:1px){}"},
+		{"whitespace before parenthesis", "@media screen and   (min-width: 1px) { a { b: c } }", "@media screen and (min-width: 1px){a{b: c}}"},
+		{"whitespace in parentheses", "a:not( .b ) { width: calc( 1px + 2px ) }", "a:not(.b){width: calc(1px + 2px)}"},
+		{"unterminated comment", "a{b:c} /* d", "a{b:c}"},
+		{"double-quoted string", `a::before { content: "  /* not a comment */  " }`, `a::before{content: "  /* not a comment */  "}`},
+		{"single-quoted string", `a::before { content: 'it\'s /* x */ "' }`, `a::before{content: 'it\'s /* x */ "'}`},
+		{"escaped newline in string", "a{content:\"a\\\n  b\"}", "a{content:\"a\\\n  b\"}"},
+		{"unterminated string", "a{content:\"a  \n  b}", "a{content:\"a  \n b}"},
+		{"unterminated string with CRLF", "a{content:'a\r\n  b}", "a{content:'a\r\n b}"},
+		{"unquoted url", "a { background: url( /a/*b*/c.png ) }", "a{background: url( /a/*b*/c.png )}"},
+		{"unquoted url with escapes", `a{background:URL(/a\)/*b*/c.png)}`, `a{background:URL(/a\)/*b*/c.png)}`},
+		{"escaped url", `a{background:\75 rl(/a/*b*/) }`, `a{background:\75 rl(/a/*b*/)}`},
+		{"quoted url", "a { background: url( \"/a/*b*/c.png\" ) }", "a{background: url(\"/a/*b*/c.png\")}"},
+		{"url function that is not url()", "a{b:xurl(1 /* c */)}", "a{b:xurl(1)}"},
+		{"unterminated url", "a{b:url(c /* d", "a{b:url(c /* d"},
+		{"hex escape with whitespace", `.\31 0 { color: red }`, `.\31 0{color: red}`},
+		{"hex escape with CRLF", ".\\31\r\n0 {}", ".\\31\r\n0{}"},
+		{"escape followed by comment", `.a\\/*x*/b{}`, `.a\\/**/b{}`},
+		{"escaped comment start", `.a\/*{}`, `.a\/*{}`},
+		{"invalid escape", "a \\\n b{}", "a \\ b{}"},
+		{"important", "a { color: red !important ; }", "a{color: red !important;}"},
+		{"at-rules", "@import url(a.css) print ;\n@font-face { font-family: x }", "@import url(a.css) print;@font-face{font-family: x}"},
+		{"CDO and CDC", "<!-- a{} -->", "<!-- a{}-->"},
+		{"style end tag split by comment", "a{}</*x*/style", "a{}</**/style"},
+		{"style end tag split by whitespace", "a{}< /style", "a{}< /style"},
+		{"non-ASCII", "é /* x */ ü {}", "é ü{}"},
+	} {
+		if got := (StyleSheet{test.in}).Minified().String(); got != test.want {
+			t.Errorf("%s: Minified(%q) = %q, want %q", test.desc, test.in, got, test.want)
+		}
+	}
+}
+
+func TestStyleSheetMinifiedIdempotent(t *testing.T) {
+	for _, in := range [...]string{
+		"a/**/b",
+		"a /* x */ b",
+		`a{b:"/* c */"}`,
+		"a{b:url(/*c*/)}",
+		`.\31 0 /**/ x`,
+	} {
+		once := (StyleSheet{in}).Minified()
+		if twice := once.Minified(); twice != once {
+			t.Errorf("Minified(%q) = %q, but minifying that again gives %q", in, once, twice)
+		}
+	}
+}
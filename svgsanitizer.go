@@ -0,0 +1,291 @@
+// Copyright (c) 2017 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package safehtml
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const (
+	svgNamespace   = "http://www.w3.org/2000/svg"
+	xlinkNamespace = "http://www.w3.org/1999/xlink"
+)
+
+// svgMIMEType is the MIME type of data URLs sanitized by sanitizeBase64SVG.
+const svgMIMEType = "image/svg+xml"
+
+// sanitizeBase64SVG returns a base64 data URL containing the SVG document
+// encoded in payload after sanitizing it with sanitizeSVG. It returns an error
+// if payload is not valid base64 or cannot be sanitized.
+func sanitizeBase64SVG(payload string) (string, error) {
+	src, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", err
+	}
+	svg, err := sanitizeSVG(src)
+	if err != nil {
+		return "", err
+	}
+	return "data:" + svgMIMEType + ";base64," + base64.StdEncoding.EncodeToString(svg), nil
+}
+
+// sanitizeSVG returns the SVG document in src with all elements and attributes
+// that are not in svgElements or svgAttributes removed. In particular, the
+// result contains no script or foreignObject elements, no event handler or
+// style attributes, and no references to resources outside the document.
+//
+// Comments, processing instructions and directives, including any DOCTYPE,
+// are also removed. It returns an error if src is not well-formed XML or if
+// its root element is not an svg element in the SVG namespace.
+func sanitizeSVG(src []byte) ([]byte, error) {
+	d := xml.NewDecoder(bytes.NewReader(src))
+	var b bytes.Buffer
+	depth := 0
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if depth == 0 && b.Len() > 0 {
+				return nil, errors.New("SVG document has more than one root element")
+			}
+			if depth == 0 && (tok.Name.Space != svgNamespace || tok.Name.Local != "svg") {
+				return nil, errors.New("root element is not an svg element in the SVG namespace")
+			}
+			if tok.Name.Space != svgNamespace || !svgElements[tok.Name.Local] {
+				// Drop the element and everything it contains.
+				if err := d.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			b.WriteString("<" + tok.Name.Local)
+			if depth == 0 {
+				b.WriteString(` xmlns="` + svgNamespace + `"`)
+			}
+			for _, attr := range tok.Attr {
+				if name, ok := sanitizeSVGAttribute(tok.Name.Local, attr); ok {
+					b.WriteString(" " + name + `="`)
+					xml.EscapeText(&b, []byte(attr.Value))
+					b.WriteString(`"`)
+				}
+			}
+			b.WriteString(">")
+			depth++
+		case xml.EndElement:
+			b.WriteString("</" + tok.Name.Local + ">")
+			depth--
+		case xml.CharData:
+			if depth > 0 {
+				xml.EscapeText(&b, tok)
+			}
+		}
+	}
+	if b.Len() == 0 {
+		return nil, errors.New("SVG document has no root element")
+	}
+	return b.Bytes(), nil
+}
+
+// sanitizeSVGAttribute returns the name under which attr of an element with the
+// given local name should be written to a sanitized SVG document, or false if
+// attr should be dropped.
+func sanitizeSVGAttribute(element string, attr xml.Attr) (string, bool) {
+	switch {
+	case attr.Name.Space == "" && svgAttributes[attr.Name.Local]:
+		if svgExternalReferencePattern.MatchString(attr.Value) {
+			return "", false
+		}
+		return attr.Name.Local, true
+	case attr.Name.Local == "href" && (attr.Name.Space == "" || attr.Name.Space == xlinkNamespace):
+		// Only allow references to elements within the document, and write
+		// xlink:href as href, so that the xlink namespace need not be declared.
+		if !svgHrefElements[element] || !strings.HasPrefix(attr.Value, "#") {
+			return "", false
+		}
+		return "href", true
+	}
+	return "", false
+}
+
+// svgExternalReferencePattern matches attribute values containing CSS url()
+// functions that do not reference an element within the document.
+var svgExternalReferencePattern = regexp.MustCompile(`(?i)url\(\s*['"]?\s*(?:[^\s'"#]|$)`)
+
+// svgElements contains the local names of SVG elements kept by sanitizeSVG.
+// It excludes elements that run scripts, such as script and the animation
+// elements, which can modify href attributes; that embed other content, such as
+// foreignObject, image and feImage; and that contain stylesheets.
+var svgElements = map[string]bool{
+	"circle":              true,
+	"clipPath":            true,
+	"defs":                true,
+	"desc":                true,
+	"ellipse":             true,
+	"feBlend":             true,
+	"feColorMatrix":       true,
+	"feComponentTransfer": true,
+	"feComposite":         true,
+	"feDropShadow":        true,
+	"feFlood":             true,
+	"feFuncA":             true,
+	"feFuncB":             true,
+	"feFuncG":             true,
+	"feFuncR":             true,
+	"feGaussianBlur":      true,
+	"feMerge":             true,
+	"feMergeNode":         true,
+	"feMorphology":        true,
+	"feOffset":            true,
+	"filter":              true,
+	"g":                   true,
+	"line":                true,
+	"linearGradient":      true,
+	"marker":              true,
+	"mask":                true,
+	"path":                true,
+	"pattern":             true,
+	"polygon":             true,
+	"polyline":            true,
+	"radialGradient":      true,
+	"rect":                true,
+	"stop":                true,
+	"svg":                 true,
+	"symbol":              true,
+	"text":                true,
+	"textPath":            true,
+	"title":               true,
+	"tspan":               true,
+	"use":                 true,
+}
+
+// svgHrefElements contains the local names of SVG elements whose href attribute
+// is kept by sanitizeSVG, provided that it references an element within the
+// document.
+var svgHrefElements = map[string]bool{
+	"linearGradient": true,
+	"pattern":        true,
+	"radialGradient": true,
+	"textPath":       true,
+	"use":            true,
+}
+
+// svgAttributes contains the names of unqualified SVG attributes kept by
+// sanitizeSVG. It excludes event handler attributes, href, which is handled
+// separately, and style.
+var svgAttributes = map[string]bool{
+	"aria-label":          true,
+	"class":               true,
+	"clip-path":           true,
+	"clip-rule":           true,
+	"clipPathUnits":       true,
+	"color":               true,
+	"cx":                  true,
+	"cy":                  true,
+	"d":                   true,
+	"display":             true,
+	"dominant-baseline":   true,
+	"dx":                  true,
+	"dy":                  true,
+	"fill":                true,
+	"fill-opacity":        true,
+	"fill-rule":           true,
+	"filter":              true,
+	"filterUnits":         true,
+	"flood-color":         true,
+	"flood-opacity":       true,
+	"font-family":         true,
+	"font-size":           true,
+	"font-style":          true,
+	"font-weight":         true,
+	"fr":                  true,
+	"fx":                  true,
+	"fy":                  true,
+	"gradientTransform":   true,
+	"gradientUnits":       true,
+	"height":              true,
+	"id":                  true,
+	"in":                  true,
+	"in2":                 true,
+	"k1":                  true,
+	"k2":                  true,
+	"k3":                  true,
+	"k4":                  true,
+	"lengthAdjust":        true,
+	"letter-spacing":      true,
+	"marker-end":          true,
+	"marker-mid":          true,
+	"marker-start":        true,
+	"markerHeight":        true,
+	"markerUnits":         true,
+	"markerWidth":         true,
+	"mask":                true,
+	"maskContentUnits":    true,
+	"maskUnits":           true,
+	"mode":                true,
+	"offset":              true,
+	"opacity":             true,
+	"operator":            true,
+	"orient":              true,
+	"patternContentUnits": true,
+	"patternTransform":    true,
+	"patternUnits":        true,
+	"points":              true,
+	"preserveAspectRatio": true,
+	"primitiveUnits":      true,
+	"r":                   true,
+	"radius":              true,
+	"refX":                true,
+	"refY":                true,
+	"result":              true,
+	"role":                true,
+	"rotate":              true,
+	"rx":                  true,
+	"ry":                  true,
+	"shape-rendering":     true,
+	"spreadMethod":        true,
+	"startOffset":         true,
+	"stdDeviation":        true,
+	"stop-color":          true,
+	"stop-opacity":        true,
+	"stroke":              true,
+	"stroke-dasharray":    true,
+	"stroke-dashoffset":   true,
+	"stroke-linecap":      true,
+	"stroke-linejoin":     true,
+	"stroke-miterlimit":   true,
+	"stroke-opacity":      true,
+	"stroke-width":        true,
+	"text-anchor":         true,
+	"textLength":          true,
+	"transform":           true,
+	"type":                true,
+	"values":              true,
+	"vector-effect":       true,
+	"version":             true,
+	"viewBox":             true,
+	"visibility":          true,
+	"width":               true,
+	"word-spacing":        true,
+	"x":                   true,
+	"x1":                  true,
+	"x2":                  true,
+	"y":                   true,
+	"y1":                  true,
+	"y2":                  true,
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2017 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package safehtml
+
+import (
+	"testing"
+)
+
+func TestSanitizeSVG(t *testing.T) {
+	const svgOpen = `<svg xmlns="http://www.w3.org/2000/svg">`
+	for _, test := range [...]struct {
+		desc, in, want string
+	}{
+		{
+			"shapes",
+			`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><rect x="1" y="1" width="8" height="8" fill="red"/></svg>`,
+			`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><rect x="1" y="1" width="8" height="8" fill="red"></rect></svg>`,
+		},
+		{
+			"script",
+			svgOpen + `<script>alert(1)</script><circle r="1"/></svg>`,
+			svgOpen + `<circle r="1"></circle></svg>`,
+		},
+		{
+			"CDATA script",
+			svgOpen + `<script><![CDATA[alert(1)]]></script></svg>`,
+			svgOpen + `</svg>`,
+		},
+		{
+			"event handlers",
+			`<svg xmlns="http://www.w3.org/2000/svg" onload="alert(1)"><g onclick="alert(1)" ONMOUSEOVER="alert(1)" fill="blue"/></svg>`,
+			svgOpen + `<g fill="blue"></g></svg>`,
+		},
+		{
+			"foreignObject",
+			svgOpen + `<foreignObject><body xmlns="http://www.w3.org/1999/xhtml"><script>alert(1)</script></body></foreignObject></svg>`,
+			svgOpen + `</svg>`,
+		},
+		{
+			"animation",
+			svgOpen + `<a><animate attributeName="href" to="javascript:alert(1)"/></a><set attributeName="onload" to="alert(1)"/></svg>`,
+			svgOpen + `</svg>`,
+		},
+		{
+			"style",
+			svgOpen + `<style>rect { fill: url(https://evil.com/) }</style><rect style="fill: red"/></svg>`,
+			svgOpen + `<rect></rect></svg>`,
+		},
+		{
+			"local references",
+			`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink"><use xlink:href="#a"/><use href="#b"/><rect fill="url(#g)" filter="url( '#f' )"/></svg>`,
+			svgOpen + `<use href="#a"></use><use href="#b"></use><rect fill="url(#g)" filter="url( &#39;#f&#39; )"></rect></svg>`,
+		},
+		{
+			"external references",
+			`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink"><use xlink:href="https://evil.com/a.svg#a"/><use href="javascript:alert(1)"/><g href="#a"/><rect fill="URL(https://evil.com/)" stroke="url('x.svg#a')"/><image href="https://evil.com/"/></svg>`,
+			svgOpen + `<use></use><use></use><g></g><rect></rect></svg>`,
+		},
+		{
+			"other namespaces",
+			`<svg xmlns="http://www.w3.org/2000/svg" xmlns:h="http://www.w3.org/1999/xhtml" xmlns:e="urn:evil"><h:script>alert(1)</h:script><rect e:onload="alert(1)" h:class="a"/></svg>`,
+			svgOpen + `<rect></rect></svg>`,
+		},
+		{
+			"text is escaped",
+			svgOpen + `<text>&lt;script&gt;alert(1)&lt;/script&gt; &amp; "x"</text></svg>`,
+			svgOpen + `<text>&lt;script&gt;alert(1)&lt;/script&gt; &amp; &#34;x&#34;</text></svg>`,
+		},
+		{
+			"attribute values are escaped",
+			svgOpen + `<text font-family="&quot;&gt;&lt;script&gt;">a</text></svg>`,
+			svgOpen + `<text font-family="&#34;&gt;&lt;script&gt;">a</text></svg>`,
+		},
+		{
+			"comments, processing instructions and directives",
+			`<!DOCTYPE svg><!-- a --><svg xmlns="http://www.w3.org/2000/svg"><?pi x?><!-- <script>alert(1)</script> --></svg>`,
+			svgOpen + `</svg>`,
+		},
+	} {
+		got, err := sanitizeSVG([]byte(test.in))
+		if err != nil {
+			t.Errorf("%s: sanitizeSVG(%q) failed: %v", test.desc, test.in, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("%s: sanitizeSVG(%q) = %q, want %q", test.desc, test.in, got, test.want)
+		}
+		// Sanitizing is idempotent, so that sanitized SVG data URLs are
+		// accepted unchanged.
+		if again, err := sanitizeSVG(got); err != nil || string(again) != string(got) {
+			t.Errorf("%s: sanitizeSVG(%q) = %q, %v, want it unchanged", test.desc, got, again, err)
+		}
+	}
+}
+
+func TestSanitizeSVGErrors(t *testing.T) {
+	for _, in := range [...]string{
+		``,
+		`not xml`,
+		`<svg xmlns="http://www.w3.org/2000/svg">`,
+		`<svg xmlns="http://www.w3.org/2000/svg"></g>`,
+		`<svg><rect/></svg>`,
+		`<html xmlns="http://www.w3.org/2000/svg"></html>`,
+		`<svg xmlns="http://www.w3.org/2000/svg"/><svg xmlns="http://www.w3.org/2000/svg"/>`,
+		`<!DOCTYPE svg [<!ENTITY x "<script>alert(1)</script>">]><svg xmlns="http://www.w3.org/2000/svg">&x;</svg>`,
+	} {
+		if got, err := sanitizeSVG([]byte(in)); err == nil {
+			t.Errorf("sanitizeSVG(%q) = %q, want error", in, got)
+		}
+	}
+}
@@ -222,3 +222,68 @@ func TestCloneRedefinedName(t *testing.T) {
 		}
 	}
 }
+
+func TestCloneIsolatesURLSchemes(t *testing.T) {
+	const text = `<a href="{{ .A }}"></a><a href="{{ .B }}"></a>`
+	data := map[string]string{"A": "chrome://settings", "B": "tel:+1-555-0100"}
+	base := Must(New("base").AllowURLSchemes("chrome").Parse(text))
+	tenant1 := Must(base.Clone())
+	tenant2 := Must(base.Clone())
+	// Extending the allowlist of one clone must not affect the others.
+	tenant1.AllowURLSchemes("tel")
+	for _, test := range [...]struct {
+		desc string
+		tmpl *Template
+		want string
+	}{
+		{"base", base, `<a href="chrome://settings"></a><a href="about:invalid#zGoSafez"></a>`},
+		{"tenant1", tenant1, `<a href="chrome://settings"></a><a href="tel:+1-555-0100"></a>`},
+		{"tenant2", tenant2, `<a href="chrome://settings"></a><a href="about:invalid#zGoSafez"></a>`},
+	} {
+		b := new(strings.Builder)
+		if err := test.tmpl.Execute(b, data); err != nil {
+			t.Errorf("%s: unexpected error: %v", test.desc, err)
+			continue
+		}
+		if got := b.String(); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.desc, got, test.want)
+		}
+	}
+	if base.nameSpace.urlSanitizerConfig == tenant2.nameSpace.urlSanitizerConfig {
+		t.Errorf("clone shares the URL sanitizer config of the original")
+	}
+}
+
+func TestCloneIsolatesFuncs(t *testing.T) {
+	base := Must(New("base").Funcs(FuncMap{"tenant": func() string { return "base" }}).Parse(`{{ tenant }}`))
+	clone := Must(base.Clone())
+	clone.Funcs(FuncMap{"tenant": func() string { return "clone" }})
+	for _, test := range [...]struct {
+		tmpl *Template
+		want string
+	}{
+		{base, "base"},
+		{clone, "clone"},
+	} {
+		b := new(strings.Builder)
+		if err := test.tmpl.Execute(b, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got := b.String(); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.tmpl.Name(), got, test.want)
+		}
+	}
+}
+
+func TestCloneCopiesOptions(t *testing.T) {
+	base := Must(New("base").CSPCompatible().Parse(`<a href="javascript:f()">{{ . }}</a>`))
+	clone := Must(base.Clone())
+	if err := clone.Execute(ioutil.Discard, nil); err == nil || !strings.Contains(err.Error(), "javascript:") {
+		t.Errorf("got error %v, want CSP compatibility error", err)
+	}
+	base = Must(New("base").Option("urlmissingkey=error").Parse(`<a href="{{ . }}"></a>`))
+	clone = Must(base.Clone())
+	if err := clone.Execute(ioutil.Discard, ""); err == nil || !strings.Contains(err.Error(), "empty value for URL") {
+		t.Errorf("got error %v, want empty URL error", err)
+	}
+}
@@ -36,6 +36,26 @@ type context struct {
 	// the rel attribute has not already been parsed in the current element, or if the
 	// value of the rel attribute cannot be determined at parse time.
 	linkRel string
+	// metaHTTPEquiv is the lowercase value of the "http-equiv" attribute inside the
+	// current "meta" element, with leading and trailing whitespace removed.
+	// This field will be empty if the parser is currently not in a meta element,
+	// the http-equiv attribute has not already been parsed in the current element,
+	// or if the value of the http-equiv attribute cannot be determined at parse time.
+	metaHTTPEquiv string
+	// nonceAttr is true if the parser is in a start tag that has a nonce
+	// attribute, and is false outside start tags.
+	nonceAttr bool
+	// jsonString is true if the parser is inside a string literal in the body of
+	// a script element whose type indicates that it contains JSON data (see
+	// jsonScriptTypes).
+	jsonString bool
+	// svg is "svg" if the parser is in SVG content, that is, inside an svg
+	// element, and is the lowercase name of the SVG element, such as
+	// "foreignobject", if the parser is in HTML content inside an HTML
+	// integration point in SVG content (see
+	// https://html.spec.whatwg.org/multipage/parsing.html#html-integration-point).
+	// This field is empty outside SVG elements.
+	svg string
 }
 
 // eq returns whether Context c is equal to Context d.
@@ -46,7 +66,11 @@ func (c context) eq(d context) bool {
 		c.attr.eq(d.attr) &&
 		c.err == d.err &&
 		c.scriptType == d.scriptType &&
-		c.linkRel == d.linkRel
+		c.linkRel == d.linkRel &&
+		c.metaHTTPEquiv == d.metaHTTPEquiv &&
+		c.nonceAttr == d.nonceAttr &&
+		c.jsonString == d.jsonString &&
+		c.svg == d.svg
 }
 
 // state describes a high-level HTML parser state.
@@ -154,8 +154,7 @@ The following table describes these sanitization contexts.
 	|--------------------+----------------------------------+------------------------------+-----------------------+
 	| HTMLContent        | Hello {{.}}                      | safehtml.HTML                | safehtml.HTMLEscaped  |
 	|                    | <title>{{.}}</title>             |                              |                       |
-	+--------------------------------------------------------------------------------------------------------------+
-	| HTMLValOnly        | <iframe srcdoc="{{.}}"></iframe> | safehtml.HTML*               | N/A                   |
+	|                    | <iframe srcdoc="{{.}}"></iframe> |                              |                       |
 	+--------------------------------------------------------------------------------------------------------------+
 	| URL                | <q cite="{{.}}">Cite</q>         | safehtml.URL                 | safehtml.URLSanitized |
 	+--------------------------------------------------------------------------------------------------------------+
@@ -163,8 +162,16 @@ The following table describes these sanitization contexts.
 	| TrustedResourceURL |                                  | safehtml.TrustedResourceURL  |                       |
 	+--------------------------------------------------------------------------------------------------------------+
 	| TrustedResourceURL | <script src="{{.}}"></script>    | safehtml.TrustedResourceURL† | N/A                   |
+	|                    | <base href="{{.}}">              |                              |                       |
 	+--------------------------------------------------------------------------------------------------------------+
 	| Script             | <script>{{.}}</script>           | safehtml.Script*             | N/A                   |
+	|                    | <p onclick="{{.}}">Text</p>      |                              |                       |
+	+--------------------------------------------------------------------------------------------------------------+
+	| JSON               | <script type="application/json"> | N/A (any type allowed)       | encoding/json.Marshal |
+	|                    | {{.}}</script>                   |                              |                       |
+	+--------------------------------------------------------------------------------------------------------------+
+	| JSONString         | <script type="application/json"> | N/A (any type allowed)       | encoding/json.Marshal |
+	|                    | {"a": "{{.}}"}</script>          |                              | of the string form    |
 	+--------------------------------------------------------------------------------------------------------------+
 	| Style              | <p style="{{.}}">Paragraph</p>   | safehtml.Style*              | N/A                   |
 	+--------------------------------------------------------------------------------------------------------------+
@@ -216,6 +223,30 @@ this behavior is in TrustedResourceURL sanitization contexts, where actions may
 output data of any type if the action occurs after a safe attribute value prefix.
 More details can be found below in "Substitutions in URLs".
 
+# Inline SVG
+
+Inside an svg element, the HTML parser parses SVG content rather than HTML, so
+the autosanitizer applies different rules:
+  - Actions in element content are HTML-escaped text, even if they output
+    safehtml.HTML values. Actions must not occur in script or style elements.
+  - The href and xlink:href attributes of a, image, use, pattern, textPath
+    and gradient elements are URL or TrustedResourceURL sanitization contexts.
+    Event handler attributes are Script contexts, style is a Style context,
+    and a fixed set of presentation attributes such as fill and viewBox have
+    no sanitization context. Actions in other attributes are disallowed.
+  - The content of foreignObject, desc and title elements is HTML content,
+    sanitized as elsewhere in the template.
+
+For example, in
+
+	<svg><use xlink:href="{{ .Ref }}"/><foreignObject><p>{{ .Text }}</p></foreignObject></svg>
+
+a Ref of "javascript:evil()" is replaced by "about:invalid#zGoSafez", and Text is
+HTML-escaped. To keep the autosanitizer's view of the document consistent with
+browsers, templates must not nest svg elements, contain CDATA sections in SVG
+content, or contain HTML elements such as p or div that would end the SVG
+content.
+
 # Unconditional sanitization
 
 In attribute value contexts, action outputs are always HTML-escaped after
@@ -238,6 +269,20 @@ remains unchanged after _sanitizeTrustedResourceURLOrURL, X will still be
 URL-normalized and HTML-escaped. Likewise, Y will still be HTML-escaped even if
 its string form is left unchanged by _sanitizeIdentifier.
 
+The srcdoc attribute of an iframe element contains an HTML document, so action
+outputs in it are sanitized as HTML content before being HTML-escaped for the
+attribute value. For example, in
+
+	<iframe srcdoc="{{ .Doc }}"></iframe>
+
+a string Doc is escaped twice, and is displayed as text in the embedded
+document, while a safehtml.HTML Doc is escaped once, and is parsed as markup in
+the embedded document.
+
+Since the escaper does not track contexts inside the embedded document,
+actions must be the whole srcdoc attribute value; an action that follows
+literal text in the value is an error.
+
 # Substitutions in URLs
 
 Values of any type may be substituted into attribute values in URL and
@@ -247,7 +292,12 @@ safe URL prefix. For example, in
 	<q cite="http://www.foo.com/{{ .PathComponent }}">foo</q>
 
 Since "http://www.foo.com/" is a safe URL prefix, PathComponent can safely be
-interpolated into this URL sanitization context after URL normalization.
+interpolated into this URL sanitization context. Since the substitution occurs
+in the path of the URL, it is escaped as a single path segment: '/', '?', '#'
+and other characters that cannot appear unencoded in a path segment are
+percent-encoded, so that a PathComponent such as "a/b?c" cannot change the
+structure of the URL. Substitutions into the authority, as in "https://{{ .Host }}",
+or into opaque URLs, as in "mailto:{{ .Address }}", are URL-normalized instead.
 Similarly, in
 
 	<script src="https://www.bar.com/{{ .PathComponent }}"></script>
@@ -262,6 +312,42 @@ fragment part of the URL, such as in:
 
 	<a href="/foo?q={{ .Query }}&hl={{ .LangCode }}">Link</a>
 
+To add several query parameters at once, use the urlWithParams builtin, which
+takes a base URL (a safehtml.URL or a string, which is sanitized) and a
+map[string]string or url.Values, and returns a safehtml.URL with the map
+entries added as percent-encoded query parameters:
+
+	<a href="{{ urlWithParams "/foo" .QueryParams }}">Link</a>
+
+To link to a URL that may be external, use the anchorOpenTag builtin, which
+takes a URL (a safehtml.URL or a string, which is sanitized) and the origin of
+the current page, and returns a safehtml.HTML <a> start tag. If the URL may
+navigate away from the given origin, the tag has rel="noopener noreferrer":
+
+	{{ anchorOpenTag .Link "https://example.com" }}Link</a>
+
+To build a srcset attribute value from several images, use the srcset builtin,
+which takes pairs of a safehtml.TrustedResourceURL and a width or pixel density
+descriptor such as "100w" or "2x", and returns a safehtml.URLSet. Execution
+fails if a descriptor is malformed or repeated:
+
+	<img srcset="{{ srcset .Small "1x" .Large "2x" }}">
+
+The content attribute of a meta refresh is a URL sanitization context after its
+delay and separator, provided that the http-equiv attribute precedes it. Actions
+elsewhere in the attribute value, or in a quoted URL, are disallowed:
+
+	<meta http-equiv="refresh" content="0; url={{ .Next }}">
+
+An action that begins the argument of a CSS url() function in a style attribute
+value is a URL sanitization context rather than a Style context, provided that
+the url( is not within a CSS comment or string. Its output is sanitized as a
+whole URL, then URL-normalized, which percent-encodes quotes, parentheses,
+whitespace and backslashes so that the URL cannot end the url() function, and
+finally HTML-escaped:
+
+	<div style="background: url({{ .Image }})"></div>
+
 A URL prefix is considered safe in a URL sanitization context if it does
 not end in an incomplete HTML character reference (e.g. https&#1) or incomplete
 percent-encoding character triplet (e.g. /fo%6), does not contain whitespace or control
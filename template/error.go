@@ -6,6 +6,8 @@ package template
 
 import (
 	"fmt"
+	"regexp"
+	"strconv"
 	"text/template/parse"
 )
 
@@ -281,3 +283,70 @@ func (e *Error) Error() string {
 func errorf(k ErrorCode, node parse.Node, line int, f string, args ...interface{}) *Error {
 	return &Error{k, node, "", line, fmt.Sprintf(f, args...)}
 }
+
+// ParseError describes a syntax error encountered while parsing a template.
+// It is returned by Parse and, wrapped with the name of the file, by ParseFiles,
+// ParseGlob and ParseFS, so callers can use errors.As to retrieve it.
+type ParseError struct {
+	// Template is the name of the template in which the error was encountered.
+	Template string
+	// Line is the line number of the error in the template source or 0.
+	Line int
+	// Column is the column of the error in Line or 0. The text/template parser
+	// currently reports only line numbers, so Column is 0 unless a column is
+	// present in the parser's error message.
+	Column int
+	// Description is a human-readable description of the problem.
+	Description string
+	// err is the error returned by the parser.
+	err error
+}
+
+// Error returns the message of the error returned by the parser.
+func (e *ParseError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error returned by the parser.
+func (e *ParseError) Unwrap() error {
+	return e.err
+}
+
+// StreamError describes an error returned by ExecuteStream, along with the
+// number of bytes of output written before the error occurred, so that callers
+// can tell whether a partial response has already been sent.
+type StreamError struct {
+	// Written is the number of bytes written to the output writer before the
+	// error occurred. It is 0 if the template could not be escaped, since
+	// escaping errors are reported before execution starts.
+	Written int64
+	// Err is the error that stopped execution.
+	Err error
+}
+
+// Error returns the message of e.Err and the number of bytes written.
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("%v (after writing %d bytes of output)", e.Err, e.Written)
+}
+
+// Unwrap returns e.Err.
+func (e *StreamError) Unwrap() error {
+	return e.Err
+}
+
+// parseErrorPattern matches the messages of errors returned by the
+// text/template parser, which have the form "template: name:line: description",
+// and may have a column after the line.
+var parseErrorPattern = regexp.MustCompile(`(?s)^template: (.*?):([0-9]+):(?:([0-9]+):)? (.*)$`)
+
+// newParseError returns a *ParseError for err, which was returned when parsing
+// the template with the given name.
+func newParseError(name string, err error) *ParseError {
+	e := &ParseError{Template: name, Description: err.Error(), err: err}
+	if m := parseErrorPattern.FindStringSubmatch(err.Error()); m != nil {
+		e.Template, e.Description = m[1], m[4]
+		e.Line, _ = strconv.Atoi(m[2])
+		e.Column, _ = strconv.Atoi(m[3])
+	}
+	return e
+}
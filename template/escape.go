@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"html"
 	"reflect"
+	"strconv"
 	"strings"
 	"text/template"
 	"text/template/parse"
@@ -81,6 +82,21 @@ type escaper struct {
 	actionNodeEdits   map[*parse.ActionNode][]string
 	templateNodeEdits map[*parse.TemplateNode]string
 	textNodeEdits     map[*parse.TextNode][]byte
+	// actionInsertions[n] holds the actions inserted into the edited text
+	// of n, such as those that output the nonce attribute of a script or
+	// style start tag, in increasing order of offset.
+	actionInsertions map[*parse.TextNode][]actionInsertion
+	// hooked[templateName] is set once a copy of the named template with
+	// stream flush points and context checks has been added to the execution
+	// set of the namespace, which happens the first time it is committed.
+	hooked map[string]bool
+}
+
+// An actionInsertion is an action inserted at an offset in the edited text of
+// a text node.
+type actionInsertion struct {
+	offset int
+	action *parse.ActionNode
 }
 
 // makeEscaper creates a blank escaper for the given set.
@@ -93,6 +109,8 @@ func makeEscaper(n *nameSpace) escaper {
 		map[*parse.ActionNode][]string{},
 		map[*parse.TemplateNode]string{},
 		map[*parse.TextNode][]byte{},
+		map[*parse.TextNode][]actionInsertion{},
+		map[string]bool{},
 	}
 }
 
@@ -155,7 +173,7 @@ func (e *escaper) escapeAction(c context, n *parse.ActionNode) context {
 		c.state = stateAttrName
 	}
 	// TODO: integrate sanitizerForContext into escapeAction.
-	s, err := sanitizerForContext(c)
+	s, err := sanitizerForContext(c, e.ns.urlSanitizer())
 	if err != nil {
 		return context{
 			state: stateError,
@@ -163,6 +181,14 @@ func (e *escaper) escapeAction(c context, n *parse.ActionNode) context {
 			err: errorf(ErrEscapeAction, n, n.Line, "cannot escape action %v: %s", n, err),
 		}
 	}
+	if e.ns.urlMissingKeyError && len(s) > 0 && urlValueSanitizers[s[0]] {
+		s = append([]string{requireURLValueFuncName}, s...)
+	}
+	if last := len(s) - 1; isSubresourceURLAttr(c) && last >= 0 && s[last] == sanitizeHTMLFuncName {
+		// Record the sanitized value before it is HTML-escaped, so that
+		// subresourceIntegrityAttr can match the URL in the start tag.
+		s = append(s[:last:last], subresourceURLFuncName, sanitizeHTMLFuncName)
+	}
 	e.editActionNode(n, s)
 	return c
 }
@@ -245,6 +271,9 @@ var equivEscapers = map[string]string{
 	// way around. We keep this entry around to preserve the behavior of templates
 	// written before Go 1.9, which might depend on this substitution taking place.
 	normalizeURLFuncName: "urlquery",
+	// Like the normalizer function, the path segment escaper is less strict
+	// than urlquery, so it is only safe to replace it with urlquery.
+	escapeURLPathSegmentFuncName: "urlquery",
 }
 
 // escFnsEq reports whether the two escaping functions are equivalent.
@@ -446,6 +475,9 @@ func (e *escaper) escapeListConditionally(c context, n *parse.ListNode, filter f
 		for k, v := range e1.textNodeEdits {
 			e.editTextNode(k, v)
 		}
+		for k, v := range e1.actionInsertions {
+			e.actionInsertions[k] = v
+		}
 	}
 	return c, ok
 }
@@ -459,14 +491,18 @@ func (e *escaper) escapeTemplate(c context, n *parse.TemplateNode) context {
 	return c
 }
 
+// mangledNameSeparator separates a template name from the context suffix
+// added by mangle.
+const mangledNameSeparator = "$htmltemplate_"
+
 // mangle produces an identifier that includes a suffix that distinguishes it
 // from template names mangled with different contexts.
 func mangle(c context, templateName string) string {
 	// The mangled name for the default context is the input templateName.
-	if c.state == stateText {
+	if c.state == stateText && c.svg == "" {
 		return templateName
 	}
-	s := templateName + "$htmltemplate_" + c.state.String()
+	s := templateName + mangledNameSeparator + c.state.String()
 	if c.delim != 0 {
 		s += "_" + c.delim.String()
 	}
@@ -476,6 +512,12 @@ func mangle(c context, templateName string) string {
 	if c.element.name != "" {
 		s += "_" + c.element.String()
 	}
+	if c.jsonString {
+		s += "_jsonString"
+	}
+	if c.svg != "" {
+		s += "_svg" + strings.Title(c.svg)
+	}
 	return s
 }
 
@@ -513,6 +555,9 @@ func (e *escaper) escapeTree(c context, node parse.Node, name string, line int)
 			dt = template.New(dname)
 			dt.Tree = t.Tree.Copy()
 			dt.Tree.Name = dname
+			// Remove the actions inserted if t has already been committed,
+			// since they were inserted for the context t was escaped in.
+			removeHookActions(dt.Tree.Root)
 			e.derived[dname] = dt
 		}
 		t = dt
@@ -582,9 +627,40 @@ var delimEnds = [...]string{
 
 var doctypeBytes = []byte("<!DOCTYPE")
 
+// cspNonceElements contains the names of elements whose start tags in template
+// text are given the nonce attribute set by AddCSPNonce.
+var cspNonceElements = map[string]bool{
+	"script": true,
+	"style":  true,
+}
+
+// needsCSPNonce reports whether the start tag that c is in is given the nonce
+// attribute set by AddCSPNonce when it ends. Tags that already have a nonce
+// attribute keep it.
+func needsCSPNonce(c context) bool {
+	return cspNonceElements[c.element.name] && !c.nonceAttr
+}
+
+// subresourceIntegrityAttrs maps the names of elements whose start tags in
+// template text are given the integrity and crossorigin attributes set by
+// AddSubresourceIntegrity to the name of the attribute containing the URL of
+// the subresource.
+var subresourceIntegrityAttrs = map[string]string{
+	"link":   "href",
+	"script": "src",
+}
+
+// isSubresourceURLAttr reports whether c is in the value of an attribute that
+// contains the URL of a subresource described by subresourceIntegrityAttrs.
+func isSubresourceURLAttr(c context) bool {
+	return c.delim != delimNone && c.svg == "" && len(c.element.names) == 0 && len(c.attr.names) == 0 &&
+		c.attr.name != "" && subresourceIntegrityAttrs[c.element.name] == c.attr.name
+}
+
 // escapeText escapes a text template node.
 func (e *escaper) escapeText(c context, n *parse.TextNode) context {
 	s, written, i, b := n.Text, 0, 0, new(bytes.Buffer)
+	var insertions []actionInsertion
 	if e.ns.cspCompatible && bytes.Contains(s, []byte("javascript:")) {
 		// This substring search is not perfect, but it is unlikely that this substring will
 		// exist in template text for any other reason than to specify a javascript URI.
@@ -622,6 +698,34 @@ func (e *escaper) escapeText(c context, n *parse.TextNode) context {
 			}
 		} else if isComment(c.state) && c.delim == delimNone {
 			written = i1
+		} else if isSubresourceURLAttr(c) {
+			// Record the part of the subresource URL in this node, so that
+			// subresourceIntegrityAttr can match the URL in the start tag.
+			end := i1
+			if c1.delim == delimNone && c.delim != delimSpaceOrTagEnd {
+				// Exclude the closing quote.
+				end--
+			}
+			b.Write(s[written:i])
+			written = i
+			url := html.UnescapeString(string(s[i:end]))
+			insertions = append(insertions, actionInsertion{b.Len(), newAction(subresourceURLTextFuncName, url)})
+		} else if c.state == stateTag && c1.state != stateTag && c1.state != stateError && i1 > i && s[i1-1] == '>' &&
+			(needsCSPNonce(c) || c.svg == "" && subresourceIntegrityAttrs[c.element.name] != "") {
+			// Leave room for the nonce and integrity attributes before the
+			// end of the start tag, and before any slash that precedes it.
+			end := i1 - 1
+			if c.attr.name == "/" && end > written && s[end-1] == '/' {
+				end--
+			}
+			b.Write(s[written:end])
+			written = end
+			if needsCSPNonce(c) {
+				insertions = append(insertions, actionInsertion{b.Len(), cspNonceAction})
+			}
+			if c.svg == "" && subresourceIntegrityAttrs[c.element.name] != "" {
+				insertions = append(insertions, actionInsertion{b.Len(), subresourceIntegrityAction})
+			}
 		}
 		if c.state == stateSpecialElementBody && c.element.name == "script" {
 			if err := isJsTemplateBalanced(bytes.NewBuffer(s)); err != nil {
@@ -648,11 +752,14 @@ func (e *escaper) escapeText(c context, n *parse.TextNode) context {
 		c, i = c1, i1
 	}
 
-	if written != 0 && c.state != stateError {
+	if (written != 0 || len(insertions) > 0) && c.state != stateError {
 		if !isComment(c.state) || c.delim != delimNone {
 			b.Write(n.Text[written:])
 		}
 		e.editTextNode(n, b.Bytes())
+		if len(insertions) > 0 {
+			e.actionInsertions[n] = insertions
+		}
 	}
 	return c
 }
@@ -706,12 +813,16 @@ func contextAfterText(c context, s []byte) (context, int) {
 	}
 
 	// On exiting an attribute, we discard all state information
-	// except the state, element, scriptType, and linkRel.
+	// except the state, element, scriptType, linkRel, metaHTTPEquiv, nonceAttr
+	// and svg.
 	ret := context{
-		state:      stateTag,
-		element:    c.element,
-		scriptType: c.scriptType,
-		linkRel:    c.linkRel,
+		state:         stateTag,
+		element:       c.element,
+		scriptType:    c.scriptType,
+		linkRel:       c.linkRel,
+		metaHTTPEquiv: c.metaHTTPEquiv,
+		nonceAttr:     c.nonceAttr,
+		svg:           c.svg,
 	}
 	// Save the script element's type attribute value if we are parsing it for the first time.
 	if c.state == stateAttr && c.element.name == "script" && c.attr.name == "type" {
@@ -721,6 +832,10 @@ func contextAfterText(c context, s []byte) (context, int) {
 	if c.state == stateAttr && c.element.name == "link" && c.attr.name == "rel" {
 		ret.linkRel = " " + strings.Join(strings.Fields(strings.TrimSpace(strings.ToLower(string(s[:i])))), " ") + " "
 	}
+	// Save the meta element's http-equiv attribute value if we are parsing it for the first time.
+	if c.state == stateAttr && c.element.name == "meta" && c.attr.name == "http-equiv" {
+		ret.metaHTTPEquiv = strings.TrimSpace(strings.ToLower(string(s[:i])))
+	}
 	if c.delim != delimSpaceOrTagEnd {
 		// Consume any quote.
 		i++
@@ -757,6 +872,9 @@ func (e *escaper) editTextNode(n *parse.TextNode, text []byte) {
 func (e *escaper) commit() {
 	for name := range e.output {
 		e.template(name).Funcs(funcs)
+		if e.ns.urlSanitizerConfig != nil {
+			e.template(name).Funcs(urlSanitizerFuncs(e.ns.urlSanitizerConfig))
+		}
 	}
 	// Any template from the name space associated with this escaper can be used
 	// to add derived templates to the underlying text/template name space.
@@ -775,12 +893,243 @@ func (e *escaper) commit() {
 	for n, s := range e.textNodeEdits {
 		n.Text = s
 	}
+	if len(e.actionInsertions) > 0 {
+		for name := range e.output {
+			if t := e.template(name); t != nil && t.Tree != nil {
+				e.insertActions(t.Tree.Root)
+			}
+		}
+	}
+	if e.ns.exec == nil {
+		// Clone the set once it has all the functions added above.
+		exec, err := tmpl.text.Clone()
+		if err != nil {
+			panic("error cloning template set for execution")
+		}
+		e.ns.exec = exec
+	}
+	for name := range e.output {
+		if e.hooked[name] {
+			continue
+		}
+		e.hooked[name] = true
+		t := e.template(name)
+		if t == nil || t.Tree == nil {
+			continue
+		}
+		tree := t.Tree.Copy()
+		insertContextChecks(tree.Root, false)
+		if _, ok := e.derived[name]; !ok {
+			// Derived templates are never executed directly.
+			insertStreamFlushes(tree.Root, name)
+		}
+		if _, err := e.ns.exec.AddParseTree(name, tree); err != nil {
+			panic("error adding template to execution set")
+		}
+	}
 	// Reset state that is specific to this commit so that the same changes are
 	// not re-applied to the template on subsequent calls to commit.
 	e.called = make(map[string]bool)
 	e.actionNodeEdits = make(map[*parse.ActionNode][]string)
 	e.templateNodeEdits = make(map[*parse.TemplateNode]string)
 	e.textNodeEdits = make(map[*parse.TextNode][]byte)
+	e.actionInsertions = make(map[*parse.TextNode][]actionInsertion)
+}
+
+// insertActions splits each text node in n that has an entry in
+// actionInsertions at the recorded offsets, inserting a copy of the recorded
+// action at each offset.
+func (e *escaper) insertActions(n *parse.ListNode) {
+	if n == nil {
+		return
+	}
+	nodes := make([]parse.Node, 0, len(n.Nodes))
+	for _, m := range n.Nodes {
+		switch m := m.(type) {
+		case *parse.IfNode:
+			e.insertActions(m.List)
+			e.insertActions(m.ElseList)
+		case *parse.RangeNode:
+			e.insertActions(m.List)
+			e.insertActions(m.ElseList)
+		case *parse.WithNode:
+			e.insertActions(m.List)
+			e.insertActions(m.ElseList)
+		case *parse.TextNode:
+			insertions, ok := e.actionInsertions[m]
+			if !ok {
+				break
+			}
+			delete(e.actionInsertions, m)
+			text, start := m.Text, 0
+			for _, ins := range insertions {
+				t := m.Copy().(*parse.TextNode)
+				t.Text = text[start:ins.offset]
+				action := ins.action.Copy().(*parse.ActionNode)
+				action.Pos = m.Pos
+				nodes = append(nodes, t, action)
+				start = ins.offset
+			}
+			m.Text = text[start:]
+		}
+		nodes = append(nodes, m)
+	}
+	n.Nodes = nodes
+}
+
+// insertStreamFlushes inserts an action that calls streamFlush with name after
+// each top-level node of the template with the given name and root node, other
+// than text nodes, actions inserted by the escaper and the last node, so that ExecuteStream can flush its output
+// after each top-level action of the template it executes.
+func insertStreamFlushes(root *parse.ListNode, name string) {
+	if root == nil {
+		return
+	}
+	flush := newAction(streamFlushFuncName, name)
+	nodes := make([]parse.Node, 0, 2*len(root.Nodes))
+	for i, n := range root.Nodes {
+		nodes = append(nodes, n)
+		if i == len(root.Nodes)-1 {
+			continue
+		}
+		switch n := n.(type) {
+		case *parse.TextNode:
+			continue
+		case *parse.ActionNode:
+			if hookFuncCalled(n) != "" {
+				continue
+			}
+		}
+		action := flush.Copy().(*parse.ActionNode)
+		action.Pos = n.Position()
+		nodes = append(nodes, action)
+	}
+	root.Nodes = nodes
+}
+
+// insertContextChecks inserts an action that calls checkContext before each
+// node in n other than text nodes, and in the lists of n's if, range and with
+// nodes, so that ExecuteContext stops before evaluating any pipeline once its
+// context is done. If body is set, n is the body of a range or with node, and
+// an action is also inserted at its start, so that the context is checked
+// between range iterations that do not evaluate any pipelines.
+func insertContextChecks(n *parse.ListNode, body bool) {
+	if n == nil {
+		return
+	}
+	nodes := make([]parse.Node, 0, 2*len(n.Nodes)+1)
+	check := func(pos parse.Pos) {
+		if len(nodes) > 0 {
+			if a, ok := nodes[len(nodes)-1].(*parse.ActionNode); ok && hookFuncCalled(a) == checkContextFuncName {
+				return
+			}
+		}
+		action := checkContextAction.Copy().(*parse.ActionNode)
+		action.Pos = pos
+		nodes = append(nodes, action)
+	}
+	if body {
+		check(n.Position())
+	}
+	for _, m := range n.Nodes {
+		switch m := m.(type) {
+		case *parse.TextNode:
+			nodes = append(nodes, m)
+			continue
+		case *parse.IfNode:
+			insertContextChecks(m.List, false)
+			insertContextChecks(m.ElseList, false)
+		case *parse.RangeNode:
+			insertContextChecks(m.List, true)
+			insertContextChecks(m.ElseList, false)
+		case *parse.WithNode:
+			insertContextChecks(m.List, true)
+			insertContextChecks(m.ElseList, false)
+		}
+		check(m.Position())
+		nodes = append(nodes, m)
+	}
+	n.Nodes = nodes
+}
+
+// removeHookActions removes the actions that call the functions in hookFuncs
+// from n and the lists of n's if, range and with nodes.
+func removeHookActions(n *parse.ListNode) {
+	if n == nil {
+		return
+	}
+	nodes := n.Nodes[:0]
+	for _, m := range n.Nodes {
+		switch m := m.(type) {
+		case *parse.ActionNode:
+			if hookFuncCalled(m) != "" {
+				continue
+			}
+		case *parse.IfNode:
+			removeHookActions(m.List)
+			removeHookActions(m.ElseList)
+		case *parse.RangeNode:
+			removeHookActions(m.List)
+			removeHookActions(m.ElseList)
+		case *parse.WithNode:
+			removeHookActions(m.List)
+			removeHookActions(m.ElseList)
+		}
+		nodes = append(nodes, m)
+	}
+	n.Nodes = nodes
+}
+
+// hookFuncs contains the names of the functions called by the actions that the
+// escaper inserts into templates. These actions are removed from the copies of
+// committed templates that derived templates start from, since the attributes
+// some of them output are only safe in the context they were inserted in.
+var hookFuncs = map[string]bool{
+	checkContextFuncName:             true,
+	cspNonceAttrFuncName:             true,
+	streamFlushFuncName:              true,
+	subresourceIntegrityAttrFuncName: true,
+	subresourceURLTextFuncName:       true,
+}
+
+// hookFuncCalled returns the name of the function in hookFuncs that n calls, if
+// n is an action inserted by the escaper, or the empty string otherwise.
+// Templates cannot call these functions themselves, since they are only added
+// to templates once they have been escaped, after which they cannot be parsed.
+func hookFuncCalled(n *parse.ActionNode) string {
+	if len(n.Pipe.Decl) != 0 || len(n.Pipe.Cmds) != 1 {
+		return ""
+	}
+	if id, ok := n.Pipe.Cmds[0].Args[0].(*parse.IdentifierNode); ok && hookFuncs[id.Ident] {
+		return id.Ident
+	}
+	return ""
+}
+
+// checkContextAction is an action that calls checkContext.
+var checkContextAction = newAction(checkContextFuncName)
+
+// cspNonceAction is an action that outputs the result of cspNonceAttr.
+var cspNonceAction = newAction(cspNonceAttrFuncName)
+
+// subresourceIntegrityAction is an action that outputs the result of
+// subresourceIntegrityAttr.
+var subresourceIntegrityAction = newAction(subresourceIntegrityAttrFuncName)
+
+// newAction returns an action that calls the function with the given name in
+// funcs, with args as string constant arguments. The action is parsed, rather
+// than built, since action nodes built outside package parse cannot be
+// printed, and is copied by insertActions.
+func newAction(name string, args ...string) *parse.ActionNode {
+	text := name
+	for _, arg := range args {
+		text += " " + strconv.Quote(arg)
+	}
+	trees, err := parse.Parse("action", "{{"+text+"}}", "", "", funcs)
+	if err != nil {
+		panic(err)
+	}
+	return trees["action"].Root.Nodes[0].(*parse.ActionNode)
 }
 
 // template returns the named template given a mangled template name.
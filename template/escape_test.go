@@ -6,12 +6,17 @@ package template
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"html"
 	"os"
 	"strings"
 	"testing"
 	"text/template"
 	"text/template/parse"
+
+	"github.com/google/safehtml"
+	"github.com/google/safehtml/internal/xsscorpus"
 )
 
 // TODO: consider merging this file with sanitize_test.go or other test files.
@@ -367,6 +372,18 @@ func TestEscapeText(t *testing.T) {
 			`<script type="text/template">`,
 			context{state: stateSpecialElementBody, element: element{name: "script"}, scriptType: "text/template"},
 		},
+		{
+			`<script type="application/json" id="data">`,
+			context{state: stateSpecialElementBody, element: element{name: "script"}, scriptType: "application/json"},
+		},
+		{
+			`<script type="application/json">{"a": "x\"`,
+			context{state: stateSpecialElementBody, element: element{name: "script"}, scriptType: "application/json", jsonString: true},
+		},
+		{
+			`<script type="application/json">{"a": "x\\", "b": `,
+			context{state: stateSpecialElementBody, element: element{name: "script"}, scriptType: "application/json"},
+		},
 		// covering issue 19968
 		{
 			`<script type="TEXT/JAVASCRIPT">`,
@@ -487,6 +504,42 @@ func TestEscapeText(t *testing.T) {
 			`<svg:a svg:onclick="x()">`,
 			context{element: element{name: "svg:a"}},
 		},
+		{
+			`<svg>`,
+			context{element: element{name: "svg"}, svg: "svg"},
+		},
+		{
+			`<svg/>`,
+			context{},
+		},
+		{
+			`<svg><use xlink:href=`,
+			context{state: stateBeforeValue, element: element{name: "use"}, attr: attr{name: "xlink:href"}, svg: "svg"},
+		},
+		{
+			`<svg><style>`,
+			context{element: element{name: "style"}, svg: "svg"},
+		},
+		{
+			`<svg><foreignObject><p>`,
+			context{element: element{name: "p"}, svg: "foreignobject"},
+		},
+		{
+			`<svg><foreignObject><style>`,
+			context{state: stateSpecialElementBody, element: element{name: "style"}, svg: "foreignobject"},
+		},
+		{
+			`<svg><foreignObject/>`,
+			context{svg: "svg"},
+		},
+		{
+			`<svg><foreignObject></foreignObject>`,
+			context{svg: "svg"},
+		},
+		{
+			`<svg><title></title></svg>`,
+			context{},
+		},
 		{
 			`<link rel="bookmark" href=`,
 			context{state: stateBeforeValue, element: element{name: "link"}, attr: attr{name: "href"}, linkRel: " bookmark "},
@@ -904,6 +957,104 @@ func TestScriptUnbalancedError(t *testing.T) {
 	}
 }
 
+// xssCorpusFile is the corpus of known XSS payloads shared with the tests of
+// package safehtml.
+const xssCorpusFile = "../testdata/xss_payloads.txt"
+
+func TestEscaperXSSCorpus(t *testing.T) {
+	payloads, err := xsscorpus.Load(xssCorpusFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range [...]struct {
+		// prefix and suffix surround a single {{.}} action.
+		prefix, suffix stringConstant
+		// check returns an error if the escaped form of a payload is unsafe.
+		// If check is nil, every payload must be rejected.
+		check func(payload, escaped string) error
+	}{
+		{`<p>`, `</p>`, checkNoMarkup},
+		{`<title>`, `</title>`, checkNoMarkup},
+		{`<textarea>`, `</textarea>`, checkNoMarkup},
+		{`<p title="`, `">x</p>`, checkNoMarkup},
+		{`<p title='`, `'>x</p>`, checkNoMarkup},
+		{`<iframe srcdoc="`, `"></iframe>`, checkNoMarkup},
+		{`<a href="`, `">x</a>`, checkURLAttrValue},
+		{`<img src="`, `">`, checkURLAttrValue},
+		{`<script type="application/json">`, `</script>`, checkJSONValue},
+		{`<p title=`, `>x</p>`, nil},
+		{`<a href=`, `>x</a>`, nil},
+		{`<script src="`, `"></script>`, nil},
+		{`<p style="`, `">x</p>`, nil},
+		{`<style>`, `</style>`, nil},
+		{`<script>var x = `, `;</script>`, nil},
+		{`<p onclick="`, `">x</p>`, nil},
+	} {
+		tmpl := Must(New("").Parse(c.prefix + "{{.}}" + c.suffix))
+		for _, p := range payloads {
+			if note, ok := p.Skip["template"]; ok {
+				t.Logf("%s:%d: skipping %q: %s", xssCorpusFile, p.Line, p.Value, note)
+				continue
+			}
+			var b strings.Builder
+			err := tmpl.Execute(&b, p.Value)
+			if c.check == nil {
+				if err == nil {
+					t.Errorf("%s:%d: template %s: expected error for %q, got output %q", xssCorpusFile, p.Line, tmpl.Tree.Root, p.Value, b.String())
+				}
+				continue
+			}
+			if err != nil {
+				// The payload was rejected.
+				continue
+			}
+			out := b.String()
+			if !strings.HasPrefix(out, string(c.prefix)) || !strings.HasSuffix(out, string(c.suffix)) || len(out) < len(c.prefix)+len(c.suffix) {
+				t.Errorf("%s:%d: template %s: output %q for %q is not surrounded by the template text", xssCorpusFile, p.Line, tmpl.Tree.Root, out, p.Value)
+				continue
+			}
+			if err := c.check(p.Value, out[len(c.prefix):len(out)-len(c.suffix)]); err != nil {
+				t.Errorf("%s:%d: template %s: output %q for %q: %v", xssCorpusFile, p.Line, tmpl.Tree.Root, out, p.Value, err)
+			}
+		}
+	}
+}
+
+// checkNoMarkup returns an error if escaped contains characters that can
+// start a tag or end an attribute value.
+func checkNoMarkup(payload, escaped string) error {
+	if strings.ContainsAny(escaped, `<>"'`) {
+		return fmt.Errorf("escaped form %q contains markup", escaped)
+	}
+	return nil
+}
+
+// checkURLAttrValue is like checkNoMarkup, but also returns an error if escaped
+// is a URL that a browser may execute.
+func checkURLAttrValue(payload, escaped string) error {
+	if err := checkNoMarkup(payload, escaped); err != nil {
+		return err
+	}
+	if url := html.UnescapeString(escaped); url != safehtml.InnocuousURL && !xsscorpus.IsBrowserSafeURL(url) {
+		return fmt.Errorf("URL %q may be executed", url)
+	}
+	return nil
+}
+
+// checkJSONValue returns an error if escaped is not a JSON string literal whose
+// value is payload, or if it contains characters that can end the script
+// element or a JavaScript string literal.
+func checkJSONValue(payload, escaped string) error {
+	var s string
+	if err := json.Unmarshal([]byte(escaped), &s); err != nil || s != payload {
+		return fmt.Errorf("escaped form %q is not a JSON string literal with the value of the payload", escaped)
+	}
+	if strings.ContainsAny(escaped, "<>\u2028\u2029") {
+		return fmt.Errorf("escaped form %q contains characters that can end the script", escaped)
+	}
+	return nil
+}
+
 func BenchmarkEscapedExecute(b *testing.B) {
 	tmpl := Must(New("t").Parse(`<a onclick="alert('{{.}}')">{{.}}</a>`))
 	var buf bytes.Buffer
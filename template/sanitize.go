@@ -6,13 +6,17 @@ package template
 
 import (
 	"fmt"
+	"html"
 	"regexp"
 	"strings"
+
+	"github.com/google/safehtml"
+	"github.com/google/safehtml/internal/safehtmlutil"
 )
 
 // sanitizerForContext returns an ordered list of function names that will be called to
 // sanitize data values found in the HTML context defined by c.
-func sanitizerForContext(c context) ([]string, error) {
+func sanitizerForContext(c context, urlSanitizer *safehtml.URLSanitizerConfig) ([]string, error) {
 	switch c.state {
 	case stateTag, stateAttrName, stateAfterName:
 		return nil, fmt.Errorf("actions must not affect element or attribute names")
@@ -20,6 +24,10 @@ func sanitizerForContext(c context) ([]string, error) {
 		return []string{sanitizeHTMLCommentFuncName}, nil
 	}
 	if len(c.element.names) == 0 && c.element.name == "" && c.state == stateText {
+		if c.svg == "svg" {
+			// Not in an SVG element, but in SVG content.
+			return []string{sanitizeRCDATAFuncName}, nil
+		}
 		// Not in an HTML element.
 		return []string{sanitizeHTMLFuncName}, nil
 	}
@@ -29,7 +37,7 @@ func sanitizerForContext(c context) ([]string, error) {
 			// TODO: consider disallowing single-quoted or unquoted attribute values completely, even in hardcoded template text.
 			return nil, fmt.Errorf("unquoted attribute values disallowed")
 		}
-		return sanitizersForAttributeValue(c)
+		return sanitizersForAttributeValue(c, urlSanitizer)
 	}
 	// Otherwise, we are in an element content context.
 	elementContentSanitizer, err := sanitizerForElementContent(c)
@@ -48,7 +56,7 @@ func appendIfNotEmpty(slice []string, strings ...string) []string {
 
 // sanitizersForAttributeValue returns a list of names of functions that will be
 // called in order to sanitize data values found the HTML attribtue value context c.
-func sanitizersForAttributeValue(c context) ([]string, error) {
+func sanitizersForAttributeValue(c context, urlSanitizer *safehtml.URLSanitizerConfig) ([]string, error) {
 	// Ensure that all combinations of element and attribute names for this context results
 	// in the same attribute value sanitization context.
 	var elems, attrs []string
@@ -66,7 +74,13 @@ func sanitizersForAttributeValue(c context) ([]string, error) {
 	var elem0, attr0 string
 	for i, elem := range elems {
 		for j, attr := range attrs {
-			sc, err := sanitizationContextForAttrVal(elem, attr, c.linkRel)
+			var sc sanitizationContext
+			var err error
+			if c.svg == "svg" || elem == "svg" {
+				sc, err = sanitizationContextForSVGAttrVal(elem, attr)
+			} else {
+				sc, err = sanitizationContextForAttrVal(elem, attr, c.linkRel, c.metaHTTPEquiv)
+			}
 			if err != nil {
 				if len(elems) == 1 && len(attrs) == 1 {
 					return nil, err
@@ -87,6 +101,12 @@ func sanitizersForAttributeValue(c context) ([]string, error) {
 	if sc0.isEnum() && c.attr.value != "" {
 		return nil, fmt.Errorf("partial substitutions are disallowed in the %q attribute value context of a %q element", c.attr.name, c.element.name)
 	}
+	if sc0 == sanitizationContextHTML && c.attr.value != "" {
+		// The contexts inside an embedded document, such as an iframe srcdoc
+		// value, are not tracked, so literal markup before an action could put
+		// it in a URL, script or event handler where HTML escaping is unsafe.
+		return nil, fmt.Errorf("actions must be the whole %q attribute value of a %q element, since it contains an HTML document", c.attr.name, c.element.name)
+	}
 	if sc0 == sanitizationContextStyle && c.attr.value != "" {
 		if err := validateDoesNotEndsWithCharRefPrefix(c.attr.value); err != nil {
 			return nil, fmt.Errorf("action cannot be interpolated into the %q attribute value of this %q element: %s", c.attr.name, c.element.name, err)
@@ -99,10 +119,30 @@ func sanitizersForAttributeValue(c context) ([]string, error) {
 	// These attribute values will later be HTML-unescaped by the HTML parser in the browser.
 	ret = append(ret, sanitizeHTMLFuncName)
 	sanitizer := sc0.sanitizerName()
+	urlAttrValPrefix := c.attr.value
+	if sc0 == sanitizationContextMetaRefresh {
+		// The URL that a meta refresh navigates to follows the delay, so
+		// actions may only occur in the URL, where they are sanitized as in a
+		// URL attribute value.
+		prefix, err := metaRefreshURLPrefix(c.attr.value)
+		if err != nil {
+			return nil, fmt.Errorf("action cannot be interpolated into the %q attribute value of this %q element: %s", c.attr.name, c.element.name, err)
+		}
+		sc0, urlAttrValPrefix = sanitizationContextURL, prefix
+	}
+	if sc0 == sanitizationContextStyle && c.attr.value != "" && isCSSURLPrefix(html.UnescapeString(c.attr.value)) {
+		if c.attr.ambiguousValue {
+			return nil, fmt.Errorf("actions must not occur after an ambiguous CSS url( prefix in the %q attribute value context of a %q element", c.attr.name, c.element.name)
+		}
+		// The action begins the argument of a CSS url() function, so it is
+		// sanitized as a whole URL. URL normalization percent-encodes quotes,
+		// parentheses, whitespace and backslashes, so the URL cannot end the
+		// url() function or a quoted string around it.
+		return reverse(append(ret, normalizeURLFuncName, sanitizeURLFuncName)), nil
+	}
 	if !sc0.isURLorTrustedResourceURL() {
 		return reverse(appendIfNotEmpty(ret, sanitizer)), nil
 	}
-	urlAttrValPrefix := c.attr.value
 	if urlAttrValPrefix == "" {
 		// Attribute value prefixes in URL or TrustedResourceURL sanitization contexts
 		// must sanitized and normalized.
@@ -116,7 +156,7 @@ func sanitizersForAttributeValue(c context) ([]string, error) {
 	if !ok {
 		return nil, fmt.Errorf("cannot validate attribute value prefix %q in the %q sanitization context", c.attr.value, sc0)
 	}
-	if err := validator(c.attr.value); err != nil {
+	if err := validator(urlAttrValPrefix, urlSanitizer); err != nil {
 		return nil, fmt.Errorf("action cannot be interpolated into the %q URL attribute value of this %q element: %s", c.attr.name, c.element.name, err)
 	}
 	switch {
@@ -129,12 +169,125 @@ func sanitizersForAttributeValue(c context) ([]string, error) {
 		// For URLs, we only escape in the query or fragment part to prevent the injection of new query
 		// parameters or fragments.
 		ret = append(ret, queryEscapeURLFuncName)
+	case isURLPathPrefix(html.UnescapeString(urlAttrValPrefix)):
+		// Escape substitutions in the path to prevent the injection of new path
+		// segments, a query or a fragment.
+		ret = append(ret, escapeURLPathSegmentFuncName)
 	default:
 		ret = append(ret, normalizeURLFuncName)
 	}
 	return reverse(ret), nil
 }
 
+// metaRefreshDelayPattern matches the delay at the start of the content
+// attribute value of a meta refresh, followed by the separator and optional
+// "url=" that precede the URL. It does not match HTML character references,
+// so that the remainder of the value is exactly the URL in the browser.
+//
+// See https://html.spec.whatwg.org/multipage/semantics.html#shared-declarative-refresh-steps.
+var metaRefreshDelayPattern = regexp.MustCompile(`^[\t\n\f\r ]*[0-9]+(?:\.[0-9.]*)?[\t\n\f\r ]*[;,][\t\n\f\r ]*(?i:url[\t\n\f\r ]*=[\t\n\f\r ]*)?`)
+
+// metaRefreshURLPrefix returns the part of prefix, the content attribute value
+// of a meta refresh preceding an action, that is a prefix of the URL that the
+// meta refresh navigates to. It returns an error if the action would not occur
+// in an unquoted URL.
+func metaRefreshURLPrefix(prefix string) (string, error) {
+	loc := metaRefreshDelayPattern.FindStringIndex(prefix)
+	if loc == nil {
+		return "", fmt.Errorf("actions must follow the delay and ';' of the meta refresh content %q", prefix)
+	}
+	url := prefix[loc[1]:]
+	if strings.HasPrefix(url, "'") || strings.HasPrefix(url, `"`) || strings.HasPrefix(url, "&") {
+		return "", fmt.Errorf("quoted or escaped URLs are disallowed in the meta refresh content %q", prefix)
+	}
+	return url, nil
+}
+
+// cssURLPrefixPattern matches the end of a style attribute value prefix that
+// opens a CSS url() function, optionally followed by whitespace and an opening
+// quote. The url must not be preceded by a character that could be part of a
+// CSS identifier, such as in "xurl(". The first submatch is the url itself.
+var cssURLPrefixPattern = regexp.MustCompile(`(?i)(?:^|[^-\w\\\x{80}-\x{10FFFF}])(url)\([\t\n\f\r ]*["']?$`)
+
+// isCSSURLPrefix reports whether an action following prefix, the HTML-unescaped
+// prefix of a style attribute value, begins the argument of a CSS url()
+// function that is not within a CSS comment or string.
+func isCSSURLPrefix(prefix string) bool {
+	loc := cssURLPrefixPattern.FindStringSubmatchIndex(prefix)
+	if loc == nil {
+		return false
+	}
+	return !endsInCSSCommentOrString(prefix[:loc[2]])
+}
+
+// endsInCSSCommentOrString reports whether css ends inside a CSS comment or
+// string.
+func endsInCSSCommentOrString(css string) bool {
+	for i := 0; i < len(css); i++ {
+		switch c := css[i]; {
+		case c == '\\':
+			// Skip the escaped character.
+			i++
+		case strings.HasPrefix(css[i:], "/*"):
+			end := strings.Index(css[i+len("/*"):], "*/")
+			if end == -1 {
+				return true
+			}
+			i += len("/*") + end + len("*/") - 1
+		case c == '"' || c == '\'':
+			end := cssStringEnd(css[i+1:], c)
+			if end == -1 {
+				return true
+			}
+			i += 1 + end
+		}
+	}
+	return false
+}
+
+// cssStringEnd returns the index in css, the text following the opening quote
+// of a CSS string, of the byte that ends the string, or -1 if css ends inside
+// the string. Strings end at the matching quote or, since they cannot contain
+// unescaped newlines, at a newline.
+func cssStringEnd(css string, quote byte) int {
+	for i := 0; i < len(css); i++ {
+		switch css[i] {
+		case '\\':
+			i++
+		case quote, '\n', '\r', '\f':
+			return i
+		}
+	}
+	return -1
+}
+
+// isURLPathPrefix reports whether a substitution following prefix, a URL prefix
+// without a query or fragment that has been validated by validateURLPrefix,
+// occurs in a hierarchical URL path. This is the case if the part of prefix
+// following any scheme and authority contains a '/'. Substitutions into an
+// authority, such as after "https://", or into an opaque URL, such as after
+// "mailto:", are not in a path.
+//
+// As in browsers, '\' is treated like '/' in relative URLs and http and https
+// URLs, so substitutions after "\\" or "\/" are in an authority.
+func isURLPathPrefix(prefix string) bool {
+	scheme := startsWithFullySpecifiedSchemePattern.FindString(prefix)
+	rest := prefix[len(scheme):]
+	switch strings.ToLower(scheme) {
+	case "", "http:", "https:":
+		if len(rest) >= 2 && safehtmlutil.IsURLSlash(rest[0]) && safehtmlutil.IsURLSlash(rest[1]) {
+			// Browsers ignore any number of slashes before the authority.
+			rest = strings.TrimLeft(rest, `/\`)
+		}
+		return strings.ContainsAny(rest, `/\`)
+	}
+	if strings.HasPrefix(rest, "//") {
+		// The path starts at the first '/' after the authority.
+		rest = rest[len("//"):]
+	}
+	return strings.ContainsRune(rest, '/')
+}
+
 // reverse reverses s and returns it.
 func reverse(s []string) []string {
 	for head, tail := 0, len(s)-1; head < tail; head, tail = head+1, tail-1 {
@@ -145,7 +298,7 @@ func reverse(s []string) []string {
 
 // sanitizationContextForAttrVal returns the sanitization context for attr when it
 // appears within element.
-func sanitizationContextForAttrVal(element, attr, linkRel string) (sanitizationContext, error) {
+func sanitizationContextForAttrVal(element, attr, linkRel, metaHTTPEquiv string) (sanitizationContext, error) {
 	if element == "link" && attr == "href" {
 		// Special case: safehtml.URL values are allowed in a link element's href attribute if that element's
 		// rel attribute possesses certain values.
@@ -156,6 +309,16 @@ func sanitizationContextForAttrVal(element, attr, linkRel string) (sanitizationC
 			}
 		}
 	}
+	if element == "meta" && attr == "content" {
+		// Special case: the content attribute of a meta refresh contains a URL
+		// that the browser navigates to. The content attributes of other meta
+		// elements are not supported, since, for example, http-equiv="set-cookie"
+		// sets cookies in some browsers.
+		if metaHTTPEquiv != "refresh" {
+			return 0, fmt.Errorf(`actions must not occur in the "content" attribute value context of a "meta" element unless it follows http-equiv="refresh"`)
+		}
+		return sanitizationContextMetaRefresh, nil
+	}
 	if dataAttributeNamePattern.MatchString(attr) {
 		// Special case: data-* attributes are specified by HTML5 to hold custom data private to
 		// the page or application; they should not be interpreted by browsers. Therefore, no
@@ -167,6 +330,12 @@ func sanitizationContextForAttrVal(element, attr, linkRel string) (sanitizationC
 	}
 	sc, isAllowedAttr := globalAttrValSanitizationContext[attr]
 	_, isAllowedElement := elementContentSanitizationContext[element]
+	if eventHandlerAttributeNamePattern.MatchString(attr) && !isAllowedAttr {
+		// Special case: event handler attributes contain script, so only
+		// safehtml.Script values, which are always HTML-escaped within the
+		// attribute value, are allowed.
+		sc, isAllowedAttr = sanitizationContextScript, true
+	}
 	if isAllowedAttr && (isAllowedElement || allowedVoidElements[element]) {
 		// Only sanitize attributes that appear in elements whose semantics are known.
 		// Thes attributes might have different semantics in other standard or custom
@@ -176,6 +345,30 @@ func sanitizationContextForAttrVal(element, attr, linkRel string) (sanitizationC
 	return 0, fmt.Errorf("actions must not occur in the %q attribute value context of a %q element", attr, element)
 }
 
+// sanitizationContextForSVGAttrVal returns the sanitization context for attr
+// when it appears within element in SVG content.
+func sanitizationContextForSVGAttrVal(element, attr string) (sanitizationContext, error) {
+	if svgElements[element] {
+		if dataAttributeNamePattern.MatchString(attr) {
+			return sanitizationContextNone, nil
+		}
+		if attr == "href" || attr == "xlink:href" {
+			if svgHrefElements[element] {
+				return sanitizationContextTrustedResourceURLOrURL, nil
+			}
+		} else if sc, ok := svgAttrValSanitizationContext[attr]; ok {
+			return sc, nil
+		} else if eventHandlerAttributeNamePattern.MatchString(attr) {
+			return sanitizationContextScript, nil
+		}
+	}
+	return 0, fmt.Errorf("actions must not occur in the %q attribute value context of a %q element in SVG content", attr, element)
+}
+
+// eventHandlerAttributeNamePattern matches the names of event handler
+// attributes, such as onclick.
+var eventHandlerAttributeNamePattern = regexp.MustCompile(`^on[a-z]+$`)
+
 // dataAttributeNamePattern matches valid data attribute names.
 // This pattern is conservative and matches only a subset of the valid names defined in
 // https://html.spec.whatwg.org/multipage/dom.html#embedding-custom-non-visible-data-with-the-data-*-attributes
@@ -213,9 +406,21 @@ func sanitizerForElementContent(c context) (string, error) {
 	for i, elem := range elems {
 		var sc sanitizationContext
 		var err error
-		if elem == "" {
+		if c.svg == "svg" {
+			sc, err = sanitizationContextForSVGElementContent(elem)
+		} else if c.svg != "" && elem == c.svg {
+			// Special case: the content of an HTML integration point in SVG
+			// content, such as a foreignObject element, is HTML content.
+			sc = sanitizationContextHTML
+		} else if elem == "" {
 			// Special case: an empty element name represents a context outside of a HTML element.
 			sc = sanitizationContextHTML
+		} else if elem == "script" && jsonScriptTypes[c.scriptType] {
+			// Special case: script elements with a JSON type contain data, not script.
+			sc = sanitizationContextJSON
+			if c.jsonString {
+				sc = sanitizationContextJSONString
+			}
 		} else {
 			sc, err = sanitizationContextForElementContent(elem)
 		}
@@ -247,6 +452,18 @@ func sanitizationContextForElementContent(element string) (sanitizationContext,
 	return sc, nil
 }
 
+// sanitizationContextForSVGElementContent returns the element content
+// sanitization context for the given element in SVG content. An empty element
+// name represents a context in SVG content outside of any SVG element.
+func sanitizationContextForSVGElementContent(element string) (sanitizationContext, error) {
+	if element != "" && !svgElements[element] {
+		return 0, fmt.Errorf("actions must not occur in the element content context of a %q element in SVG content", element)
+	}
+	// Markup in SVG content, including that in safehtml.HTML values, is parsed
+	// as SVG rather than HTML, so only text is allowed.
+	return sanitizationContextRCDATA, nil
+}
+
 // sanitizeHTMLComment returns the empty string regardless of input.
 // Comment content does not correspond to any parsed structure or
 // human-readable content, so the simplest and most secure policy is to drop
@@ -195,11 +195,13 @@ func TestSanitize(t *testing.T) {
 		T           bool
 		A, E        []string
 		QueryParams map[string]string
+		Ref         string
 	}{
 		T:           true,
 		A:           []string{"<a>", "<b>"},
 		E:           []string{},
 		QueryParams: map[string]string{"k1": "v1", "k2": "v2", "k3": "v3"},
+		Ref:         "javascript:alert(1)",
 	}
 	for _, test := range [...]struct {
 		input  string
@@ -425,8 +427,33 @@ func TestSanitize(t *testing.T) {
 		// Attribute value contexts that expect HTML.
 		{
 			input:  `<iframe srcdoc="{{ "<a href=\"https://www.foo.com\">foo</a>" }}">{{ "<b>bar</b>" }}</iframe>`,
+			output: `<iframe srcdoc="&amp;lt;a href=&amp;#34;https://www.foo.com&amp;#34;&amp;gt;foo&amp;lt;/a&amp;gt;">&lt;b&gt;bar&lt;/b&gt;</iframe>`,
+			err:    ``,
+		},
+		{
+			input:  `<iframe srcdoc="<p>{{ "<script>alert('&amp;')</script>" }}</p>"></iframe>`,
+			output: ``,
+			err:    `actions must be the whole "srcdoc" attribute value of a "iframe" element, since it contains an HTML document`,
+		},
+		{
+			input:  `<iframe srcdoc="<a href='{{ "javascript:alert(1)" }}'>x</a>"></iframe>`,
+			output: ``,
+			err:    `actions must be the whole "srcdoc" attribute value of a "iframe" element, since it contains an HTML document`,
+		},
+		{
+			input:  `<iframe srcdoc="<script>{{ "alert(1)" }}</script>"></iframe>`,
+			output: ``,
+			err:    `actions must be the whole "srcdoc" attribute value of a "iframe" element, since it contains an HTML document`,
+		},
+		{
+			input:  `<iframe srcdoc="<img src=x onerror='{{ "alert(1)" }}'>"></iframe>`,
+			output: ``,
+			err:    `actions must be the whole "srcdoc" attribute value of a "iframe" element, since it contains an HTML document`,
+		},
+		{
+			input:  `<iframe srcdoc="<p>{{ makeHTMLForTest "<b>bar</b>" }}</p>"></iframe>`,
 			output: ``,
-			err:    `expected a safehtml.HTML value`,
+			err:    `actions must be the whole "srcdoc" attribute value of a "iframe" element, since it contains an HTML document`,
 		},
 		{
 			input:  `<iframe srcdoc="{{ makeHTMLForTest "<a href=\"https://www.foo.com\">foo</a>" }}">{{ makeHTMLForTest "<b>bar</b>" }}</iframe>`,
@@ -477,7 +504,52 @@ func TestSanitize(t *testing.T) {
 		},
 		{
 			input:  `<q cite="http://www.foo.com/{{ "multiple/path/segments" }}">foo</q>`,
-			output: `<q cite="http://www.foo.com/multiple/path/segments">foo</q>`,
+			output: `<q cite="http://www.foo.com/multiple%2fpath%2fsegments">foo</q>`,
+			err:    ``,
+		},
+		{
+			input:  `<q cite="/user/{{ "a/b?c=d#e f" }}">foo</q>`,
+			output: `<q cite="/user/a%2fb%3fc=d%23e%20f">foo</q>`,
+			err:    ``,
+		},
+		{
+			input:  `<q cite="/user/{{ "a/b" }}/{{ "c?d" }}.html">foo</q>`,
+			output: `<q cite="/user/a%2fb/c%3fd.html">foo</q>`,
+			err:    ``,
+		},
+		{
+			input:  `<q cite="/user/{{ "a/b" | urlquery }}">foo</q>`,
+			output: `<q cite="/user/a%2Fb">foo</q>`,
+			err:    ``,
+		},
+		{
+			input:  `<q cite="https://{{ "www.foo.com/a/b" }}">foo</q>`,
+			output: `<q cite="https://www.foo.com/a/b">foo</q>`,
+			err:    ``,
+		},
+		{
+			// A '\' does not end a scheme, even though browsers treat it like '/'.
+			input: `<q cite="\\{{ "www.foo.com/a/b" }}">foo</q>`,
+			err:   `action cannot be interpolated into the "cite" URL attribute value of this "q" element: URL prefix "\\\\" is unsafe; it might be interpreted as part of a scheme`,
+		},
+		{
+			input:  `<q cite="\/{{ "www.foo.com/a/b" }}">foo</q>`,
+			output: `<q cite="\/www.foo.com/a/b">foo</q>`,
+			err:    ``,
+		},
+		{
+			input:  `<q cite="\\www.foo.com/user\{{ "a\\b/c" }}">foo</q>`,
+			output: `<q cite="\\www.foo.com/user\a%5cb%2fc">foo</q>`,
+			err:    ``,
+		},
+		{
+			input:  `<q cite="\/{{ "www.foo.com/a" }}\user">foo</q>`,
+			output: `<q cite="\/www.foo.com/a\user">foo</q>`,
+			err:    ``,
+		},
+		{
+			input:  `<q cite="mailto:{{ "a@foo.com" }}">foo</q>`,
+			output: `<q cite="mailto:a@foo.com">foo</q>`,
 			err:    ``,
 		},
 		{
@@ -492,7 +564,7 @@ func TestSanitize(t *testing.T) {
 		},
 		{
 			input:  `<q cite="http://www.foo.com/{{ "multiple/path/segments" }}?q={{ "bar&x=baz" }}">foo</q>`,
-			output: `<q cite="http://www.foo.com/multiple/path/segments?q=bar%26x%3dbaz">foo</q>`,
+			output: `<q cite="http://www.foo.com/multiple%2fpath%2fsegments?q=bar%26x%3dbaz">foo</q>`,
 			err:    ``,
 		},
 		{
@@ -677,7 +749,7 @@ func TestSanitize(t *testing.T) {
 		},
 		{
 			input:  `<source src="http://www.foo.com/{{ "multiple/path/segments" }}">`,
-			output: `<source src="http://www.foo.com/multiple/path/segments">`,
+			output: `<source src="http://www.foo.com/multiple%2fpath%2fsegments">`,
 			err:    ``,
 		},
 		{
@@ -692,7 +764,7 @@ func TestSanitize(t *testing.T) {
 		},
 		{
 			input:  `<source src="http://www.foo.com/{{ "multiple/path/segments" }}?q={{ "bar&x=baz" }}">`,
-			output: `<source src="http://www.foo.com/multiple/path/segments?q=bar%26x%3dbaz">`,
+			output: `<source src="http://www.foo.com/multiple%2fpath%2fsegments?q=bar%26x%3dbaz">`,
 			err:    ``,
 		},
 		{
@@ -751,6 +823,52 @@ func TestSanitize(t *testing.T) {
 			output: ``,
 			err:    `action cannot be interpolated into the "style" attribute value of this "p" element: prefix "color:green; &" ends with an incomplete HTML character reference; did you mean "&amp;" instead of "&"?`,
 		},
+		// Actions that begin the argument of a CSS url() function in a style attribute are URLs.
+		{
+			input:  `<div style="background: url({{ .Ref }})">foo</div>`,
+			output: `<div style="background: url(about:invalid#zGoSafez)">foo</div>`,
+			err:    ``,
+		},
+		{
+			input:  `<div style="background: URL( '{{ "/img.png?a=');color:red;x=\\\"" }}' )">foo</div>`,
+			output: `<div style="background: URL( '/img.png?a=%27%29;color:red;x=%5c%22' )">foo</div>`,
+			err:    ``,
+		},
+		{
+			input:  `<div style="background:url(&quot;{{ makeURLForTest "/a b.png" }}&quot;)">foo</div>`,
+			output: `<div style="background:url(&quot;/a%20b.png&quot;)">foo</div>`,
+			err:    ``,
+		},
+		{
+			input:  `<div style="background: url({{ "/a.png" }}); color: {{ "red" }}">foo</div>`,
+			output: ``,
+			err:    `expected a safehtml.Style value`,
+		},
+		{
+			input:  `<div style="background: xurl({{ "/a.png" }})">foo</div>`,
+			output: ``,
+			err:    `expected a safehtml.Style value`,
+		},
+		{
+			input:  `<div style="content: 'url({{ "/a.png" }})'">foo</div>`,
+			output: ``,
+			err:    `expected a safehtml.Style value`,
+		},
+		{
+			input:  `<div style="/* url({{ "/a.png" }}) */">foo</div>`,
+			output: ``,
+			err:    `expected a safehtml.Style value`,
+		},
+		{
+			input:  `<div style="/* a */ content: '\'' url({{ .Ref }})">foo</div>`,
+			output: `<div style="/* a */ content: '\'' url(about:invalid#zGoSafez)">foo</div>`,
+			err:    ``,
+		},
+		{
+			input:  `<div {{if .T}}style="background: url({{else}}style="background: url(&#39;{{end}}{{ "/a.png" }})">foo</div>`,
+			output: ``,
+			err:    `actions must not occur after an ambiguous CSS url( prefix in the "style" attribute value context of a "div" element`,
+		},
 		// Element content contexts that expect StyleSheet.
 		{
 			input:  `<style>{{ "P.special { color:red ; }" }}</style>`,
@@ -788,6 +906,116 @@ func TestSanitize(t *testing.T) {
 			output: ``,
 			err:    `expected a safehtml.Script value`,
 		},
+		// Event handler attribute values also expect Script, which is
+		// HTML-escaped so that it cannot break out of the attribute value.
+		{
+			input:  `<button onclick="{{ makeScriptForTest "alert(\"a'b\" + '</button>' && 1);" }}">Go</button>`,
+			output: `<button onclick="alert(&#34;a&#39;b&#34; + &#39;&lt;/button&gt;&#39; &amp;&amp; 1);">Go</button>`,
+			err:    ``,
+		},
+		{
+			input:  `<button onclick='{{ makeScriptForTest "alert(\"a'b\");" }}'>Go</button>`,
+			output: `<button onclick='alert(&#34;a&#39;b&#34;);'>Go</button>`,
+			err:    ``,
+		},
+		{
+			input:  `<img src="/a.png" onerror="fallback(); {{ makeScriptForTest "log();" }}">`,
+			output: `<img src="/a.png" onerror="fallback(); log();">`,
+			err:    ``,
+		},
+		{
+			input:  `<button onclick="{{ "alert(1);" }}">Go</button>`,
+			output: ``,
+			err:    `expected a safehtml.Script value`,
+		},
+		{
+			input:  `<foo onclick="{{ makeScriptForTest "alert(1);" }}">Go</foo>`,
+			output: ``,
+			err:    `actions must not occur in the "onclick" attribute value context of a "foo" element`,
+		},
+		// The base element's href attribute expects a TrustedResourceURL,
+		// since it changes how every relative URL in the document resolves.
+		{
+			input:  `<base href="{{ makeTrustedResourceURLForTest "https://static.example.com/app/" }}">`,
+			output: `<base href="https://static.example.com/app/">`,
+			err:    ``,
+		},
+		{
+			input:  `<base href="/static/{{ "v1.2" }}/">`,
+			output: `<base href="/static/v1.2/">`,
+			err:    ``,
+		},
+		{
+			input:  `<base href="/static/{{ "../../evil" }}/">`,
+			output: ``,
+			err:    `cannot substitute "../../evil" after TrustedResourceURL prefix: ".." is disallowed`,
+		},
+		{
+			input:  `<base href="{{ "https://evil.com/" }}">`,
+			output: ``,
+			err:    `expected a safehtml.TrustedResourceURL value`,
+		},
+		{
+			input:  `<base href="{{ "javascript:alert(1)" }}">`,
+			output: ``,
+			err:    `expected a safehtml.TrustedResourceURL value`,
+		},
+		{
+			input:  `<base href="{{ "//evil.com/" }}">`,
+			output: ``,
+			err:    `expected a safehtml.TrustedResourceURL value`,
+		},
+		{
+			input:  `<base href="{{ makeURLForTest "https://example.com/" }}">`,
+			output: ``,
+			err:    `expected a safehtml.TrustedResourceURL value`,
+		},
+		{
+			input:  `<base href="//{{ "evil.com" }}/">`,
+			output: ``,
+			err:    `action cannot be interpolated into the "href" URL attribute value of this "base" element`,
+		},
+		// Element content contexts that expect JSON data.
+		{
+			input:  `<script type="application/json">{{ .A }}</script>`,
+			output: `<script type="application/json">["\u003ca\u003e","\u003cb\u003e"]</script>`,
+			err:    ``,
+		},
+		{
+			input:  `<script type="APPLICATION/LD+JSON">{{ "</script><script>alert(1)</script>" }}</script>`,
+			output: `<script type="APPLICATION/LD+JSON">"\u003c/script\u003e\u003cscript\u003ealert(1)\u003c/script\u003e"</script>`,
+			err:    ``,
+		},
+		{
+			input:  `<script type="application/json">{{ .QueryParams }}</script>`,
+			output: `<script type="application/json">{"k1":"v1","k2":"v2","k3":"v3"}</script>`,
+			err:    ``,
+		},
+		{
+			input:  `<script type="application/json">{"a": "{{ "x" }}", "b": {{ "y" }}}</script>`,
+			output: `<script type="application/json">{"a": "x", "b": "y"}</script>`,
+			err:    ``,
+		},
+		{
+			input:  `<script type="application/json">{"a": "\"{{ "\"</script>\u2028" }}\\", "{{ 1 }}": {{ 1 }}}</script>`,
+			output: `<script type="application/json">{"a": "\"\"\u003c/script\u003e\u2028\\", "1": 1}</script>`,
+			err:    ``,
+		},
+		{
+			input:  `<script type="application/json">{"a": "{{ if .T }}{{ else }}"{{ end }}"}</script>`,
+			output: ``,
+			err:    `{{if}} branches end in different contexts`,
+		},
+		{
+			input:  `<script type="application/json">{"a": "\{{ "x" }}"}</script>`,
+			output: ``,
+			err:    `actions must not follow a backslash in a JSON string`,
+		},
+		{
+			input:  `<script type="importmap">{{ .QueryParams }}</script>`,
+			output: ``,
+			err:    `expected a safehtml.Script value`,
+		},
 		// Attribute value contexts that expect enumerated string values.
 		{
 			input:  `<a target="{{ "blah" }}">foo</a>`,
@@ -854,6 +1082,104 @@ func TestSanitize(t *testing.T) {
 			output: `<a href="about:invalid#zGoSafez">foo</a>`,
 			err:    ``,
 		},
+		// Meta refresh.
+		{
+			input:  `<meta http-equiv="refresh" content="0; url={{ "javascript:alert(1)" }}">`,
+			output: `<meta http-equiv="refresh" content="0; url=about:invalid#zGoSafez">`,
+			err:    ``,
+		},
+		{
+			input:  `<meta http-equiv="Refresh" content="5;URL={{ "https://www.foo.com/a b" }}">`,
+			output: `<meta http-equiv="Refresh" content="5;URL=https://www.foo.com/a%20b">`,
+			err:    ``,
+		},
+		{
+			input:  `<meta http-equiv="refresh" content="0, {{ "/next?x=y" }}">`,
+			output: `<meta http-equiv="refresh" content="0, /next?x=y">`,
+			err:    ``,
+		},
+		{
+			input:  `<meta http-equiv="refresh" content="0; url=/search?q={{ "a&b" }}">`,
+			output: `<meta http-equiv="refresh" content="0; url=/search?q=a%26b">`,
+			err:    ``,
+		},
+		{
+			input: `<meta http-equiv="refresh" content="0; url=java{{ "script:alert(1)" }}">`,
+			err:   `action cannot be interpolated into the "content" URL attribute value of this "meta" element: URL prefix "java" is unsafe; it might be interpreted as part of a scheme`,
+		},
+		{
+			input: `<meta http-equiv="refresh" content="{{ "0; url=javascript:alert(1)" }}">`,
+			err:   `actions must follow the delay and ';' of the meta refresh content ""`,
+		},
+		{
+			input: `<meta http-equiv="refresh" content="0; url='{{ "/next" }}'">`,
+			err:   `quoted or escaped URLs are disallowed in the meta refresh content "0; url='"`,
+		},
+		{
+			input: `<meta content="0; url={{ "/next" }}" http-equiv="refresh">`,
+			err:   `actions must not occur in the "content" attribute value context of a "meta" element unless it follows http-equiv="refresh"`,
+		},
+		{
+			input: `<meta http-equiv="set-cookie" content="{{ "a=b" }}">`,
+			err:   `actions must not occur in the "content" attribute value context of a "meta" element unless it follows http-equiv="refresh"`,
+		},
+		// SVG content.
+		{
+			input:  `<svg viewBox="{{ "0 0 10 10" }}"><use xlink:href="{{ .Ref }}"></use></svg>`,
+			output: `<svg viewBox="0 0 10 10"><use xlink:href="about:invalid#zGoSafez"></use></svg>`,
+		},
+		{
+			input:  `<svg><use href="{{ "#icon" }}"/><a href="{{ "javascript:alert(1)" }}">x</a></svg>`,
+			output: `<svg><use href="#icon"/><a href="about:invalid#zGoSafez">x</a></svg>`,
+		},
+		{
+			input:  `<svg><text>{{ makeHTMLForTest "<b>bold</b>" }}</text>{{ "<img>" }}</svg>`,
+			output: `<svg><text>&lt;b&gt;bold&lt;/b&gt;</text>&lt;img&gt;</svg>`,
+		},
+		{
+			input:  `<svg><foreignObject><p>{{ "<script>alert(1)</script>" }}</p>{{ makeHTMLForTest "<b>bold</b>" }}</foreignObject></svg>`,
+			output: `<svg><foreignObject><p>&lt;script&gt;alert(1)&lt;/script&gt;</p><b>bold</b></foreignObject></svg>`,
+		},
+		{
+			input:  `<svg><foreignObject><a href="{{ "javascript:alert(1)" }}">x</a></foreignObject><circle r="{{ "1" }}" onclick="{{ makeScriptForTest "f()" }}"/></svg>`,
+			output: `<svg><foreignObject><a href="about:invalid#zGoSafez">x</a></foreignObject><circle r="1" onclick="f()"/></svg>`,
+		},
+		{
+			input: `<svg><style>{{ "a{}" }}</style></svg>`,
+			err:   `actions must not occur in the element content context of a "style" element in SVG content`,
+		},
+		{
+			input: `<svg><script>{{ "alert(1)" }}</script></svg>`,
+			err:   `actions must not occur in the element content context of a "script" element in SVG content`,
+		},
+		{
+			input: `<svg><animate attributeName="href" values="{{ "javascript:alert(1)" }}"/></svg>`,
+			err:   `actions must not occur in the "values" attribute value context of a "animate" element in SVG content`,
+		},
+		{
+			input: `<svg><rect href="{{ "/a" }}"/></svg>`,
+			err:   `actions must not occur in the "href" attribute value context of a "rect" element in SVG content`,
+		},
+		{
+			input: `<svg><p>{{ "x" }}</p></svg>`,
+			err:   `HTML element "p" must not occur in SVG content`,
+		},
+		{
+			input: `<div><svg></div>{{ "x" }}`,
+			err:   `HTML element "div" must not occur in SVG content`,
+		},
+		{
+			input: `<svg><svg></svg></svg>`,
+			err:   `nested svg elements are not supported`,
+		},
+		{
+			input: `<svg><![CDATA[<a title="]]>{{ "x" }}">]]></svg>`,
+			err:   `CDATA sections are disallowed in SVG content`,
+		},
+		{
+			input: `{{ if .T }}<svg>{{ end }}{{ "x" }}`,
+			err:   `branches end in different contexts`,
+		},
 		// Conditional valueless attribute name.
 		{
 			input: `<img class="{{"iconClass"}}"` +
@@ -1165,6 +1491,68 @@ func testSanitizationLogicCheckError(t *testing.T, err error, parseFuncName, exe
 	}
 }
 
+func TestSafeTypeValues(t *testing.T) {
+	var (
+		html       = testconversions.MakeHTMLForTest(`<b class="x">a &amp; b</b>`)
+		identifier = testconversions.MakeIdentifierForTest(`foo-bar`)
+		script     = testconversions.MakeScriptForTest(`if (a < b && c) { f("</b>"); }`)
+		style      = testconversions.MakeStyleForTest(`margin: 0 1px; color: red`)
+		styleSheet = testconversions.MakeStyleSheetForTest(`a > b { color: red }`)
+		trustedURL = testconversions.MakeTrustedResourceURLForTest(`https://example.com/a.js?b=%2F&c`)
+		url        = testconversions.MakeURLForTest(`tel:+1%20555`)
+	)
+	// url would be replaced if it were sanitized again.
+	if sanitized := safehtml.URLSanitized(url.String()); sanitized.String() != safehtml.InnocuousURL {
+		t.Fatalf("URLSanitized(%q) = %q, want %q", url, sanitized, safehtml.InnocuousURL)
+	}
+	for _, test := range [...]struct {
+		desc string
+		tmpl stringConstant
+		data interface{}
+		want string
+		err  string
+	}{
+		// Values in matching contexts are emitted unchanged, apart from the
+		// HTML escaping required by attribute values.
+		{desc: "HTML in element content", tmpl: `<div>{{.}}</div>`, data: html, want: `<div><b class="x">a &amp; b</b></div>`},
+		{desc: "Identifier in id", tmpl: `<p id="{{.}}">`, data: identifier, want: `<p id="foo-bar">`},
+		{desc: "Script in script element", tmpl: `<script>{{.}}</script>`, data: script, want: `<script>if (a < b && c) { f("</b>"); }</script>`},
+		{desc: "Style in style attribute", tmpl: `<p style="{{.}}">`, data: style, want: `<p style="margin: 0 1px; color: red">`},
+		{desc: "StyleSheet in style element", tmpl: `<style>{{.}}</style>`, data: styleSheet, want: `<style>a > b { color: red }</style>`},
+		{desc: "TrustedResourceURL in script src", tmpl: `<script src="{{.}}"></script>`, data: trustedURL, want: `<script src="https://example.com/a.js?b=%2F&amp;c"></script>`},
+		{desc: "TrustedResourceURL in href", tmpl: `<a href="{{.}}">`, data: trustedURL, want: `<a href="https://example.com/a.js?b=%2F&amp;c">`},
+		{desc: "URL in href", tmpl: `<a href="{{.}}">`, data: url, want: `<a href="tel:+1%20555">`},
+		{desc: "URL in img src", tmpl: `<img src="{{.}}">`, data: url, want: `<img src="tel:+1%20555">`},
+		// Values in mismatching contexts are rejected.
+		{desc: "HTML in script element", tmpl: `<script>{{.}}</script>`, data: html, err: "expected a safehtml.Script value"},
+		{desc: "Identifier in style attribute", tmpl: `<p style="{{.}}">`, data: identifier, err: "expected a safehtml.Style value"},
+		{desc: "Script in style element", tmpl: `<style>{{.}}</style>`, data: script, err: "expected a safehtml.StyleSheet value"},
+		{desc: "Style in style element", tmpl: `<style>{{.}}</style>`, data: style, err: "expected a safehtml.StyleSheet value"},
+		{desc: "StyleSheet in style attribute", tmpl: `<p style="{{.}}">`, data: styleSheet, err: "expected a safehtml.Style value"},
+		{desc: "URL in script src", tmpl: `<script src="{{.}}"></script>`, data: url, err: "expected a safehtml.TrustedResourceURL value"},
+		{desc: "Style in id", tmpl: `<p id="{{.}}">`, data: style, err: "expected a safehtml.Identifier value"},
+	} {
+		tmpl := Must(New("").Parse(test.tmpl))
+		var b strings.Builder
+		err := tmpl.Execute(&b, test.data)
+		if test.err != "" {
+			if err == nil {
+				t.Errorf("%s: got output %q, want error containing %q", test.desc, b.String(), test.err)
+			} else if !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error:\n\t%s\nwant error containing:\n\t%s", test.desc, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.desc, err)
+			continue
+		}
+		if got := b.String(); got != test.want {
+			t.Errorf("%s: got\n\t%s\nwant\n\t%s", test.desc, got, test.want)
+		}
+	}
+}
+
 func TestCannotCallInternalSanitizers(t *testing.T) {
 	const templateName = "test"
 	// Programmatically generate templates that call each sanitizer function in the internal
@@ -1642,3 +2030,35 @@ Line 6</html>`,
 		}
 	}
 }
+
+func TestJSONScriptElementContent(t *testing.T) {
+	data := struct {
+		Name    string
+		Comment string
+		Lines   []string
+	}{
+		Name:    "</script><script>alert(1)</script>",
+		Comment: "<!-- -->",
+		Lines:   []string{"a\u2028b", "c\u2029d", `"&'`},
+	}
+	tmpl := Must(New("").Parse(`<script type="application/json" id="data">{{ . }}</script>`))
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		t.Fatal(err)
+	}
+	got := b.String()
+	want := `<script type="application/json" id="data">` +
+		`{"Name":"\u003c/script\u003e\u003cscript\u003ealert(1)\u003c/script\u003e",` +
+		`"Comment":"\u003c!-- --\u003e",` +
+		`"Lines":["a\u2028b","c\u2029d","\"\u0026'"]}` +
+		`</script>`
+	if got != want {
+		t.Errorf("got:\n\t%s\nwant:\n\t%s", got, want)
+	}
+	body := strings.TrimSuffix(strings.TrimPrefix(got, `<script type="application/json" id="data">`), `</script>`)
+	for _, s := range []string{"<", ">", "\u2028", "\u2029"} {
+		if strings.Contains(body, s) {
+			t.Errorf("JSON script body %q contains context-breaking sequence %q", body, s)
+		}
+	}
+}
@@ -7,6 +7,7 @@
 package template
 
 import (
+	"encoding/json"
 	"fmt"
 	"text/template"
 
@@ -23,9 +24,11 @@ const (
 	sanitizationContextAsyncEnum
 	sanitizationContextDirEnum
 	sanitizationContextHTML
-	sanitizationContextHTMLValOnly
 	sanitizationContextIdentifier
+	sanitizationContextJSON
+	sanitizationContextJSONString
 	sanitizationContextLoadingEnum
+	sanitizationContextMetaRefresh
 	sanitizationContextNone
 	sanitizationContextRCDATA
 	sanitizationContextScript
@@ -75,9 +78,11 @@ var sanitizationContextInfo = [...]struct {
 	sanitizationContextAsyncEnum:               {"AsyncEnum", sanitizeAsyncEnumFuncName},
 	sanitizationContextDirEnum:                 {"DirEnum", sanitizeDirEnumFuncName},
 	sanitizationContextHTML:                    {"HTML", sanitizeHTMLFuncName},
-	sanitizationContextHTMLValOnly:             {"HTMLValOnly", sanitizeHTMLValOnlyFuncName},
 	sanitizationContextIdentifier:              {"Identifier", sanitizeIdentifierFuncName},
+	sanitizationContextJSON:                    {"JSON", sanitizeJSONFuncName},
+	sanitizationContextJSONString:              {"JSONString", sanitizeJSONStringFuncName},
 	sanitizationContextLoadingEnum:             {"LoadingEnum", sanitizeLoadingEnumFuncName},
+	sanitizationContextMetaRefresh:             {"MetaRefresh", sanitizeURLFuncName},
 	sanitizationContextNone:                    {"None", ""},
 	sanitizationContextRCDATA:                  {"RCDATA", sanitizeRCDATAFuncName},
 	sanitizationContextScript:                  {"Script", sanitizeScriptFuncName},
@@ -93,14 +98,16 @@ var sanitizationContextInfo = [...]struct {
 var funcs = template.FuncMap{
 	queryEscapeURLFuncName:                         safehtmlutil.QueryEscapeURL,
 	normalizeURLFuncName:                           safehtmlutil.NormalizeURL,
+	escapeURLPathSegmentFuncName:                   safehtmlutil.EscapeURLPathSegment,
 	validateTrustedResourceURLSubstitutionFuncName: validateTrustedResourceURLSubstitution,
 	evalArgsFuncName:                               evalArgs,
 	sanitizeHTMLCommentFuncName:                    sanitizeHTMLComment,
 	sanitizeAsyncEnumFuncName:                      sanitizeAsyncEnum,
 	sanitizeDirEnumFuncName:                        sanitizeDirEnum,
 	sanitizeHTMLFuncName:                           sanitizeHTML,
-	sanitizeHTMLValOnlyFuncName:                    sanitizeHTMLValOnly,
 	sanitizeIdentifierFuncName:                     sanitizeIdentifier,
+	sanitizeJSONFuncName:                           sanitizeJSON,
+	sanitizeJSONStringFuncName:                     sanitizeJSONString,
 	sanitizeLoadingEnumFuncName:                    sanitizeLoadingEnum,
 	sanitizeRCDATAFuncName:                         sanitizeRCDATA,
 	sanitizeScriptFuncName:                         sanitizeScript,
@@ -111,19 +118,29 @@ var funcs = template.FuncMap{
 	sanitizeTrustedResourceURLOrURLFuncName:        sanitizeTrustedResourceURLOrURL,
 	sanitizeURLFuncName:                            sanitizeURL,
 	sanitizeURLSetFuncName:                         sanitizeURLSet,
+	requireURLValueFuncName:                        requireURLValue,
+	cspNonceAttrFuncName:                           cspNonceAttr,
+	subresourceURLFuncName:                         subresourceURL,
+	subresourceURLTextFuncName:                     subresourceURLText,
+	subresourceIntegrityAttrFuncName:               subresourceIntegrityAttr,
+	streamFlushFuncName:                            streamFlush,
+	checkContextFuncName:                           checkContext,
 }
 
 const (
 	queryEscapeURLFuncName                         = "_queryEscapeURL"
+	requireURLValueFuncName                        = "_requireURLValue"
 	normalizeURLFuncName                           = "_normalizeURL"
+	escapeURLPathSegmentFuncName                   = "_escapeURLPathSegment"
 	validateTrustedResourceURLSubstitutionFuncName = "_validateTrustedResourceURLSubstitution"
 	evalArgsFuncName                               = "_evalArgs"
 	sanitizeHTMLCommentFuncName                    = "_sanitizeHTMLComment"
 	sanitizeAsyncEnumFuncName                      = "_sanitizeAsyncEnum"
 	sanitizeDirEnumFuncName                        = "_sanitizeDirEnum"
 	sanitizeHTMLFuncName                           = "_sanitizeHTML"
-	sanitizeHTMLValOnlyFuncName                    = "_sanitizeHTMLValOnly"
 	sanitizeIdentifierFuncName                     = "_sanitizeIdentifier"
+	sanitizeJSONFuncName                           = "_sanitizeJSON"
+	sanitizeJSONStringFuncName                     = "_sanitizeJSONString"
 	sanitizeLoadingEnumFuncName                    = "_sanitizeLoadingEnum"
 	sanitizeRCDATAFuncName                         = "_sanitizeRCDATA"
 	sanitizeScriptFuncName                         = "_sanitizeScript"
@@ -134,6 +151,12 @@ const (
 	sanitizeTrustedResourceURLOrURLFuncName        = "_sanitizeTrustedResourceURLOrURL"
 	sanitizeURLFuncName                            = "_sanitizeURL"
 	sanitizeURLSetFuncName                         = "_sanitizeURLSet"
+	cspNonceAttrFuncName                           = "_cspNonceAttr"
+	subresourceURLFuncName                         = "_subresourceURL"
+	subresourceURLTextFuncName                     = "_subresourceURLText"
+	subresourceIntegrityAttrFuncName               = "_subresourceIntegrityAttr"
+	streamFlushFuncName                            = "_streamFlush"
+	checkContextFuncName                           = "_checkContext"
 )
 
 // urlLinkRelVals contains values for a link element's rel attribute that indicate that the same link
@@ -158,6 +181,14 @@ var urlLinkRelVals = map[string]bool{
 	"subresource":  true,
 }
 
+// jsonScriptTypes contains values for a script element's type attribute that indicate
+// that the script element contains a JSON data block rather than executable script.
+// See https://html.spec.whatwg.org/multipage/scripting.html#data-block.
+var jsonScriptTypes = map[string]bool{
+	"application/json":    true,
+	"application/ld+json": true,
+}
+
 // elementSpecificAttrValSanitizationContext[x][y] is the sanitization context for
 // attribute x when it appears within element y.
 var elementSpecificAttrValSanitizationContext = map[string]map[string]sanitizationContext{
@@ -181,6 +212,10 @@ var elementSpecificAttrValSanitizationContext = map[string]map[string]sanitizati
 	"href": {
 		"a":    sanitizationContextTrustedResourceURLOrURL,
 		"area": sanitizationContextTrustedResourceURLOrURL,
+		// The base URL changes how every relative URL in the document
+		// resolves, so it must be a safehtml.TrustedResourceURL, whose origin
+		// is chosen by the application rather than by template data.
+		"base": sanitizationContextTrustedResourceURL,
 	},
 	"method": {
 		"form": sanitizationContextNone,
@@ -200,7 +235,7 @@ var elementSpecificAttrValSanitizationContext = map[string]map[string]sanitizati
 		"video":  sanitizationContextTrustedResourceURLOrURL,
 	},
 	"srcdoc": {
-		"iframe": sanitizationContextHTMLValOnly,
+		"iframe": sanitizationContextHTML,
 	},
 }
 
@@ -436,6 +471,181 @@ var elementContentSanitizationContext = map[string]sanitizationContext{
 	"video":      sanitizationContextHTML,
 }
 
+// svgElements contains the lowercase names of SVG elements in whose content and
+// attribute values actions may occur in SVG content. It excludes elements that
+// run scripts, such as script and the animation elements, which can modify
+// attribute values; and that contain stylesheets.
+var svgElements = map[string]bool{
+	"a":                   true,
+	"circle":              true,
+	"clippath":            true,
+	"defs":                true,
+	"desc":                true,
+	"ellipse":             true,
+	"feblend":             true,
+	"fecolormatrix":       true,
+	"fecomponenttransfer": true,
+	"fecomposite":         true,
+	"fedropshadow":        true,
+	"feflood":             true,
+	"fefunca":             true,
+	"fefuncb":             true,
+	"fefuncg":             true,
+	"fefuncr":             true,
+	"fegaussianblur":      true,
+	"femerge":             true,
+	"femergenode":         true,
+	"femorphology":        true,
+	"feoffset":            true,
+	"filter":              true,
+	"foreignobject":       true,
+	"g":                   true,
+	"image":               true,
+	"line":                true,
+	"lineargradient":      true,
+	"marker":              true,
+	"mask":                true,
+	"path":                true,
+	"pattern":             true,
+	"polygon":             true,
+	"polyline":            true,
+	"radialgradient":      true,
+	"rect":                true,
+	"stop":                true,
+	"svg":                 true,
+	"symbol":              true,
+	"text":                true,
+	"textpath":            true,
+	"title":               true,
+	"tspan":               true,
+	"use":                 true,
+}
+
+// svgHrefElements contains the lowercase names of SVG elements whose href and
+// xlink:href attribute values are URL or TrustedResourceURL sanitization
+// contexts.
+var svgHrefElements = map[string]bool{
+	"a":              true,
+	"image":          true,
+	"lineargradient": true,
+	"pattern":        true,
+	"radialgradient": true,
+	"textpath":       true,
+	"use":            true,
+}
+
+// svgAttrValSanitizationContext[x] is the sanitization context for attribute x,
+// other than href and xlink:href, when it appears within an SVG element in
+// svgElements.
+var svgAttrValSanitizationContext = map[string]sanitizationContext{
+	"aria-hidden":         sanitizationContextNone,
+	"aria-label":          sanitizationContextNone,
+	"aria-labelledby":     sanitizationContextIdentifier,
+	"class":               sanitizationContextNone,
+	"clip-path":           sanitizationContextNone,
+	"clip-rule":           sanitizationContextNone,
+	"clippathunits":       sanitizationContextNone,
+	"color":               sanitizationContextNone,
+	"cx":                  sanitizationContextNone,
+	"cy":                  sanitizationContextNone,
+	"d":                   sanitizationContextNone,
+	"display":             sanitizationContextNone,
+	"dominant-baseline":   sanitizationContextNone,
+	"dx":                  sanitizationContextNone,
+	"dy":                  sanitizationContextNone,
+	"fill":                sanitizationContextNone,
+	"fill-opacity":        sanitizationContextNone,
+	"fill-rule":           sanitizationContextNone,
+	"filter":              sanitizationContextNone,
+	"filterunits":         sanitizationContextNone,
+	"flood-color":         sanitizationContextNone,
+	"flood-opacity":       sanitizationContextNone,
+	"focusable":           sanitizationContextNone,
+	"font-family":         sanitizationContextNone,
+	"font-size":           sanitizationContextNone,
+	"font-style":          sanitizationContextNone,
+	"font-weight":         sanitizationContextNone,
+	"fr":                  sanitizationContextNone,
+	"fx":                  sanitizationContextNone,
+	"fy":                  sanitizationContextNone,
+	"gradienttransform":   sanitizationContextNone,
+	"gradientunits":       sanitizationContextNone,
+	"height":              sanitizationContextNone,
+	"id":                  sanitizationContextIdentifier,
+	"in":                  sanitizationContextNone,
+	"in2":                 sanitizationContextNone,
+	"k1":                  sanitizationContextNone,
+	"k2":                  sanitizationContextNone,
+	"k3":                  sanitizationContextNone,
+	"k4":                  sanitizationContextNone,
+	"lang":                sanitizationContextNone,
+	"lengthadjust":        sanitizationContextNone,
+	"letter-spacing":      sanitizationContextNone,
+	"marker-end":          sanitizationContextNone,
+	"marker-mid":          sanitizationContextNone,
+	"marker-start":        sanitizationContextNone,
+	"markerheight":        sanitizationContextNone,
+	"markerunits":         sanitizationContextNone,
+	"markerwidth":         sanitizationContextNone,
+	"mask":                sanitizationContextNone,
+	"maskcontentunits":    sanitizationContextNone,
+	"maskunits":           sanitizationContextNone,
+	"mode":                sanitizationContextNone,
+	"offset":              sanitizationContextNone,
+	"opacity":             sanitizationContextNone,
+	"operator":            sanitizationContextNone,
+	"orient":              sanitizationContextNone,
+	"patterncontentunits": sanitizationContextNone,
+	"patterntransform":    sanitizationContextNone,
+	"patternunits":        sanitizationContextNone,
+	"points":              sanitizationContextNone,
+	"preserveaspectratio": sanitizationContextNone,
+	"primitiveunits":      sanitizationContextNone,
+	"r":                   sanitizationContextNone,
+	"radius":              sanitizationContextNone,
+	"refx":                sanitizationContextNone,
+	"refy":                sanitizationContextNone,
+	"result":              sanitizationContextNone,
+	"role":                sanitizationContextNone,
+	"rotate":              sanitizationContextNone,
+	"rx":                  sanitizationContextNone,
+	"ry":                  sanitizationContextNone,
+	"shape-rendering":     sanitizationContextNone,
+	"spreadmethod":        sanitizationContextNone,
+	"startoffset":         sanitizationContextNone,
+	"stddeviation":        sanitizationContextNone,
+	"stop-color":          sanitizationContextNone,
+	"stop-opacity":        sanitizationContextNone,
+	"stroke":              sanitizationContextNone,
+	"stroke-dasharray":    sanitizationContextNone,
+	"stroke-dashoffset":   sanitizationContextNone,
+	"stroke-linecap":      sanitizationContextNone,
+	"stroke-linejoin":     sanitizationContextNone,
+	"stroke-miterlimit":   sanitizationContextNone,
+	"stroke-opacity":      sanitizationContextNone,
+	"stroke-width":        sanitizationContextNone,
+	"style":               sanitizationContextStyle,
+	"tabindex":            sanitizationContextNone,
+	"target":              sanitizationContextTargetEnum,
+	"text-anchor":         sanitizationContextNone,
+	"textlength":          sanitizationContextNone,
+	"transform":           sanitizationContextNone,
+	"type":                sanitizationContextNone,
+	"values":              sanitizationContextNone,
+	"vector-effect":       sanitizationContextNone,
+	"version":             sanitizationContextNone,
+	"viewbox":             sanitizationContextNone,
+	"visibility":          sanitizationContextNone,
+	"width":               sanitizationContextNone,
+	"word-spacing":        sanitizationContextNone,
+	"x":                   sanitizationContextNone,
+	"x1":                  sanitizationContextNone,
+	"x2":                  sanitizationContextNone,
+	"y":                   sanitizationContextNone,
+	"y1":                  sanitizationContextNone,
+	"y2":                  sanitizationContextNone,
+}
+
 // allowedVoidElements is a set of names of void elements actions may appear in.
 var allowedVoidElements = map[string]bool{
 	"area":   true,
@@ -487,15 +697,6 @@ func sanitizeHTML(args ...interface{}) (string, error) {
 	return safehtml.HTMLEscaped(input).String(), nil
 }
 
-func sanitizeHTMLValOnly(args ...interface{}) (string, error) {
-	if len(args) > 0 {
-		if safeTypeValue, ok := safehtmlutil.Indirect(args[0]).(safehtml.HTML); ok {
-			return safeTypeValue.String(), nil
-		}
-	}
-	return "", fmt.Errorf(`expected a safehtml.HTML value`)
-}
-
 func sanitizeIdentifier(args ...interface{}) (string, error) {
 	if len(args) > 0 {
 		if safeTypeValue, ok := safehtmlutil.Indirect(args[0]).(safehtml.Identifier); ok {
@@ -505,6 +706,34 @@ func sanitizeIdentifier(args ...interface{}) (string, error) {
 	return "", fmt.Errorf(`expected a safehtml.Identifier value`)
 }
 
+// sanitizeJSON returns the JSON encoding of its argument, or of its arguments
+// as a JSON array if there is more than one. encoding/json escapes '<', '>', '&',
+// U+2028 and U+2029 in strings, so the output cannot close the enclosing script
+// element, open an HTML comment, or be misinterpreted if evaluated as JavaScript.
+func sanitizeJSON(args ...interface{}) (string, error) {
+	var v interface{} = args
+	if len(args) == 1 {
+		v = args[0]
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// sanitizeJSONString returns the JSON encoding of the string form of its
+// arguments without the enclosing quotes, so that it can be interpolated into a
+// JSON string literal. Like sanitizeJSON, it escapes '<', '>', '&', U+2028 and
+// U+2029.
+func sanitizeJSONString(args ...interface{}) (string, error) {
+	b, err := json.Marshal(safehtmlutil.Stringify(args...))
+	if err != nil {
+		return "", err
+	}
+	return string(b[1 : len(b)-1]), nil
+}
+
 var sanitizeLoadingEnumValues = map[string]bool{
 	"eager": true,
 	"lazy":  true,
@@ -573,6 +802,12 @@ func sanitizeTrustedResourceURL(args ...interface{}) (string, error) {
 }
 
 func sanitizeTrustedResourceURLOrURL(args ...interface{}) (string, error) {
+	return sanitizeTrustedResourceURLOrURLWith(defaultURLSanitizerConfig, args...)
+}
+
+// sanitizeTrustedResourceURLOrURLWith is like sanitizeTrustedResourceURLOrURL,
+// but sanitizes values that are not safe URL types with urlSanitizer.
+func sanitizeTrustedResourceURLOrURLWith(urlSanitizer *safehtml.URLSanitizerConfig, args ...interface{}) (string, error) {
 	if len(args) > 0 {
 		switch v := safehtmlutil.Indirect(args[0]).(type) {
 		case safehtml.TrustedResourceURL, safehtml.URL:
@@ -580,20 +815,105 @@ func sanitizeTrustedResourceURLOrURL(args ...interface{}) (string, error) {
 		}
 	}
 	input := safehtmlutil.Stringify(args...)
-	return safehtml.URLSanitized(input).String(), nil
+	return urlSanitizer.Sanitize(input).String(), nil
 }
 
 func sanitizeURL(args ...interface{}) (string, error) {
+	return sanitizeURLWith(defaultURLSanitizerConfig, args...)
+}
+
+// sanitizeURLWith is like sanitizeURL, but sanitizes values that are not
+// safehtml.URLs with urlSanitizer.
+func sanitizeURLWith(urlSanitizer *safehtml.URLSanitizerConfig, args ...interface{}) (string, error) {
 	if len(args) > 0 {
 		if safeTypeValue, ok := safehtmlutil.Indirect(args[0]).(safehtml.URL); ok {
 			return safeTypeValue.String(), nil
 		}
 	}
 	input := safehtmlutil.Stringify(args...)
-	return safehtml.URLSanitized(input).String(), nil
+	return urlSanitizer.Sanitize(input).String(), nil
 }
 
 func sanitizeURLSet(args ...interface{}) (string, error) {
+	if len(args) > 0 {
+		if safeTypeValue, ok := safehtmlutil.Indirect(args[0]).(safehtml.URLSet); ok {
+			return safeTypeValue.String(), nil
+		}
+	}
 	input := safehtmlutil.Stringify(args...)
 	return safehtml.URLSetSanitized(input).String(), nil
 }
+
+// urlValueSanitizers contains the names of the functions that, when first in a
+// list returned by sanitizerForContext, sanitize values substituted into URL or
+// TrustedResourceURL attribute values.
+var urlValueSanitizers = map[string]bool{
+	sanitizeTrustedResourceURLFuncName:             true,
+	sanitizeTrustedResourceURLOrURLFuncName:        true,
+	sanitizeURLFuncName:                            true,
+	normalizeURLFuncName:                           true,
+	escapeURLPathSegmentFuncName:                   true,
+	queryEscapeURLFuncName:                         true,
+	validateTrustedResourceURLSubstitutionFuncName: true,
+}
+
+// requireURLValue returns arg, or an error if arg is nil or its string form is
+// empty. It is applied to values substituted into URL or TrustedResourceURL
+// attribute values before they are sanitized in templates with the
+// "urlmissingkey=error" option.
+func requireURLValue(arg interface{}) (interface{}, error) {
+	if arg == nil {
+		return nil, fmt.Errorf("missing value for URL")
+	}
+	if safehtmlutil.Stringify(arg) == "" {
+		return nil, fmt.Errorf("empty value for URL")
+	}
+	return arg, nil
+}
+
+// cspNonceAttr returns the nonce attribute that is inserted after the name of
+// each script and style start tag in template text. It returns the empty
+// string unless it is replaced for a single execution by the AddCSPNonce
+// option.
+func cspNonceAttr() string {
+	return ""
+}
+
+// subresourceURL returns the sanitized value of an action in the URL attribute
+// of a script or link element. Unless it is replaced for a single execution by
+// the AddSubresourceIntegrity option, it does not record the value.
+func subresourceURL(value string) string {
+	return value
+}
+
+// subresourceURLText returns the empty string. It is inserted before each part
+// of the URL attribute value of a script or link start tag in template text,
+// with that part, HTML-unescaped, as text. Unless it is replaced for a single
+// execution by the AddSubresourceIntegrity option, it does not record text.
+func subresourceURLText(text string) string {
+	return ""
+}
+
+// subresourceIntegrityAttr returns the integrity and crossorigin attributes
+// that are inserted before the end of each script and link start tag in
+// template text. It returns the empty string unless it is replaced for a
+// single execution by the AddSubresourceIntegrity option.
+func subresourceIntegrityAttr() string {
+	return ""
+}
+
+// streamFlush returns a marker that causes the writer of ExecuteStream to
+// flush its output if name is the name of the executed template. It is
+// inserted after each top-level action of the copy of each template executed
+// by ExecuteStream, with the name of the template as name.
+func streamFlush(name string) string {
+	return streamFlushOutput(name)
+}
+
+// checkContext returns the empty string. It is inserted before each pipeline
+// and at the start of each range and with body in the copy of each template
+// executed by ExecuteContext, whose writer checks the context whenever its
+// empty output is written.
+func checkContext() string {
+	return ""
+}
@@ -5,18 +5,24 @@
 package template
 
 import (
+	"bufio"
 	"bytes"
+	stdcontext "context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
 	"sync"
 	"text/template"
 	"text/template/parse"
 
 	"log"
 	"github.com/google/safehtml"
-	"github.com/google/safehtml/uncheckedconversions"
+	"github.com/google/safehtml/internal/raw"
 )
 
 // Template is a specialized Template from "text/template" that produces a safe
@@ -44,9 +50,87 @@ type nameSpace struct {
 	// cspCompatible indicates whether inline event handlers and
 	// javascript: URIs are disallowed in templates in this namespace.
 	cspCompatible bool
-	esc           escaper
+	// urlSanitizerConfig, if non-nil, is used instead of safehtml.URLSanitized
+	// to sanitize URLs in templates in this namespace.
+	urlSanitizerConfig *safehtml.URLSanitizerConfig
+	// urlMissingKeyError indicates whether execution fails when a value
+	// substituted into a URL or TrustedResourceURL attribute value is empty
+	// or missing.
+	urlMissingKeyError bool
+	// contextFuncs holds the functions added to templates in this namespace
+	// whose first parameter is a context.Context.
+	contextFuncs map[string]reflect.Value
+	// funcNames holds the names of all functions added to templates in this
+	// namespace with Funcs.
+	funcNames map[string]bool
+	// fileTemplates maps the name of each file parsed with ParseFiles and
+	// related functions, or with ReparseFile, to the sorted names of the
+	// templates it defined.
+	fileTemplates map[string][]string
+	// exec is a copy of the underlying text template set, made when a
+	// template in this namespace is first escaped, that holds a copy of each
+	// escaped template with the actions that check the context and flush
+	// streamed output. It is executed by ExecuteStream, ExecuteContext and
+	// ExecuteWithOptions, so that these actions are not in the trees
+	// returned by Tree and Templates.
+	exec *template.Template
+	esc  escaper
 }
 
+// parseTrees returns the parse trees of the templates in ns, keyed by template
+// name. The caller must hold ns.mu.
+func (ns *nameSpace) parseTrees() map[string]*parse.Tree {
+	trees := make(map[string]*parse.Tree, len(ns.set))
+	for name, tmpl := range ns.set {
+		trees[name] = tmpl.Tree
+	}
+	return trees
+}
+
+// recordFileTemplates records the templates defined by parsing the file with
+// the given name, which are those whose parse trees differ from before, the
+// result of parseTrees before the file was parsed. Templates redefined by the
+// file are no longer recorded as defined by other files. The caller must hold
+// ns.mu.
+func (ns *nameSpace) recordFileTemplates(name string, before map[string]*parse.Tree) {
+	var defined []string
+	redefined := make(map[string]bool)
+	for n, tmpl := range ns.set {
+		if tmpl.Tree != nil && tmpl.Tree != before[n] && !isEmptyFileTemplate(name, n, tmpl.Tree) {
+			defined = append(defined, n)
+			redefined[n] = true
+		}
+	}
+	sort.Strings(defined)
+	if ns.fileTemplates == nil {
+		ns.fileTemplates = make(map[string][]string)
+	}
+	for file, names := range ns.fileTemplates {
+		var kept []string
+		for _, n := range names {
+			if !redefined[n] {
+				kept = append(kept, n)
+			}
+		}
+		if len(kept) < len(names) {
+			ns.fileTemplates[file] = kept
+		}
+	}
+	ns.fileTemplates[name] = defined
+}
+
+// urlSanitizer returns the URLSanitizerConfig used to sanitize URLs in templates
+// in ns.
+func (ns *nameSpace) urlSanitizer() *safehtml.URLSanitizerConfig {
+	if ns.urlSanitizerConfig == nil {
+		return defaultURLSanitizerConfig
+	}
+	return ns.urlSanitizerConfig
+}
+
+// defaultURLSanitizerConfig accepts exactly the URLs accepted by safehtml.URLSanitized.
+var defaultURLSanitizerConfig = safehtml.DefaultURLSanitizerConfig()
+
 // Templates returns a slice of the templates associated with t, including t
 // itself.
 func (t *Template) Templates() []*Template {
@@ -79,11 +163,57 @@ func (t *Template) Templates() []*Template {
 //		The operation returns the zero value for the map type's element.
 //	"missingkey=error"
 //		Execution stops immediately with an error.
+//
+// urlmissingkey: Control the behavior during execution if a value substituted
+// into a URL or TrustedResourceURL attribute value, such as an href or src
+// attribute, is empty or missing. Unlike the other options, this option
+// applies to t and all its associated templates, and must be set before any
+// of them is executed.
+//
+//	"urlmissingkey=default"
+//		The default behavior: Do nothing and continue execution.
+//		A missing value is substituted as "<nil>", or as the zero value
+//		for the map type's element with "missingkey=zero".
+//	"urlmissingkey=error"
+//		Execution stops immediately with an error if the value is nil,
+//		as it is for missing map keys with "missingkey=default", or if its
+//		string form is empty, as it is for the zero value of string fields
+//		and of safehtml.URL. With "missingkey=error", missing map keys
+//		still cause the error described above.
 func (t *Template) Option(opt ...string) *Template {
-	t.text.Option(opt...)
+	for _, o := range opt {
+		if strings.HasPrefix(o, "urlmissingkey=") {
+			t.nameSpace.setURLMissingKey(o)
+			continue
+		}
+		t.text.Option(o)
+		t.nameSpace.mu.Lock()
+		exec := t.nameSpace.exec
+		t.nameSpace.mu.Unlock()
+		if exec != nil {
+			exec.Option(o)
+		}
+	}
 	return t
 }
 
+// setURLMissingKey applies the urlmissingkey option opt to ns.
+func (ns *nameSpace) setURLMissingKey(opt string) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	if ns.escaped {
+		panic("html/template: cannot set urlmissingkey after Execute")
+	}
+	switch opt {
+	case "urlmissingkey=default":
+		ns.urlMissingKeyError = false
+	case "urlmissingkey=error":
+		ns.urlMissingKeyError = true
+	default:
+		panic("unrecognized option: " + opt)
+	}
+}
+
 // checkCanParse checks whether it is OK to parse templates.
 // If not, it returns an error.
 func (t *Template) checkCanParse() error {
@@ -130,6 +260,440 @@ func (t *Template) Execute(wr io.Writer, data interface{}) error {
 	return t.text.Execute(wr, data)
 }
 
+// ExecuteStream applies a parsed template to the specified data object,
+// writing the output to wr as it is produced, rather than after the whole
+// template has been executed.
+//
+// ExecuteStream buffers up to 4096 bytes of output at a time. The buffered
+// output is written to wr after each top-level action of the template, that
+// is, each action, range, if, with or template call at the top level of its
+// text; whenever the buffer is full; and when execution ends. If wr has a
+// Flush method, such as a *bufio.Writer or an http.ResponseWriter
+// implementing http.Flusher, ExecuteStream flushes wr after each of these
+// writes, so that output reaches the client incrementally without a flush for
+// every text node and action.
+//
+// The output of ExecuteStream is identical to that of Execute. If an error
+// occurs executing the template or writing or flushing its output,
+// execution stops, but partial results may already have been written to the
+// output writer. The returned error is then a *StreamError reporting how many
+// bytes were written, so that callers can, for example, replace the response
+// with an error page if nothing was written yet, or abort it otherwise.
+func (t *Template) ExecuteStream(wr io.Writer, data interface{}) error {
+	if err := t.escape(); err != nil {
+		return &StreamError{Err: err}
+	}
+	sw := newStreamWriter(wr, t.Name())
+	err := t.execTemplate().Execute(sw, data)
+	// Write any partial results, as Execute would have.
+	if flushErr := sw.Flush(); err == nil {
+		err = flushErr
+	}
+	if sw.err != nil {
+		// Report write and flush errors as such, rather than as errors
+		// calling the function that flushed the output.
+		err = sw.err
+	}
+	if err != nil {
+		return &StreamError{Written: sw.cw.n, Err: err}
+	}
+	return nil
+}
+
+// streamBufferSize is the size of the buffer used by ExecuteStream.
+const streamBufferSize = 4096
+
+// countingWriter is an io.Writer that counts the bytes written to the
+// underlying writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// streamFlushPrefix starts the output of streamFlush, which is never written
+// to the output of a template.
+const streamFlushPrefix = "\x00safehtml/template: flush\x00"
+
+// streamFlushOutput returns the output of streamFlush for the template with
+// the given name.
+func streamFlushOutput(name string) string {
+	return streamFlushPrefix + name
+}
+
+// isStreamFlush returns whether p is the output of streamFlush.
+func isStreamFlush(p []byte) bool {
+	return bytes.HasPrefix(p, []byte(streamFlushPrefix))
+}
+
+// streamWriter is an io.Writer that buffers the output of ExecuteStream. It
+// flushes the underlying writer, if it has a Flush method, whenever buffered
+// output is written to it.
+type streamWriter struct {
+	bw    *bufio.Writer
+	cw    countingWriter
+	flush func() error
+	// flushOutput is the output of streamFlush after each top-level action
+	// of the executed template, which flushes the buffered output.
+	flushOutput string
+	// flushed is the number of bytes written to the underlying writer when
+	// it was last flushed.
+	flushed int64
+	// err is the first error writing or flushing the underlying writer.
+	err error
+}
+
+// newStreamWriter returns a streamWriter that writes the output of the
+// template with the given name to w.
+func newStreamWriter(w io.Writer, name string) *streamWriter {
+	sw := &streamWriter{cw: countingWriter{w: w}, flushOutput: streamFlushOutput(name)}
+	sw.bw = bufio.NewWriterSize(&sw.cw, streamBufferSize)
+	switch f := w.(type) {
+	case interface{ Flush() error }:
+		sw.flush = f.Flush
+	case interface{ Flush() }:
+		sw.flush = func() error { f.Flush(); return nil }
+	}
+	return sw
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if isStreamFlush(p) {
+		if string(p) != sw.flushOutput {
+			// The action is at the top level of a called template.
+			return len(p), nil
+		}
+		return len(p), sw.Flush()
+	}
+	n, err := sw.bw.Write(p)
+	if err == nil {
+		// Flush any output written because the buffer filled up.
+		err = sw.flushWritten()
+	}
+	return n, sw.setErr(err)
+}
+
+// Flush writes any buffered output to the underlying writer and flushes it.
+func (sw *streamWriter) Flush() error {
+	err := sw.bw.Flush()
+	if err == nil {
+		err = sw.flushWritten()
+	}
+	return sw.setErr(err)
+}
+
+// flushWritten flushes the underlying writer if it has a Flush method and
+// output was written to it since it was last flushed.
+func (sw *streamWriter) flushWritten() error {
+	if sw.flush == nil || sw.cw.n == sw.flushed {
+		return nil
+	}
+	sw.flushed = sw.cw.n
+	return sw.flush()
+}
+
+// setErr records err, if it is the first error, and returns it.
+func (sw *streamWriter) setErr(err error) error {
+	if err != nil && sw.err == nil {
+		sw.err = err
+	}
+	return err
+}
+
+// ExecuteContext is like Execute, but stops executing the template and returns
+// ctx.Err() once ctx is done.
+//
+// ctx is checked before template execution starts, before the evaluation of
+// each pipeline, including those of if, range, with and template actions, at
+// the start of each iteration of a range action, and before each write of
+// template output. Execution therefore stops promptly even in ranges that
+// produce no output, although a function that is already running is not
+// interrupted unless it observes ctx itself. If ctx is done when execution
+// ends, ctx.Err() is returned instead of any other error.
+//
+// Functions added with Funcs whose first parameter is a context.Context
+// receive ctx as that argument and are called in templates without it. For
+// example, given
+//
+//	tmpl.Funcs(FuncMap{"lookup": func(ctx stdcontext.Context, key string) (string, error) { ... }})
+//
+// the action {{ lookup "user" }} calls the function with ctx and "user". Such
+// functions receive stdcontext.Background() when the template is executed by any
+// method other than ExecuteContext.
+func (t *Template) ExecuteContext(ctx stdcontext.Context, wr io.Writer, data interface{}) error {
+	if err := t.escape(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	text, err := t.withContext(ctx, nil)
+	if err != nil {
+		return err
+	}
+	return executeContext(ctx, text, wr, data)
+}
+
+// executeContext executes text, which must have been returned by withContext
+// for ctx, returning ctx.Err() if ctx is done when execution ends.
+func executeContext(ctx stdcontext.Context, text *template.Template, wr io.Writer, data interface{}) error {
+	err := text.Execute(&contextWriter{ctx, wr}, data)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		// Report cancellation as such, rather than as an error calling
+		// checkContext or writing the output.
+		return ctxErr
+	}
+	return err
+}
+
+// An ExecuteOption configures a single execution of a template by
+// ExecuteWithOptions.
+type ExecuteOption func(*executeOptions)
+
+// executeOptions holds the settings made by ExecuteOptions.
+type executeOptions struct {
+	cspNonce             string
+	subresourceIntegrity map[safehtml.TrustedResourceURL]string
+}
+
+// cspNoncePattern matches the base64-value grammar of nonce sources in the
+// CSP specification. None of the characters it allows need to be escaped in
+// a quoted attribute value.
+// See https://www.w3.org/TR/CSP3/#grammardef-nonce-source.
+var cspNoncePattern = regexp.MustCompile(`^[A-Za-z0-9+/_-]+={0,2}$`)
+
+// AddCSPNonce returns an ExecuteOption that adds a nonce attribute with the
+// given value to each script and style start tag in the template text, so
+// that the elements are allowed by a Content-Security-Policy using the nonce.
+// Since the option applies to a single execution, each response can be given
+// a fresh nonce without re-parsing the template.
+//
+// For example, executing
+//
+//	<script src="{{ . }}"></script><style>p { color: red }</style>
+//
+// with AddCSPNonce("rAnd0m") produces
+//
+//	<script src="..." nonce="rAnd0m"></script><style nonce="rAnd0m">p { color: red }</style>
+//
+// Only start tags written literally in template text are changed, and start
+// tags that already have a nonce attribute are left unchanged. Execution
+// fails if nonce does not match the base64-value grammar of the CSP
+// specification.
+func AddCSPNonce(nonce string) ExecuteOption {
+	return func(o *executeOptions) {
+		o.cspNonce = nonce
+	}
+}
+
+// subresourceIntegrityPattern matches the hash expressions accepted by
+// AddSubresourceIntegrity: a SHA-256, SHA-384 or SHA-512 hash with standard
+// base64 padding. None of the characters it allows need to be escaped in a
+// quoted attribute value.
+// See https://www.w3.org/TR/SRI/#the-integrity-attribute.
+var subresourceIntegrityPattern = regexp.MustCompile(`^sha(?:256-[A-Za-z0-9+/]{43}=|384-[A-Za-z0-9+/]{64}|512-[A-Za-z0-9+/]{86}==)$`)
+
+// AddSubresourceIntegrity returns an ExecuteOption that adds integrity and
+// crossorigin="anonymous" attributes to each script and link start tag in the
+// template text whose src or href attribute, respectively, is one of the URLs
+// in hashes. The integrity attribute contains the hash of the URL in hashes,
+// such as "sha384-oqVuAfXRKap7fdgcCY5uykM6+R9GqQ8K/uxy9rx7HNQlGYl1kPzQho1wx4JwY8wC",
+// so that browsers only load the script or stylesheet if its content matches
+// the hash.
+//
+// For example, executing
+//
+//	<script src="{{ . }}"></script>
+//
+// with data equal to u and AddSubresourceIntegrity(map[safehtml.TrustedResourceURL]string{u: hash})
+// produces
+//
+//	<script src="..." integrity="sha384-..." crossorigin="anonymous"></script>
+//
+// URLs are matched against the value of the attribute in the output, before it
+// is HTML-escaped, whether it is written in template text, the output of
+// actions, or both. Only start tags written literally in template text are
+// changed, and the attributes are added before the end of the tag, so any
+// integrity or crossorigin attribute already in the tag takes precedence.
+// Execution fails if a hash is not a base64-encoded SHA-256, SHA-384 or
+// SHA-512 hash prefixed by the name of its algorithm, as in the example.
+func AddSubresourceIntegrity(hashes map[safehtml.TrustedResourceURL]string) ExecuteOption {
+	return func(o *executeOptions) {
+		o.subresourceIntegrity = hashes
+	}
+}
+
+// subresourceIntegrityFuncs returns the functions that replace subresourceURL,
+// subresourceURLText and subresourceIntegrityAttr for a single execution, so
+// that the integrity attributes for the URLs in hashes are added.
+func subresourceIntegrityFuncs(hashes map[safehtml.TrustedResourceURL]string) (template.FuncMap, error) {
+	attrs := make(map[string]string, len(hashes))
+	for u, hash := range hashes {
+		if !subresourceIntegrityPattern.MatchString(hash) {
+			return nil, fmt.Errorf("template: invalid subresource integrity hash %q for %q", hash, u)
+		}
+		attrs[u.String()] = ` integrity="` + hash + `" crossorigin="anonymous"`
+	}
+	// url holds the URL attribute value of the current start tag.
+	var url strings.Builder
+	return template.FuncMap{
+		subresourceURLFuncName: func(value string) string {
+			url.WriteString(value)
+			return value
+		},
+		subresourceURLTextFuncName: func(text string) string {
+			url.WriteString(text)
+			return ""
+		},
+		subresourceIntegrityAttrFuncName: func() string {
+			attr := attrs[url.String()]
+			url.Reset()
+			return attr
+		},
+	}, nil
+}
+
+// ExecuteWithOptions is like ExecuteContext, but applies the given options to
+// this execution only.
+func (t *Template) ExecuteWithOptions(ctx stdcontext.Context, wr io.Writer, data interface{}, opts ...ExecuteOption) error {
+	var o executeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	funcs := template.FuncMap{}
+	if o.cspNonce != "" {
+		if !cspNoncePattern.MatchString(o.cspNonce) {
+			return fmt.Errorf("template: invalid CSP nonce %q", o.cspNonce)
+		}
+		attr := ` nonce="` + o.cspNonce + `"`
+		funcs[cspNonceAttrFuncName] = func() string { return attr }
+	}
+	if len(o.subresourceIntegrity) > 0 {
+		sri, err := subresourceIntegrityFuncs(o.subresourceIntegrity)
+		if err != nil {
+			return err
+		}
+		for name, fn := range sri {
+			funcs[name] = fn
+		}
+	}
+	if err := t.escape(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	text, err := t.withContext(ctx, funcs)
+	if err != nil {
+		return err
+	}
+	return executeContext(ctx, text, wr, data)
+}
+
+// execTemplate returns the copy of t in the execution set of its namespace.
+// t must have been escaped.
+func (t *Template) execTemplate() *template.Template {
+	t.nameSpace.mu.Lock()
+	defer t.nameSpace.mu.Unlock()
+	return t.nameSpace.execLookup(t.Name())
+}
+
+// execLookup returns the copy of the escaped template with the given name in
+// the execution set of ns. The caller must hold ns.mu.
+func (ns *nameSpace) execLookup(name string) *template.Template {
+	var text *template.Template
+	if ns.exec != nil {
+		text = ns.exec.Lookup(name)
+	}
+	if text == nil {
+		panic("html/template internal error: escaped template missing from execution set")
+	}
+	return text
+}
+
+// withContext returns the copy of t in the execution set of its namespace. If
+// there are functions in contextFuncs or extra, the set is cloned, with the
+// functions in contextFuncs bound to ctx and the functions in extra added.
+func (t *Template) withContext(ctx stdcontext.Context, extra template.FuncMap) (*template.Template, error) {
+	t.nameSpace.mu.Lock()
+	defer t.nameSpace.mu.Unlock()
+	text := t.nameSpace.execLookup(t.Name())
+	if len(t.contextFuncs) == 0 && len(extra) == 0 {
+		return text, nil
+	}
+	text, err := text.Clone()
+	if err != nil {
+		return nil, err
+	}
+	funcs := make(template.FuncMap, len(t.contextFuncs)+len(extra))
+	for name, fn := range t.contextFuncs {
+		funcs[name] = bindContext(fn, ctx)
+	}
+	for name, fn := range extra {
+		funcs[name] = fn
+	}
+	return text.Funcs(funcs), nil
+}
+
+// contextWriter is an io.Writer that fails with ctx.Err() once ctx is done.
+// The empty output of checkContext is not written to w, but still causes ctx
+// to be checked, and the output of streamFlush is dropped.
+type contextWriter struct {
+	ctx stdcontext.Context
+	w   io.Writer
+}
+
+func (cw *contextWriter) Write(p []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if len(p) == 0 || isStreamFlush(p) {
+		return len(p), nil
+	}
+	return cw.w.Write(p)
+}
+
+var contextType = reflect.TypeOf((*stdcontext.Context)(nil)).Elem()
+
+// isContextFunc returns whether fn is a function whose first parameter is a
+// context.Context.
+func isContextFunc(fn reflect.Value) bool {
+	return fn.Kind() == reflect.Func && fn.Type().NumIn() > 0 && fn.Type().In(0) == contextType
+}
+
+// bindContext returns a function that calls fn, which must satisfy
+// isContextFunc, with ctx as its first argument followed by the arguments
+// it was called with.
+func bindContext(fn reflect.Value, ctx stdcontext.Context) interface{} {
+	typ := fn.Type()
+	in := make([]reflect.Type, typ.NumIn()-1)
+	for i := range in {
+		in[i] = typ.In(i + 1)
+	}
+	out := make([]reflect.Type, typ.NumOut())
+	for i := range out {
+		out[i] = typ.Out(i)
+	}
+	ctxVal := reflect.ValueOf(&ctx).Elem()
+	return reflect.MakeFunc(reflect.FuncOf(in, out, typ.IsVariadic()), func(args []reflect.Value) []reflect.Value {
+		args = append([]reflect.Value{ctxVal}, args...)
+		if typ.IsVariadic() {
+			return fn.CallSlice(args)
+		}
+		return fn.Call(args)
+	}).Interface()
+}
+
+// rawHTML constructs a safehtml.HTML from the output of an escaped template.
+// It is used instead of package uncheckedconversions so that these
+// conversions are not audited.
+var rawHTML = raw.HTML.(func(string) safehtml.HTML)
+
 // ExecuteToHTML applies a parsed template to the specified data object,
 // returning the output as a safehtml.HTML value.
 // A template may be executed safely in parallel.
@@ -138,7 +702,7 @@ func (t *Template) ExecuteToHTML(data interface{}) (safehtml.HTML, error) {
 	if err := t.Execute(&buf, data); err != nil {
 		return safehtml.HTML{}, err
 	}
-	return uncheckedconversions.HTMLFromStringKnownToSatisfyTypeContract(buf.String()), nil
+	return rawHTML(buf.String()), nil
 }
 
 // MustParseAndExecuteToHTML is a helper that returns the safehtml.HTML value produced
@@ -179,14 +743,16 @@ func (t *Template) ExecuteTemplate(wr io.Writer, name string, data interface{})
 
 // ExecuteTemplateToHTML applies the template associated with t that has
 // the given name to the specified data object and returns the output as
-// a safehtml.HTML value.
+// a safehtml.HTML value. This can be used to render a single defined block,
+// such as one defined by {{define "card"}}, and pass the result to the HTML
+// content of another template, where it is not escaped again.
 // A template may be executed safely in parallel.
 func (t *Template) ExecuteTemplateToHTML(name string, data interface{}) (safehtml.HTML, error) {
 	var buf bytes.Buffer
 	if err := t.ExecuteTemplate(&buf, name, data); err != nil {
 		return safehtml.HTML{}, err
 	}
-	return uncheckedconversions.HTMLFromStringKnownToSatisfyTypeContract(buf.String()), nil
+	return rawHTML(buf.String()), nil
 }
 
 // lookupAndEscapeTemplate guarantees that the template with the given name
@@ -222,6 +788,112 @@ func (t *Template) DefinedTemplates() string {
 	return t.text.DefinedTemplates()
 }
 
+// DefinedTemplateNames returns, in sorted order, the names of the templates
+// associated with t, including t itself, that have been defined by any of the
+// Parse methods. It lists the same templates as DefinedTemplates, but in a
+// form suited to programmatic use, such as checking that required templates
+// exist before any template is executed. Templates derived by the escaper
+// are not listed.
+func (t *Template) DefinedTemplateNames() []string {
+	ns := t.nameSpace
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	var names []string
+	for name, tmpl := range ns.set {
+		if tmpl.text.Tree == nil || tmpl.text.Root == nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TemplateInfo describes a template associated with a Template.
+type TemplateInfo struct {
+	// Name is the name of the template.
+	Name string
+	// Defined lists, in sorted order, the names of the templates defined by
+	// {{define}} or {{block}} actions in the text that was parsed to produce
+	// this template.
+	Defined []string
+	// Invoked lists, in sorted order, the names of the templates invoked by
+	// {{template}} or {{block}} actions in this template. Invoked templates
+	// are not guaranteed to be defined.
+	Invoked []string
+}
+
+// Describe returns a TemplateInfo for each template associated with t,
+// including t itself, sorted by name. Templates that have no parse tree and
+// define no other templates, such as those only created with New, are omitted.
+//
+// The result is derived from the parsed templates and is not affected by
+// escaping. It may be used, for example, to check that all invoked templates
+// are defined before any template is executed.
+func (t *Template) Describe() []TemplateInfo {
+	ns := t.nameSpace
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	defined := make(map[string][]string)
+	for name, tmpl := range ns.set {
+		if tmpl.text.Tree == nil || tmpl.text.Tree.ParseName == name {
+			continue
+		}
+		parent := tmpl.text.Tree.ParseName
+		defined[parent] = append(defined[parent], name)
+	}
+	var infos []TemplateInfo
+	for name, tmpl := range ns.set {
+		hasTree := tmpl.text.Tree != nil && tmpl.text.Root != nil
+		if !hasTree && len(defined[name]) == 0 {
+			continue
+		}
+		invoked := make(map[string]bool)
+		if hasTree {
+			collectInvokedTemplates(tmpl.text.Root, invoked)
+		}
+		info := TemplateInfo{Name: name, Defined: defined[name]}
+		for n := range invoked {
+			info.Invoked = append(info.Invoked, n)
+		}
+		sort.Strings(info.Defined)
+		sort.Strings(info.Invoked)
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// collectInvokedTemplates adds to names the names of the templates invoked
+// by {{template}} actions in the tree rooted at node. The names of templates
+// derived during escaping are replaced by the names of their sources.
+func collectInvokedTemplates(node parse.Node, names map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			collectInvokedTemplates(c, names)
+		}
+	case *parse.IfNode:
+		collectInvokedTemplates(n.List, names)
+		collectInvokedTemplates(n.ElseList, names)
+	case *parse.RangeNode:
+		collectInvokedTemplates(n.List, names)
+		collectInvokedTemplates(n.ElseList, names)
+	case *parse.WithNode:
+		collectInvokedTemplates(n.List, names)
+		collectInvokedTemplates(n.ElseList, names)
+	case *parse.TemplateNode:
+		name := n.Name
+		if i := strings.Index(name, mangledNameSeparator); i >= 0 {
+			name = name[:i]
+		}
+		names[name] = true
+	}
+}
+
 // Parse parses text as a template body for t.
 // Named template definitions ({{define ...}} or {{block ...}} statements) in text
 // define additional templates associated with t and are removed from the
@@ -234,6 +906,8 @@ func (t *Template) DefinedTemplates() string {
 // This allows using Parse to add new named template definitions without
 // overwriting the main template body.
 //
+// Syntax errors are reported as a *ParseError.
+//
 // To guarantee that the template body is never controlled by an attacker, text
 // must be an untyped string constant, which is always under programmer control.
 func (t *Template) Parse(text stringConstant) (*Template, error) {
@@ -243,7 +917,7 @@ func (t *Template) Parse(text stringConstant) (*Template, error) {
 
 	ret, err := t.text.Parse(string(text))
 	if err != nil {
-		return nil, err
+		return nil, newParseError(t.Name(), err)
 	}
 
 	// In general, all the named templates might have changed underfoot.
@@ -281,6 +955,79 @@ func (t *Template) ParseFromTrustedTemplate(tmpl TrustedTemplate) (*Template, er
 	return t.Parse(stringConstant(tmpl.String()))
 }
 
+// A TrustedParseTree is the parse tree of a template whose text is under
+// programmer control, such as a template parsed from an untyped string
+// constant, a TrustedTemplate or a TrustedSource. It is used to compose
+// templates programmatically with AddTrustedParseTree.
+//
+// A TrustedParseTree can only be obtained from a Template with
+// TrustedParseTree, so that parse trees built or modified by other code, which
+// may be derived from untrusted input, cannot be added to a template.
+type TrustedParseTree struct {
+	tree *parse.Tree
+}
+
+// Name returns the name of the template the parse tree was obtained from.
+func (t TrustedParseTree) Name() string {
+	if t.tree == nil {
+		return ""
+	}
+	return t.tree.Name
+}
+
+// TrustedParseTree returns the parse tree of t, so that it can be added to
+// other templates with AddTrustedParseTree.
+//
+// It returns an error if t has no parse tree or if t or any associated
+// template has already been executed, since execution rewrites the parse trees
+// of t and the templates it calls to escape their output.
+func (t *Template) TrustedParseTree() (TrustedParseTree, error) {
+	t.nameSpace.mu.Lock()
+	defer t.nameSpace.mu.Unlock()
+	if t.nameSpace.escaped {
+		return TrustedParseTree{}, fmt.Errorf("html/template: cannot get the parse tree of %q after it has executed", t.Name())
+	}
+	if t.Tree == nil {
+		return TrustedParseTree{}, fmt.Errorf("html/template: %q is an incomplete or empty template", t.Name())
+	}
+	return TrustedParseTree{t.Tree.Copy()}, nil
+}
+
+// AddTrustedParseTree associates the parse tree with t, giving it the
+// specified name, and returns the associated template. If a template with
+// that name already exists, its definition is replaced. It is the analog of
+// the AddParseTree method of html/template, which is omitted from this package
+// since a *parse.Tree may have been built from untrusted input.
+//
+// The same TrustedParseTree may be added to any number of templates. Functions
+// called by the parse tree must also be added to t with Funcs before t is
+// executed.
+//
+// It returns an error if t or any associated template has already been
+// executed.
+func (t *Template) AddTrustedParseTree(name string, tree TrustedParseTree) (*Template, error) {
+	if tree.tree == nil {
+		return nil, fmt.Errorf("html/template: cannot add an empty TrustedParseTree as %q", name)
+	}
+	if err := t.checkCanParse(); err != nil {
+		return nil, err
+	}
+	t.nameSpace.mu.Lock()
+	defer t.nameSpace.mu.Unlock()
+	// Escaping rewrites parse trees in place, so add a copy.
+	text, err := t.text.AddParseTree(name, tree.tree.Copy())
+	if err != nil {
+		return nil, err
+	}
+	tmpl := t.set[name]
+	if tmpl == nil {
+		tmpl = t.new(name)
+	}
+	tmpl.text = text
+	tmpl.Tree = text.Tree
+	return tmpl, nil
+}
+
 // Clone returns a duplicate of the template, including all associated
 // templates. The actual representation is not copied, but the name space of
 // associated templates is, so further calls to Parse in the copy will add
@@ -288,6 +1035,10 @@ func (t *Template) ParseFromTrustedTemplate(tmpl TrustedTemplate) (*Template, er
 // common templates and use them with variant definitions for other templates
 // by adding the variants after the clone is made.
 //
+// The copy also has the functions added with Funcs and the settings made with
+// AllowURLSchemes, CSPCompatible and Option. Subsequent calls to these methods
+// on either template do not affect the other.
+//
 // It returns an error if t has already been executed.
 func (t *Template) Clone() (*Template, error) {
 	t.nameSpace.mu.Lock()
@@ -299,7 +1050,34 @@ func (t *Template) Clone() (*Template, error) {
 	if err != nil {
 		return nil, err
 	}
-	ns := &nameSpace{set: make(map[string]*Template)}
+	ns := &nameSpace{
+		set:                make(map[string]*Template),
+		cspCompatible:      t.nameSpace.cspCompatible,
+		urlMissingKeyError: t.nameSpace.urlMissingKeyError,
+	}
+	if c := t.nameSpace.urlSanitizerConfig; c != nil {
+		copied := *c
+		ns.urlSanitizerConfig = &copied
+	}
+	if len(t.nameSpace.contextFuncs) > 0 {
+		ns.contextFuncs = make(map[string]reflect.Value, len(t.nameSpace.contextFuncs))
+		for name, fn := range t.nameSpace.contextFuncs {
+			ns.contextFuncs[name] = fn
+		}
+	}
+	if len(t.nameSpace.funcNames) > 0 {
+		ns.funcNames = make(map[string]bool, len(t.nameSpace.funcNames))
+		for name := range t.nameSpace.funcNames {
+			ns.funcNames[name] = true
+		}
+	}
+	if len(t.nameSpace.fileTemplates) > 0 {
+		// The slices are never modified in place, so they can be shared.
+		ns.fileTemplates = make(map[string][]string, len(t.nameSpace.fileTemplates))
+		for name, defined := range t.nameSpace.fileTemplates {
+			ns.fileTemplates[name] = defined
+		}
+	}
 	ns.esc = makeEscaper(ns)
 	ret := &Template{
 		nil,
@@ -332,7 +1110,7 @@ func New(name string) *Template {
 	ns.esc = makeEscaper(ns)
 	tmpl := &Template{
 		nil,
-		template.New(name),
+		template.New(name).Funcs(builtinFuncs),
 		nil,
 		ns,
 	}
@@ -383,13 +1161,49 @@ func (t *Template) Name() string {
 // "text/template".
 type FuncMap map[string]interface{}
 
+// builtinFuncs contains the functions that are available in every template, in
+// addition to the text/template builtins. See "Substitutions in URLs" in the
+// package documentation for urlWithParams, anchorOpenTag and srcset.
+var builtinFuncs = template.FuncMap{
+	"urlWithParams": urlWithParams,
+	"anchorOpenTag": anchorOpenTag,
+	"srcset":        srcset,
+}
+
 // Funcs adds the elements of the argument map to the template's function map.
 // It must be called before the template is parsed.
 // It panics if a value in the map is not a function with appropriate return
 // type. However, it is legal to overwrite elements of the map. The return
 // value is the template, so calls can be chained.
+//
+// Functions whose first parameter is a context.Context are called without
+// that argument in templates; see ExecuteContext.
 func (t *Template) Funcs(funcMap FuncMap) *Template {
-	t.text.Funcs(template.FuncMap(funcMap))
+	funcs := make(template.FuncMap, len(funcMap))
+	t.nameSpace.mu.Lock()
+	if t.funcNames == nil {
+		t.funcNames = make(map[string]bool)
+	}
+	for name, fn := range funcMap {
+		t.funcNames[name] = true
+		v := reflect.ValueOf(fn)
+		if !isContextFunc(v) {
+			funcs[name] = fn
+			delete(t.contextFuncs, name)
+			continue
+		}
+		if t.contextFuncs == nil {
+			t.contextFuncs = make(map[string]reflect.Value)
+		}
+		t.contextFuncs[name] = v
+		funcs[name] = bindContext(v, stdcontext.Background())
+	}
+	exec := t.nameSpace.exec
+	t.nameSpace.mu.Unlock()
+	t.text.Funcs(funcs)
+	if exec != nil {
+		exec.Funcs(funcs)
+	}
 	return t
 }
 
@@ -409,6 +1223,54 @@ func (t *Template) CSPCompatible() *Template {
 	return t
 }
 
+// AllowURLSchemes causes this template and its associated templates to accept
+// absolute URLs with any of the given schemes, in addition to the schemes allowed
+// by safehtml.URLSanitized, in URL sanitization contexts, both in URL prefixes in
+// template text and in values substituted at run time. The return value is the
+// template, so calls can be chained.
+//
+// This option is security-sensitive: allowing a scheme whose URLs can cause
+// script execution or navigate to privileged pages, such as javascript or
+// chrome, removes the protection that URL sanitization otherwise provides in
+// templates that may render untrusted data. Use it only on templates whose
+// trust requirements allow such URLs.
+//
+// It panics if any scheme does not conform to the scheme grammar in RFC 3986
+// Section 3.1, or if t or any associated template has already been executed.
+func (t *Template) AllowURLSchemes(schemes ...string) *Template {
+	t.nameSpace.mu.Lock()
+	defer t.nameSpace.mu.Unlock()
+	if t.nameSpace.escaped {
+		panic("html/template: cannot AllowURLSchemes after Execute")
+	}
+	c, err := t.nameSpace.urlSanitizer().WithSchemes(schemes...)
+	if err != nil {
+		panic(fmt.Sprintf("html/template: %s", err))
+	}
+	t.nameSpace.urlSanitizerConfig = c
+	return t
+}
+
+// FuncNames returns the sorted names of the functions that templates can call
+// in t and in the templates associated with it: the functions added with Funcs
+// and the functions of this package, such as urlWithParams. It does not
+// include the predefined functions of "text/template", such as len and printf.
+func (t *Template) FuncNames() []string {
+	t.nameSpace.mu.Lock()
+	defer t.nameSpace.mu.Unlock()
+	names := make([]string, 0, len(builtinFuncs)+len(t.funcNames))
+	for name := range builtinFuncs {
+		names = append(names, name)
+	}
+	for name := range t.funcNames {
+		if _, ok := builtinFuncs[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Delims sets the action delimiters to the specified strings, to be used in
 // subsequent calls to Parse, ParseFiles, or ParseGlob. Nested template
 // definitions will inherit the settings. An empty delimiter stands for the
@@ -427,6 +1289,17 @@ func (t *Template) Lookup(name string) *Template {
 	return t.set[name]
 }
 
+// LookupOrError is like Lookup, but returns an error naming the missing
+// template, rather than nil, if there is no template with the given name
+// associated with t. This is useful when name is not a constant, such as when
+// templates are looked up by names computed at run time.
+func (t *Template) LookupOrError(name string) (*Template, error) {
+	if tmpl := t.Lookup(name); tmpl != nil {
+		return tmpl, nil
+	}
+	return nil, fmt.Errorf("html/template: no template %q associated with template %q", name, t.Name())
+}
+
 // Must is a helper that wraps a call to a function returning (*Template, error)
 // and panics if the error is non-nil. It is intended for use in variable initializations
 // such as
@@ -547,6 +1420,9 @@ func parseFiles(t *Template, readFile func(string) (string, []byte, error), file
 		if t == nil {
 			t = New(name)
 		}
+		t.nameSpace.mu.Lock()
+		before := t.nameSpace.parseTrees()
+		t.nameSpace.mu.Unlock()
 		if name == t.Name() {
 			tmpl = t
 		} else {
@@ -554,12 +1430,100 @@ func parseFiles(t *Template, readFile func(string) (string, []byte, error), file
 		}
 		_, err = tmpl.Parse(s)
 		if err != nil {
-			return nil, err
+			// Parse errors only identify the template by name, which is not
+			// unique among files in different directories.
+			return nil, fmt.Errorf("html/template: parsing file %q: %w", filename, err)
 		}
+		t.nameSpace.mu.Lock()
+		t.nameSpace.recordFileTemplates(name, before)
+		t.nameSpace.mu.Unlock()
 	}
 	return t, nil
 }
 
+// isEmptyFileTemplate reports whether the template with the given name and
+// parse tree is the empty template named after the file it was parsed from,
+// as for a file that only contains {{define}} actions. Since an empty parse
+// tree never replaces an existing one, such templates are not recorded as
+// defined by the file.
+func isEmptyFileTemplate(file, name string, tree *parse.Tree) bool {
+	return name == file && parse.IsEmptyTree(tree.Root)
+}
+
+// ReparseFile parses src as the new contents of the file with the given
+// (base) name, which was previously parsed into t or an associated template
+// with ParseFiles or a related function, and returns the template with that
+// name. The templates defined by src replace those of the same name, and
+// templates defined by other files are unchanged, so that a single file that
+// has changed can be reloaded without reading and parsing every file again.
+// If no file with that name was parsed, ReparseFile adds the templates defined
+// by src, as if the file had been among those parsed.
+//
+// Since templates cannot be removed from t, ReparseFile returns an error if
+// src no longer defines a template defined by the previous contents of the
+// file; parse all files again into a new template in that case. If an error
+// occurs, t is unchanged.
+//
+// Like Parse, ReparseFile returns an error if t or any associated template
+// has already been executed. To reload templates while serving, keep a
+// template that is never executed, reparse changed files into it, and execute
+// clones of it:
+//
+//	if _, err := base.ReparseFile(name, src); err != nil {
+//		return err
+//	}
+//	serving, err := base.Clone()
+//
+// To guarantee that the template body is never controlled by an attacker, src
+// is a TrustedTemplate, which is always under programmer control.
+func (t *Template) ReparseFile(name string, src TrustedTemplate) (*Template, error) {
+	if err := t.checkCanParse(); err != nil {
+		return nil, err
+	}
+	t.nameSpace.mu.Lock()
+	defer t.nameSpace.mu.Unlock()
+	// Parse src into a copy of the underlying template set first, so that t is
+	// unchanged if src does not parse or no longer defines a template. The copy
+	// shares the parse trees of t, so trees that differ were defined by src.
+	scratch, err := t.text.Clone()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := scratch.New(name).Parse(src.String()); err != nil {
+		return nil, fmt.Errorf("html/template: parsing file %q: %w", name, newParseError(name, err))
+	}
+	before := t.nameSpace.parseTrees()
+	defined := make(map[string]*parse.Tree)
+	for _, x := range scratch.Templates() {
+		if x.Tree != nil && x.Tree != before[x.Name()] {
+			defined[x.Name()] = x.Tree
+		}
+	}
+	for _, prev := range t.nameSpace.fileTemplates[name] {
+		if defined[prev] == nil && !isEmptyFileTemplate(name, prev, t.set[prev].Tree) {
+			return nil, fmt.Errorf("html/template: reparsing file %q: template %q is no longer defined, and cannot be removed", name, prev)
+		}
+	}
+	for n, tree := range defined {
+		text, err := t.text.AddParseTree(n, tree)
+		if err != nil {
+			return nil, err
+		}
+		tmpl := t.set[n]
+		if tmpl == nil {
+			tmpl = t.new(n)
+		}
+		tmpl.text = text
+		tmpl.Tree = text.Tree
+	}
+	t.nameSpace.recordFileTemplates(name, before)
+	tmpl := t.set[name]
+	if tmpl == nil {
+		tmpl = t.new(name)
+	}
+	return tmpl, nil
+}
+
 // Copied with minor changes from
 // https://go.googlesource.com/go/+/refs/tags/go1.17.1/src/text/template/helper.go.
 func readFileOS(file string) (string, []byte, error) {
@@ -637,7 +1601,7 @@ func parseGlob(t *Template, pattern string) (*Template, error) {
 	}
 	filenames, err := filepath.Glob(pattern)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("html/template: pattern %#q: %w", pattern, err)
 	}
 	if len(filenames) == 0 {
 		return nil, fmt.Errorf("html/template: pattern matches no files: %#q", pattern)
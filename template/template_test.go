@@ -6,13 +6,20 @@ package template
 
 import (
 	"bytes"
+	stdcontext "context"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"testing/fstest"
+
+	"github.com/google/safehtml"
 )
 
 const tmplText = "foo"
@@ -53,6 +60,29 @@ func TestMustParseAndExecuteToHTML(t *testing.T) {
 	}
 }
 
+func TestExecuteTemplateToHTML(t *testing.T) {
+	tmpl := Must(New("page").Parse(`{{define "card"}}<div class="card">{{.}}</div>{{end}}<main>{{.}}</main>`))
+	card, err := tmpl.ExecuteTemplateToHTML("card", "<script>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := card.String(), `<div class="card">&lt;script&gt;</div>`; got != want {
+		t.Errorf("ExecuteTemplateToHTML(%q) = %q, want %q", "card", got, want)
+	}
+	// The rendered block is interpolated into HTML content without being
+	// escaped again.
+	page, err := tmpl.ExecuteToHTML(card)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := page.String(), `<main><div class="card">&lt;script&gt;</div></main>`; got != want {
+		t.Errorf("ExecuteToHTML(card) = %q, want %q", got, want)
+	}
+	if _, err := tmpl.ExecuteTemplateToHTML("missing", nil); err == nil {
+		t.Errorf("ExecuteTemplateToHTML(%q) succeeded, want error", "missing")
+	}
+}
+
 func TestTemplateClone(t *testing.T) {
 	// https://golang.org/issue/12996
 	orig := New("name")
@@ -97,6 +127,25 @@ func TestLookup(t *testing.T) {
 	}
 }
 
+func TestLookupOrError(t *testing.T) {
+	tmpl := Must(New("test").Parse(tmpl1))
+	a, err := tmpl.LookupOrError("a")
+	if err != nil {
+		t.Fatalf(`LookupOrError("a") failed: %v`, err)
+	}
+	if a != tmpl.Lookup("a") {
+		t.Errorf(`LookupOrError("a") = %v, want %v`, a, tmpl.Lookup("a"))
+	}
+	c, err := tmpl.LookupOrError("c")
+	if c != nil {
+		t.Errorf(`LookupOrError("c") = %v, want nil`, c)
+	}
+	const want = `html/template: no template "c" associated with template "test"`
+	if err == nil || err.Error() != want {
+		t.Errorf(`LookupOrError("c") returned error %v, want %q`, err, want)
+	}
+}
+
 func TestTemplates(t *testing.T) {
 	// want maps template name to expected output.
 	want := map[string]string{
@@ -166,6 +215,208 @@ func TestParseFiles(t *testing.T) {
 	}
 }
 
+func TestParseFilesErrorIncludesFilename(t *testing.T) {
+	dir1, dir2 := createTestDirAndFile(filename), createTestDirAndFile(filename)
+	defer os.RemoveAll(dir1)
+	defer os.RemoveAll(dir2)
+	bad := filepath.Join(dir2, filename)
+	if err := ioutil.WriteFile(bad, []byte(`{{ if }}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := New("root").ParseFiles(stringConstant(filepath.Join(dir1, filename)), stringConstant(bad))
+	if err == nil {
+		t.Fatalf("expected parse error")
+	}
+	// The error identifies the file, not only its base name, which is shared
+	// by both files.
+	if want := fmt.Sprintf("html/template: parsing file %q: template: T1.tmpl:1: missing value for if", bad); err.Error() != want {
+		t.Errorf("got error:\n\t%s\nwant:\n\t%s", err, want)
+	}
+
+	_, err = ParseGlob(stringConstant(filepath.Join(dir2, "T*.tmpl")))
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("parsing file %q", bad)) {
+		t.Errorf("ParseGlob: got error %v, want error naming %q", err, bad)
+	}
+	_, err = ParseGlob("[")
+	if want := "html/template: pattern `[`: syntax error in pattern"; err == nil || err.Error() != want {
+		t.Errorf("ParseGlob: got error %v, want %s", err, want)
+	}
+}
+
+func TestReparseFile(t *testing.T) {
+	tfs := trustedFSRaw(fstest.MapFS{
+		"page.tmpl":   {Data: []byte(`{{template "header"}}<p>{{block "body" .}}old {{.}}{{end}}</p>`)},
+		"header.tmpl": {Data: []byte(`{{define "header"}}<h1>Header</h1>{{end}}`)},
+	})
+	base := Must(New("page.tmpl").ParseFSFiles(tfs, "page.tmpl", "header.tmpl"))
+	header := base.Lookup("header")
+
+	page, err := base.ReparseFile("page.tmpl", MakeTrustedTemplate(`{{template "header"}}<p>{{block "body" .}}new {{.}}{{end}}</p>{{define "footer"}}footer{{end}}`))
+	if err != nil {
+		t.Fatalf("ReparseFile failed: %v", err)
+	}
+	if page != base {
+		t.Errorf("ReparseFile returned %v, want %v", page, base)
+	}
+	if base.Lookup("header") != header {
+		t.Errorf("ReparseFile replaced the header template, which is defined by another file")
+	}
+	if base.Lookup("footer") == nil {
+		t.Errorf("ReparseFile did not add the footer template")
+	}
+	serving := Must(base.Clone())
+	var b bytes.Buffer
+	if err := serving.Execute(&b, "<x>"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), `<h1>Header</h1><p>new &lt;x&gt;</p>`; got != want {
+		t.Errorf("after ReparseFile, got %q, want %q", got, want)
+	}
+
+	// Templates defined by the previous contents of a file cannot be removed.
+	const wantErr = `html/template: reparsing file "page.tmpl": template "footer" is no longer defined, and cannot be removed`
+	if _, err := base.ReparseFile("page.tmpl", MakeTrustedTemplate(`{{block "body" .}}x{{end}}`)); err == nil || err.Error() != wantErr {
+		t.Errorf("ReparseFile returned error %v, want %q", err, wantErr)
+	}
+	// Errors leave the template unchanged.
+	if _, err := base.ReparseFile("page.tmpl", MakeTrustedTemplate(`{{define "body"}}broken{{end}}{{if}}`)); err == nil {
+		t.Errorf("ReparseFile succeeded on a template with a syntax error")
+	}
+	b.Reset()
+	if err := Must(base.Clone()).ExecuteTemplate(&b, "body", "y"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), "new y"; got != want {
+		t.Errorf("after failed ReparseFile, got %q, want %q", got, want)
+	}
+
+	// A file that was not parsed before adds its templates.
+	if _, err := base.ReparseFile("extra.tmpl", MakeTrustedTemplate(`{{define "extra"}}extra{{end}}`)); err != nil {
+		t.Fatalf("ReparseFile failed: %v", err)
+	}
+	if base.Lookup("extra") == nil {
+		t.Errorf("ReparseFile did not add the extra template")
+	}
+
+	if _, err := serving.ReparseFile("header.tmpl", MakeTrustedTemplate(`{{define "header"}}new{{end}}`)); err == nil || !strings.Contains(err.Error(), "Execute") {
+		t.Errorf("ReparseFile after Execute: got error %v, want error about already having executed", err)
+	}
+}
+
+func TestReparseDefineOnlyFile(t *testing.T) {
+	files := map[string]string{
+		"page.tmpl": `{{template "a"}} {{template "b"}}`,
+		"a.tmpl":    `{{define "a"}}A{{end}}`,
+		"b.tmpl":    `{{define "b"}}B{{end}}`,
+	}
+	fsys := make(fstest.MapFS)
+	for name, data := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(data)}
+	}
+	tfs := trustedFSRaw(fsys)
+	for _, test := range [...]struct {
+		desc  string
+		parse func() (*Template, error)
+	}{
+		{"ParseFS", func() (*Template, error) { return ParseFS(tfs, "page.tmpl", "a.tmpl", "b.tmpl") }},
+		{"ParseFiles", func() (*Template, error) {
+			dir, err := ioutil.TempDir("", "template")
+			if err != nil {
+				return nil, err
+			}
+			defer os.RemoveAll(dir)
+			var filenames []stringConstant
+			for _, name := range []string{"page.tmpl", "a.tmpl", "b.tmpl"} {
+				filename := filepath.Join(dir, name)
+				if err := ioutil.WriteFile(filename, []byte(files[name]), 0644); err != nil {
+					return nil, err
+				}
+				filenames = append(filenames, stringConstant(filename))
+			}
+			return ParseFiles(filenames...)
+		}},
+	} {
+		base, err := test.parse()
+		if err != nil {
+			t.Fatalf("%s: %v", test.desc, err)
+		}
+		if _, err := base.ReparseFile("a.tmpl", MakeTrustedTemplate(`{{define "a"}}A2{{end}}`)); err != nil {
+			t.Fatalf("%s: ReparseFile failed: %v", test.desc, err)
+		}
+		var b bytes.Buffer
+		if err := Must(base.Clone()).Execute(&b, nil); err != nil {
+			t.Fatalf("%s: %v", test.desc, err)
+		}
+		if got, want := b.String(), "A2 B"; got != want {
+			t.Errorf("%s: after ReparseFile, got %q, want %q", test.desc, got, want)
+		}
+		// The define-only file must still define its template.
+		const wantErr = `html/template: reparsing file "a.tmpl": template "a" is no longer defined, and cannot be removed`
+		if _, err := base.ReparseFile("a.tmpl", MakeTrustedTemplate(``)); err == nil || err.Error() != wantErr {
+			t.Errorf("%s: ReparseFile returned error %v, want %q", test.desc, err, wantErr)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for _, test := range [...]struct {
+		text stringConstant
+		want ParseError
+	}{
+		{`{{ if }}`, ParseError{Template: "page", Line: 1, Description: "missing value for if"}},
+		{"<p>\n\n{{ .X }}\n{{ .Y", ParseError{Template: "page", Line: 4, Description: "unclosed action"}},
+		{"{{ define \"row\" }}\n<td>{{ nofunc }}</td>\n{{ end }}", ParseError{Template: "page", Line: 2, Description: `function "nofunc" not defined`}},
+		{"a\n{{ end }}", ParseError{Template: "page", Line: 2, Description: "unexpected {{end}}"}},
+	} {
+		_, err := New("page").Parse(test.text)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("Parse(%q) returned error %v, want *ParseError", test.text, err)
+			continue
+		}
+		if got := *parseErr; got.Template != test.want.Template || got.Line != test.want.Line || got.Column != test.want.Column || got.Description != test.want.Description {
+			t.Errorf("Parse(%q) returned %+v, want %+v", test.text, got, test.want)
+		}
+		// The error message is that of the text/template parser.
+		if want := fmt.Sprintf("template: %s:%d: %s", test.want.Template, test.want.Line, test.want.Description); err.Error() != want {
+			t.Errorf("Parse(%q) returned error %q, want %q", test.text, err, want)
+		}
+		if errors.Unwrap(err) == nil {
+			t.Errorf("Parse(%q) returned error %q that does not wrap the parser error", test.text, err)
+		}
+	}
+}
+
+func TestParseErrorFiles(t *testing.T) {
+	dir := createTestDirAndFile(filename)
+	defer os.RemoveAll(dir)
+	bad := filepath.Join(dir, filename)
+	if err := ioutil.WriteFile(bad, []byte("<p>\n{{ .X }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ParseFiles(stringConstant(bad))
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("ParseFiles returned error %v, want an error wrapping *ParseError", err)
+	}
+	if parseErr.Template != filename || parseErr.Line != 2 || parseErr.Description == "" {
+		t.Errorf("ParseFiles returned %+v, want an error in %s on line 2", *parseErr, filename)
+	}
+}
+
+func TestNewParseError(t *testing.T) {
+	// Unknown messages are reported with the name of the parsed template.
+	err := newParseError("page", errors.New("unexpected failure"))
+	if want := (ParseError{Template: "page", Description: "unexpected failure"}); err.Template != want.Template || err.Line != 0 || err.Column != 0 || err.Description != want.Description {
+		t.Errorf("newParseError returned %+v, want %+v", *err, want)
+	}
+	// Columns are parsed when present.
+	err = newParseError("page", errors.New("template: row:3:14: bad character"))
+	if want := (ParseError{Template: "row", Line: 3, Column: 14, Description: "bad character"}); err.Template != want.Template || err.Line != want.Line || err.Column != want.Column || err.Description != want.Description {
+		t.Errorf("newParseError returned %+v, want %+v", *err, want)
+	}
+}
+
 func TestParseGlob(t *testing.T) {
 	dir := createTestDirAndFile(filename)
 	tmpl := New("root")
@@ -185,3 +436,818 @@ func TestDontAllowJSTemplateSubstitution(t *testing.T) {
 		t.Errorf("Parsed template %v, got error %v, expected %v", template, err, want)
 	}
 }
+
+func TestAllowURLSchemes(t *testing.T) {
+	const text = `<a href="{{ .URL }}">a</a><a href="chrome://{{ .Page }}">b</a>{{ template "sub" . }}`
+	const sub = `{{ define "sub" }}<a href="{{ .URL }}">c</a>{{ end }}`
+	data := struct{ URL, Page string }{"chrome://settings", "about"}
+
+	// The default allowlist rejects the chrome: prefix at escape time.
+	var b bytes.Buffer
+	tmpl := Must(Must(New("default").Parse(text)).Parse(sub))
+	if err := tmpl.Execute(&b, data); err == nil || !strings.Contains(err.Error(), `URL prefix "chrome://" contains an unsafe scheme`) {
+		t.Errorf("default template: got error %v, want unsafe scheme error", err)
+	}
+
+	tmpl = Must(Must(New("admin").AllowURLSchemes("chrome", "TEL").Parse(text)).Parse(sub))
+	clone := Must(tmpl.Clone())
+	want := `<a href="chrome://settings">a</a><a href="chrome://about">b</a><a href="chrome://settings">c</a>`
+	for _, tt := range []*Template{tmpl, clone} {
+		b.Reset()
+		if err := tt.Execute(&b, data); err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.Name(), err)
+		} else if got := b.String(); got != want {
+			t.Errorf("%s: got:\n\t%s\nwant:\n\t%s", tt.Name(), got, want)
+		}
+	}
+
+	// Other schemes are still disallowed.
+	b.Reset()
+	if err := tmpl.Execute(&b, struct{ URL, Page string }{"javascript:alert(1)", "about"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), `<a href="about:invalid#zGoSafez">a</a><a href="chrome://about">b</a><a href="about:invalid#zGoSafez">c</a>`; got != want {
+		t.Errorf("got:\n\t%s\nwant:\n\t%s", got, want)
+	}
+
+	// Unrelated templates keep the default allowlist.
+	b.Reset()
+	if err := Must(New("public").Parse(`<a href="{{ . }}">a</a>`)).Execute(&b, "chrome://settings"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), `<a href="about:invalid#zGoSafez">a</a>`; got != want {
+		t.Errorf("got:\n\t%s\nwant:\n\t%s", got, want)
+	}
+}
+
+func TestAllowURLSchemesPanics(t *testing.T) {
+	for _, test := range [...]struct {
+		desc string
+		f    func()
+		want string
+	}{
+		{
+			"invalid scheme",
+			func() { New("t").AllowURLSchemes("chrome:") },
+			`scheme "chrome:" does not conform`,
+		},
+		{
+			"after execution",
+			func() {
+				tmpl := Must(New("t").Parse(`foo`))
+				tmpl.Execute(ioutil.Discard, nil)
+				tmpl.AllowURLSchemes("chrome")
+			},
+			"cannot AllowURLSchemes after Execute",
+		},
+	} {
+		func() {
+			defer func() {
+				r := recover()
+				if r == nil || !strings.Contains(fmt.Sprint(r), test.want) {
+					t.Errorf("%s: got panic %v, want panic containing %q", test.desc, r, test.want)
+				}
+			}()
+			test.f()
+		}()
+	}
+}
+
+// countingFlusher records the writes made between flushes.
+type countingFlusher struct {
+	bytes.Buffer
+	flushed []string
+}
+
+func (f *countingFlusher) Flush() {
+	f.flushed = append(f.flushed, f.String()[len(strings.Join(f.flushed, "")):])
+}
+
+func TestExecuteStream(t *testing.T) {
+	const text = `<ul>{{ range . }}<li><a href="{{ .URL }}" title="{{ .Title }}">{{ .Title }}</a></li>{{ end }}</ul>`
+	data := []struct{ URL, Title string }{
+		{"https://example.com/?a=b&c=d", `"quoted" <title>`},
+		{"javascript:alert(1)", "evil"},
+	}
+	tmpl := Must(New("t").Parse(text))
+	var want bytes.Buffer
+	if err := tmpl.Execute(&want, data); err != nil {
+		t.Fatal(err)
+	}
+
+	var f countingFlusher
+	if err := tmpl.ExecuteStream(&f, data); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.String(); got != want.String() {
+		t.Errorf("ExecuteStream output differs from Execute:\n\t%s\nwant:\n\t%s", got, want.String())
+	}
+	if got := strings.Join(f.flushed, ""); got != want.String() {
+		t.Errorf("flushed output:\n\t%s\nwant:\n\t%s", got, want.String())
+	}
+	if len(f.flushed) < 2 {
+		t.Errorf("got %d flushes, want output to be flushed incrementally", len(f.flushed))
+	}
+
+	// Writers without a Flush method are written to directly.
+	var b bytes.Buffer
+	if err := tmpl.ExecuteStream(&b, data); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); got != want.String() {
+		t.Errorf("ExecuteStream output differs from Execute:\n\t%s\nwant:\n\t%s", got, want.String())
+	}
+
+	// Escaping errors are reported before anything is written.
+	f = countingFlusher{}
+	err := Must(New("t").Parse(`<a href="java{{ . }}">`)).ExecuteStream(&f, "script:alert(1)")
+	if err == nil {
+		t.Errorf("expected escaping error")
+	}
+	if f.Len() != 0 {
+		t.Errorf("got output %q, want none", f.String())
+	}
+	var streamErr *StreamError
+	if !errors.As(err, &streamErr) || streamErr.Written != 0 {
+		t.Errorf("got error %#v, want *StreamError with nothing written", err)
+	}
+}
+
+func TestExecuteStreamFlushes(t *testing.T) {
+	tmpl := Must(New("t").Parse(`<h1>{{ .Title }}</h1>{{ template "list" .Items }}{{ if .Footer }}<footer>{{ .Footer }}</footer>{{ end }}` +
+		`{{ define "list" }}<ul>{{ range . }}<li>{{ . }}</li>{{ end }}</ul>{{ end }}`))
+	data := map[string]interface{}{
+		"Title":  "T",
+		"Items":  []string{"a", "b"},
+		"Footer": "F",
+	}
+	for _, test := range [...]struct {
+		name string
+		want []string
+	}{
+		// Output is flushed after each top-level action of the executed
+		// template only, and not after every write.
+		{"t", []string{`<h1>T`, `</h1><ul><li>a</li><li>b</li></ul>`, `<footer>F</footer>`}},
+		{"list", []string{`<ul><li>a</li><li>b</li>`, `</ul>`}},
+	} {
+		var f countingFlusher
+		var d interface{} = data
+		if test.name == "list" {
+			d = data["Items"]
+		}
+		if err := tmpl.Lookup(test.name).ExecuteStream(&f, d); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(f.flushed, test.want) {
+			t.Errorf("%s: got flushes %q, want %q", test.name, f.flushed, test.want)
+		}
+	}
+
+	// Long top-level actions are flushed whenever the buffer fills up.
+	items := make([]int, 2000)
+	var f countingFlusher
+	if err := Must(New("t").Parse(`<ul>{{ range . }}<li>{{ . }}</li>{{ end }}`)).ExecuteStream(&f, items); err != nil {
+		t.Fatal(err)
+	}
+	if min := f.Len() / streamBufferSize; len(f.flushed) < min {
+		t.Errorf("got %d flushes of %d bytes, want at least %d", len(f.flushed), f.Len(), min)
+	}
+	for _, chunk := range f.flushed {
+		if len(chunk) > streamBufferSize {
+			t.Errorf("got flush of %d bytes, want at most %d", len(chunk), streamBufferSize)
+		}
+	}
+}
+
+func TestExecuteStreamError(t *testing.T) {
+	errFailed := errors.New("failed")
+	tmpl := Must(New("t").Funcs(FuncMap{
+		"check": func(ok bool) (string, error) {
+			if !ok {
+				return "", errFailed
+			}
+			return "ok", nil
+		},
+	}).Parse(`<ul>{{ range . }}<li>{{ check . }}</li>{{ end }}</ul>`))
+
+	var f countingFlusher
+	err := tmpl.ExecuteStream(&f, []bool{true, true, false, true})
+	var streamErr *StreamError
+	if !errors.As(err, &streamErr) {
+		t.Fatalf("got error %v, want *StreamError", err)
+	}
+	const wantOutput = `<ul><li>ok</li><li>ok</li><li>`
+	if got := f.String(); got != wantOutput {
+		t.Errorf("got output %q, want %q", got, wantOutput)
+	}
+	if streamErr.Written != int64(len(wantOutput)) {
+		t.Errorf("got Written = %d, want %d", streamErr.Written, len(wantOutput))
+	}
+	if !errors.Is(err, errFailed) {
+		t.Errorf("got error %v, want error wrapping %v", err, errFailed)
+	}
+	if want := fmt.Sprintf("(after writing %d bytes of output)", len(wantOutput)); !strings.HasSuffix(err.Error(), want) {
+		t.Errorf("got error %q, want error ending in %q", err, want)
+	}
+
+	// Write errors are reported with the bytes written before them.
+	w := &limitedWriter{limit: 10}
+	err = tmpl.ExecuteStream(w, []bool{true, true})
+	if !errors.As(err, &streamErr) || !errors.Is(err, errLimit) || streamErr.Written != 10 {
+		t.Errorf("got error %#v, want *StreamError wrapping %v with 10 bytes written", err, errLimit)
+	}
+}
+
+var errLimit = errors.New("write limit exceeded")
+
+// limitedWriter accepts at most limit bytes.
+type limitedWriter struct {
+	limit int
+	b     bytes.Buffer
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if n := w.limit - w.b.Len(); len(p) > n {
+		w.b.Write(p[:n])
+		return n, errLimit
+	}
+	return w.b.Write(p)
+}
+
+func benchmarkReport(b *testing.B, execute func(*Template, interface{}) error) {
+	tmpl := Must(New("report").Parse(`<table>{{ range . }}<tr><td><a href="{{ .URL }}">{{ .Title }}</a></td><td>{{ .Title }}</td></tr>{{ end }}</table>`))
+	rows := make([]struct{ URL, Title string }, 10000)
+	for i := range rows {
+		rows[i].URL = fmt.Sprintf("https://example.com/row/%d?q=a&b", i)
+		rows[i].Title = fmt.Sprintf("<row %d>", i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := execute(tmpl, rows); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// discardFlusher is an io.Writer with a Flush method that discards its output
+// and counts flushes.
+type discardFlusher struct {
+	flushes int
+}
+
+func (f *discardFlusher) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (f *discardFlusher) Flush() {
+	f.flushes++
+}
+
+func BenchmarkExecuteStreamReport(b *testing.B) {
+	var f discardFlusher
+	benchmarkReport(b, func(tmpl *Template, data interface{}) error {
+		return tmpl.ExecuteStream(&f, data)
+	})
+	b.ReportMetric(float64(f.flushes)/float64(b.N), "flushes/op")
+}
+
+func BenchmarkExecuteToHTMLReport(b *testing.B) {
+	benchmarkReport(b, func(tmpl *Template, data interface{}) error {
+		html, err := tmpl.ExecuteToHTML(data)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(ioutil.Discard, html.String())
+		return err
+	})
+}
+
+func benchmarkManyTemplates(b *testing.B, execute func(*Template) error) {
+	var src strings.Builder
+	src.WriteString(`<ul>{{ template "item0" . }}{{ template "item1" . }}</ul>`)
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&src, `{{ define "item%d" }}<li title="{{ . }}">%d</li>{{ end }}`, i, i)
+	}
+	tmpl := Must(New("page").Parse(stringConstant(src.String())))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := execute(tmpl); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkExecuteStreamManyTemplates(b *testing.B) {
+	benchmarkManyTemplates(b, func(tmpl *Template) error {
+		return tmpl.ExecuteStream(ioutil.Discard, "a")
+	})
+}
+
+func BenchmarkExecuteContextManyTemplates(b *testing.B) {
+	ctx, cancel := stdcontext.WithCancel(stdcontext.Background())
+	defer cancel()
+	benchmarkManyTemplates(b, func(tmpl *Template) error {
+		return tmpl.ExecuteContext(ctx, ioutil.Discard, "a")
+	})
+}
+
+type ctxKey struct{}
+
+func TestExecuteContext(t *testing.T) {
+	tmpl := Must(New("t").Funcs(FuncMap{
+		"user": func(ctx stdcontext.Context, prefix string) string {
+			if v, ok := ctx.Value(ctxKey{}).(string); ok {
+				return prefix + v
+			}
+			return prefix + "anonymous"
+		},
+		"join": func(ctx stdcontext.Context, sep string, elems ...string) string {
+			return strings.Join(elems, sep)
+		},
+	}).Parse(`<b>{{ user "@" }}</b>{{ join "," "a" "<b>" }}`))
+
+	ctx := stdcontext.WithValue(stdcontext.Background(), ctxKey{}, "<gopher>")
+	var b bytes.Buffer
+	if err := tmpl.ExecuteContext(ctx, &b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), `<b>@&lt;gopher&gt;</b>a,&lt;b&gt;`; got != want {
+		t.Errorf("got:\n\t%s\nwant:\n\t%s", got, want)
+	}
+
+	// Other execution methods pass stdcontext.Background().
+	b.Reset()
+	if err := tmpl.Execute(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), `<b>@anonymous</b>a,&lt;b&gt;`; got != want {
+		t.Errorf("got:\n\t%s\nwant:\n\t%s", got, want)
+	}
+
+	// Clones keep context-aware functions.
+	clone := Must(Must(New("c").Funcs(FuncMap{
+		"user": func(ctx stdcontext.Context) string { return ctx.Value(ctxKey{}).(string) },
+	}).Parse(`{{ user }}`)).Clone())
+	b.Reset()
+	if err := clone.ExecuteContext(ctx, &b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), `&lt;gopher&gt;`; got != want {
+		t.Errorf("got:\n\t%s\nwant:\n\t%s", got, want)
+	}
+}
+
+func TestExecuteContextCancel(t *testing.T) {
+	ctx, cancel := stdcontext.WithCancel(stdcontext.Background())
+	calls := 0
+	tmpl := Must(New("t").Funcs(FuncMap{
+		"slow": func(ctx stdcontext.Context, i int) int {
+			calls++
+			if i == 2 {
+				cancel()
+			}
+			return i
+		},
+	}).Parse(`{{ range . }}<li>{{ slow . }}</li>{{ end }}`))
+
+	var b bytes.Buffer
+	err := tmpl.ExecuteContext(ctx, &b, []int{0, 1, 2, 3, 4, 5})
+	if err != stdcontext.Canceled {
+		t.Fatalf("got error %v, want %v", err, stdcontext.Canceled)
+	}
+	if got, want := b.String(), `<li>0</li><li>1</li><li>`; got != want {
+		t.Errorf("got partial output %q, want %q", got, want)
+	}
+	if calls != 3 {
+		t.Errorf("got %d calls, want execution to stop after the third", calls)
+	}
+
+	// Already-cancelled contexts produce no output.
+	b.Reset()
+	if err := tmpl.ExecuteContext(ctx, &b, []int{0}); err != stdcontext.Canceled {
+		t.Errorf("got error %v, want %v", err, stdcontext.Canceled)
+	}
+	if b.Len() != 0 {
+		t.Errorf("got output %q, want none", b.String())
+	}
+
+	// Ranges and pipelines that produce no output are interrupted too.
+	items := make([]int, 100)
+	for i := range items {
+		items[i] = i
+	}
+	for _, text := range []stringConstant{
+		`{{ range . }}{{ $x := work . }}{{ end }}`,
+		`{{ range . }}{{ if work . }}x{{ end }}{{ end }}`,
+		`{{ range . }}{{ with work . }}x{{ end }}{{ end }}`,
+		`{{ range . }}{{ template "sub" . }}{{ end }}{{ define "sub" }}{{ if work . }}x{{ end }}{{ end }}`,
+	} {
+		for _, opts := range [][]ExecuteOption{nil, {AddCSPNonce("n")}} {
+			ctx, cancel := stdcontext.WithCancel(stdcontext.Background())
+			calls := 0
+			tmpl := Must(New("t").Funcs(FuncMap{
+				"work": func(i int) bool {
+					calls++
+					if i == 2 {
+						cancel()
+					}
+					return false
+				},
+			}).Parse(text))
+			var err error
+			if opts == nil {
+				err = tmpl.ExecuteContext(ctx, &b, items)
+			} else {
+				err = tmpl.ExecuteWithOptions(ctx, &b, items, opts...)
+			}
+			if err != stdcontext.Canceled {
+				t.Errorf("%s: got error %v, want %v", text, err, stdcontext.Canceled)
+			}
+			if calls != 3 {
+				t.Errorf("%s: got %d calls, want execution to stop after the third", text, calls)
+			}
+			cancel()
+		}
+	}
+}
+
+func TestInsertedActionsInDerivedTemplates(t *testing.T) {
+	// Executing "url" inserts actions into its tree, which must not be
+	// escaped when "url" is later called in another context.
+	tmpl := Must(New("page").Parse(`<script src="{{ template "url" . }}"></script>` +
+		`{{ define "url" }}{{ if . }}/a.js{{ end }}{{ if . }}?v=1{{ end }}{{ end }}`))
+	var b bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&b, "url", true); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), `/a.js?v=1`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	ctx, cancel := stdcontext.WithCancel(stdcontext.Background())
+	defer cancel()
+	for _, execute := range []func() error{
+		func() error { return tmpl.Execute(&b, true) },
+		func() error { return tmpl.ExecuteStream(&b, true) },
+		func() error { return tmpl.ExecuteContext(ctx, &b, true) },
+	} {
+		b.Reset()
+		if err := execute(); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := b.String(), `<script src="/a.js?v=1"></script>`; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestExecuteLeavesTreesUnchanged(t *testing.T) {
+	tmpl := Must(New("page").Parse(`<p>{{ range . }}{{ template "item" . }}{{ end }}</p>{{ . }}` +
+		`{{ define "item" }}{{ if . }}<b>{{ . }}</b>{{ end }}{{ end }}`))
+	ctx, cancel := stdcontext.WithCancel(stdcontext.Background())
+	defer cancel()
+	var b bytes.Buffer
+	if err := tmpl.ExecuteStream(&b, []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpl.ExecuteContext(ctx, &b, []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range tmpl.Templates() {
+		for _, name := range []string{checkContextFuncName, streamFlushFuncName} {
+			if text := x.Tree.Root.String(); strings.Contains(text, name) {
+				t.Errorf("tree of %q contains %s: %s", x.Name(), name, text)
+			}
+		}
+	}
+	if got, want := b.String(), `<p><b>a</b></p>[a]<p><b>a</b></p>[a]`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCSPNonceInDerivedTemplate(t *testing.T) {
+	// Executing "style" inserts an action that outputs the nonce attribute,
+	// which must not be output when "style" is later called in an attribute
+	// value.
+	tmpl := Must(New("page").Parse(`<p title="{{ template "style" }}"></p>` +
+		`{{ define "style" }}<style>p {}</style>{{ end }}`))
+	var b bytes.Buffer
+	if err := tmpl.Lookup("style").ExecuteWithOptions(stdcontext.Background(), &b, nil, AddCSPNonce("n")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), `<style nonce="n">p {}</style>`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	b.Reset()
+	if err := tmpl.ExecuteWithOptions(stdcontext.Background(), &b, nil, AddCSPNonce("n")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), `<p title="<style>p {}</style>"></p>`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestAddCSPNonce(t *testing.T) {
+	tmpl := Must(New("t").Parse(`<script src="{{ .Src }}"></script>` +
+		`{{ if .Style }}<STYLE>p { color: red }</style>{{ end }}` +
+		`{{ template "sub" . }}` +
+		`<p title="<script>">script {{ .Text }}</p><scripts></scripts><link rel="stylesheet" href="/s.css"><style></style><script></script>` +
+		`{{ define "sub" }}<script type="application/json">{{ .Text }}</script>{{ end }}`))
+	data := map[string]interface{}{
+		"Src":   safehtml.TrustedResourceURLFromConstant("/app.js"),
+		"Style": true,
+		"Text":  "<script>",
+	}
+	const want = `<script src="/app.js"{{nonce}}></script><STYLE{{nonce}}>p { color: red }</style>` +
+		`<script type="application/json"{{nonce}}>"\u003cscript\u003e"</script>` +
+		`<p title="<script>">script &lt;script&gt;</p><scripts></scripts><link rel="stylesheet" href="/s.css"><style{{nonce}}></style><script{{nonce}}></script>`
+	for _, test := range [...]struct {
+		nonce string
+		attr  string
+	}{
+		{"", ""},
+		{"rAnd0m+/Nonce==", ` nonce="rAnd0m+/Nonce=="`},
+		{"other", ` nonce="other"`},
+	} {
+		var b bytes.Buffer
+		var opts []ExecuteOption
+		if test.nonce != "" {
+			opts = append(opts, AddCSPNonce(test.nonce))
+		}
+		if err := tmpl.ExecuteWithOptions(stdcontext.Background(), &b, data, opts...); err != nil {
+			t.Fatalf("nonce %q: %v", test.nonce, err)
+		}
+		if got, want := b.String(), strings.ReplaceAll(want, "{{nonce}}", test.attr); got != want {
+			t.Errorf("nonce %q: got:\n\t%s\nwant:\n\t%s", test.nonce, got, want)
+		}
+	}
+
+	// The nonce only applies to the execution it is passed to.
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "nonce") {
+		t.Errorf("Execute output %q contains a nonce", b.String())
+	}
+
+	for _, nonce := range []string{`"><script>`, "a b", "a&amp;", "a=b", "a==="} {
+		if err := tmpl.ExecuteWithOptions(stdcontext.Background(), &b, data, AddCSPNonce(nonce)); err == nil {
+			t.Errorf("nonce %q: expected error", nonce)
+		}
+	}
+
+	// Start tags that already have a nonce attribute keep it.
+	tmpl = Must(New("t").Parse(`<script nonce="static"></script><style NONCE="{{ .Nonce }}"></style>` +
+		`<script src="{{ .Src }}" nonce="{{ .Nonce }}"></script><script></script>`))
+	data["Nonce"] = "own"
+	b.Reset()
+	if err := tmpl.ExecuteWithOptions(stdcontext.Background(), &b, data, AddCSPNonce("n")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), `<script nonce="static"></script><style NONCE="own"></style>`+
+		`<script src="/app.js" nonce="own"></script><script nonce="n"></script>`; got != want {
+		t.Errorf("got:\n\t%s\nwant:\n\t%s", got, want)
+	}
+	// A nonce attribute must be in every branch of a start tag or in none.
+	tmpl = Must(New("t").Parse(`<script {{ if .Style }}nonce="static"{{ end }}></script>`))
+	if err := tmpl.Execute(&b, data); err == nil || !strings.Contains(err.Error(), "branches end in different contexts") {
+		t.Errorf("got error %v, want error about branches ending in different contexts", err)
+	}
+}
+
+func TestAddSubresourceIntegrity(t *testing.T) {
+	const (
+		sha256 = "sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+		sha384 = "sha384-oqVuAfXRKap7fdgcCY5uykM6+R9GqQ8K/uxy9rx7HNQlGYl1kPzQho1wx4JwY8wC"
+		sha512 = "sha512-z4PhNX7vuL3xVChQ1m2AB9Yg5AULVxXcg/SpIdNs6c5H0NE8XYXysP+DGNKHfuwvY7kxvUdBeoGlODJ6+SfaPg=="
+	)
+	tmpl := Must(New("t").Parse(`<script src="{{ .Src }}"></script>` +
+		`<script src="/other.js"></script>` +
+		`<SCRIPT async src="https://cdn.example.com/lib.js?v=1&amp;x=2">f()</SCRIPT>` +
+		`<link rel="stylesheet" href="/css/{{ .Name }}.css"/>` +
+		`{{ range .Srcs }}<script src="{{ . }}" ></script>{{ end }}` +
+		`{{ template "sub" . }}` +
+		`<img src="{{ .Src }}"><a href="/other.js">{{ .Text }}</a><script>f()</script>` +
+		`{{ define "sub" }}<script src="{{ if .Src }}/other.js{{ else }}/app.js{{ end }}"></script>{{ end }}`))
+	data := map[string]interface{}{
+		"Src":  safehtml.TrustedResourceURLFromConstant("/app.js"),
+		"Name": "site",
+		"Srcs": []safehtml.TrustedResourceURL{
+			safehtml.TrustedResourceURLFromConstant("/other.js"),
+			safehtml.TrustedResourceURLFromConstant("/app.js"),
+		},
+		"Text": "x",
+	}
+	hashes := map[safehtml.TrustedResourceURL]string{
+		safehtml.TrustedResourceURLFromConstant("/app.js"):                                sha256,
+		safehtml.TrustedResourceURLFromConstant("https://cdn.example.com/lib.js?v=1&x=2"): sha384,
+		safehtml.TrustedResourceURLFromConstant("/css/site.css"):                          sha512,
+	}
+	want := `<script src="/app.js" integrity="` + sha256 + `" crossorigin="anonymous"></script>` +
+		`<script src="/other.js"></script>` +
+		`<SCRIPT async src="https://cdn.example.com/lib.js?v=1&amp;x=2" integrity="` + sha384 + `" crossorigin="anonymous">f()</SCRIPT>` +
+		`<link rel="stylesheet" href="/css/site.css" integrity="` + sha512 + `" crossorigin="anonymous"/>` +
+		`<script src="/other.js" ></script>` +
+		`<script src="/app.js"  integrity="` + sha256 + `" crossorigin="anonymous"></script>` +
+		`<script src="/other.js"></script>` +
+		`<img src="/app.js"><a href="/other.js">x</a><script>f()</script>`
+	var b bytes.Buffer
+	if err := tmpl.ExecuteWithOptions(stdcontext.Background(), &b, data, AddSubresourceIntegrity(hashes)); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); got != want {
+		t.Errorf("got:\n\t%s\nwant:\n\t%s", got, want)
+	}
+
+	// The hashes only apply to the execution they are passed to.
+	b.Reset()
+	if err := tmpl.Execute(&b, data); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "integrity") {
+		t.Errorf("Execute output %q contains an integrity attribute", b.String())
+	}
+
+	// The option can be combined with AddCSPNonce.
+	b.Reset()
+	tmpl = Must(New("t").Parse(`<script src="{{ . }}"></script>`))
+	if err := tmpl.ExecuteWithOptions(stdcontext.Background(), &b, data["Src"], AddCSPNonce("n"), AddSubresourceIntegrity(hashes)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), `<script src="/app.js" nonce="n" integrity="`+sha256+`" crossorigin="anonymous"></script>`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	for _, hash := range []string{
+		"",
+		"47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=",
+		"md5-1B2M2Y8AsgTpgAmY7PhCfg==",
+		"sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU",
+		"sha384-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=",
+		`sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=" onload="alert(1)`,
+		sha256 + " " + sha384,
+	} {
+		u := safehtml.TrustedResourceURLFromConstant("/app.js")
+		err := tmpl.ExecuteWithOptions(stdcontext.Background(), &b, u, AddSubresourceIntegrity(map[safehtml.TrustedResourceURL]string{u: hash}))
+		if err == nil || !strings.Contains(err.Error(), "invalid subresource integrity hash") {
+			t.Errorf("hash %q: got error %v, want invalid hash error", hash, err)
+		}
+	}
+}
+
+func TestAddTrustedParseTree(t *testing.T) {
+	header := Must(New("header").ParseFromTrustedTemplate(MakeTrustedTemplate(`<h1 title="{{ . }}">{{ . }}</h1>`)))
+	footer := Must(New("footer").Funcs(FuncMap{"upper": strings.ToUpper}).Parse(`<a href="{{ . }}">{{ upper "home" }}</a>`))
+	headerTree, err := header.TrustedParseTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	footerTree, err := footer.TrustedParseTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := headerTree.Name(), "header"; got != want {
+		t.Errorf("Name() = %q, want %q", got, want)
+	}
+
+	page := Must(New("page").Funcs(FuncMap{"upper": strings.ToUpper}).Parse(`{{ template "top" "<b>" }}<p>body</p>{{ template "footer" "javascript:alert(1)" }}`))
+	if _, err := page.AddTrustedParseTree("top", headerTree); err != nil {
+		t.Fatal(err)
+	}
+	added, err := page.AddTrustedParseTree("footer", footerTree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.Name() != "footer" || page.Lookup("footer") != added {
+		t.Errorf("AddTrustedParseTree returned %q, which is not the associated footer template", added.Name())
+	}
+	var b bytes.Buffer
+	if err := page.Execute(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), `<h1 title="&lt;b&gt;">&lt;b&gt;</h1><p>body</p><a href="about:invalid#zGoSafez">HOME</a>`; got != want {
+		t.Errorf("got:\n\t%s\nwant:\n\t%s", got, want)
+	}
+
+	// The source templates and trees are unaffected by escaping page, so they
+	// can be executed and added elsewhere.
+	b.Reset()
+	if err := header.Execute(&b, "x"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), `<h1 title="x">x</h1>`; got != want {
+		t.Errorf("got:\n\t%s\nwant:\n\t%s", got, want)
+	}
+	other := Must(New("other").Parse(`[{{ template "header" "y" }}]`))
+	if _, err := other.AddTrustedParseTree("header", headerTree); err != nil {
+		t.Fatal(err)
+	}
+	b.Reset()
+	if err := other.Execute(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), `[<h1 title="y">y</h1>]`; got != want {
+		t.Errorf("got:\n\t%s\nwant:\n\t%s", got, want)
+	}
+
+	// Executed templates neither provide nor accept parse trees.
+	if _, err := header.TrustedParseTree(); err == nil {
+		t.Error("expected error getting the parse tree of an executed template")
+	}
+	if _, err := page.AddTrustedParseTree("extra", footerTree); err == nil {
+		t.Error("expected error adding a parse tree to an executed template")
+	}
+	// Executing a caller also rewrites the parse trees of the templates it calls.
+	caller := Must(New("x").Parse(`{{define "a"}}<b>{{.}}</b>{{end}}{{define "b"}}{{template "a" .}}{{end}}`))
+	if err := caller.ExecuteTemplate(&b, "b", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := caller.Lookup("a").TrustedParseTree(); err == nil {
+		t.Error("expected error getting the parse tree of a template called by an executed template")
+	}
+	if _, err := New("empty").TrustedParseTree(); err == nil {
+		t.Error("expected error getting the parse tree of an empty template")
+	}
+	if _, err := New("t").AddTrustedParseTree("x", TrustedParseTree{}); err == nil {
+		t.Error("expected error adding the zero TrustedParseTree")
+	}
+}
+
+func TestFuncNames(t *testing.T) {
+	builtins := []string{"anchorOpenTag", "srcset", "urlWithParams"}
+	if got := New("t").FuncNames(); !reflect.DeepEqual(got, builtins) {
+		t.Errorf("FuncNames() = %q, want %q", got, builtins)
+	}
+
+	tmpl := New("t").Funcs(FuncMap{
+		"upper":   strings.ToUpper,
+		"srcset":  func() string { return "" },
+		"tenant":  func(ctx stdcontext.Context) string { return "" },
+		"aLitter": strings.TrimSpace,
+	})
+	assoc := tmpl.New("assoc").Funcs(FuncMap{"lower": strings.ToLower})
+	clone := Must(tmpl.Clone()).Funcs(FuncMap{"cloneOnly": strings.ToLower})
+	want := []string{"aLitter", "anchorOpenTag", "lower", "srcset", "tenant", "upper", "urlWithParams"}
+	for _, x := range []*Template{tmpl, assoc} {
+		if got := x.FuncNames(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: FuncNames() = %q, want %q", x.Name(), got, want)
+		}
+	}
+	wantClone := []string{"aLitter", "anchorOpenTag", "cloneOnly", "lower", "srcset", "tenant", "upper", "urlWithParams"}
+	if got := clone.FuncNames(); !reflect.DeepEqual(got, wantClone) {
+		t.Errorf("clone: FuncNames() = %q, want %q", got, wantClone)
+	}
+}
+
+func TestDefinedTemplateNames(t *testing.T) {
+	tmpl := New("page")
+	if got := tmpl.DefinedTemplateNames(); got != nil {
+		t.Errorf("before parsing: got %q, want none", got)
+	}
+	Must(tmpl.Parse(`{{ template "header" }}<p>{{ . }}</p>{{ template "footer" }}`))
+	Must(tmpl.New("partials").Parse(`{{ define "header" }}<h1>{{ end }}{{ define "footer" }}</h1>{{ end }}`))
+	tmpl.New("empty")
+	want := []string{"footer", "header", "page", "partials"}
+	if got := tmpl.DefinedTemplateNames(); !reflect.DeepEqual(got, want) {
+		t.Errorf("before execution: got %q, want %q", got, want)
+	}
+	// Templates derived by the escaper are not listed.
+	if err := tmpl.Execute(ioutil.Discard, "x"); err != nil {
+		t.Fatal(err)
+	}
+	if got := tmpl.DefinedTemplateNames(); !reflect.DeepEqual(got, want) {
+		t.Errorf("after execution: got %q, want %q", got, want)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tmpl := Must(New("page").Parse(`{{ template "header" . }}<main>{{ block "content" . }}default{{ end }}</main>` +
+		`{{ if . }}{{ template "footer" }}{{ else }}{{ range . }}{{ template "item" . }}{{ end }}{{ end }}`))
+	Must(tmpl.New("partials").Parse(`{{ define "header" }}<h1>{{ template "title" }}</h1>{{ end }}{{ define "title" }}T{{ end }}`))
+	Must(tmpl.New("script").Parse(`<script>{{ template "title" }}</script>`))
+	want := []TemplateInfo{
+		{Name: "content"},
+		{Name: "header", Invoked: []string{"title"}},
+		{Name: "page", Defined: []string{"content"}, Invoked: []string{"content", "footer", "header", "item"}},
+		{Name: "partials", Defined: []string{"header", "title"}},
+		{Name: "script", Invoked: []string{"title"}},
+		{Name: "title"},
+	}
+	if got := tmpl.Describe(); !reflect.DeepEqual(got, want) {
+		t.Errorf("before execution: got:\n\t%+v\nwant:\n\t%+v", got, want)
+	}
+
+	// Escaping makes script invoke a copy of title derived for the script
+	// element context, but this is reported under its original name.
+	if err := tmpl.ExecuteTemplate(ioutil.Discard, "script", nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(tmpl.Lookup("script").Tree.Root.String(), mangledNameSeparator) {
+		t.Fatalf("expected script to invoke a derived template")
+	}
+	if got := tmpl.Describe(); !reflect.DeepEqual(got, want) {
+		t.Errorf("after execution: got:\n\t%+v\nwant:\n\t%+v", got, want)
+	}
+}
@@ -27,6 +27,7 @@ var transitionFunc = [...]func(context, []byte) (context, int){
 
 var commentStart = []byte("<!--")
 var commentEnd = []byte("-->")
+var cdataStart = []byte("<![CDATA[")
 
 // tText is the context transition function for the text state.
 func tText(c context, s []byte) (context, int) {
@@ -36,7 +37,14 @@ func tText(c context, s []byte) (context, int) {
 		if i < k || i+1 == len(s) {
 			return c, len(s)
 		} else if i+4 <= len(s) && bytes.Equal(commentStart, s[i:i+4]) {
-			return context{state: stateHTMLCmt}, i + 4
+			return context{state: stateHTMLCmt, svg: c.svg}, i + 4
+		} else if c.svg == "svg" && bytes.HasPrefix(s[i:], cdataStart) {
+			// CDATA sections in SVG content contain text, which the escaper
+			// does not parse.
+			return context{
+				state: stateError,
+				err:   errorf(ErrBadHTML, nil, 0, "CDATA sections are disallowed in SVG content: %.32q", s[i:]),
+			}, len(s)
 		}
 		i++
 		end := false
@@ -49,10 +57,23 @@ func tText(c context, s []byte) (context, int) {
 		j, e := eatTagName(s, i)
 		if j != i {
 			// We've found an HTML tag.
-			ret := context{state: stateTag}
+			ret := context{state: stateTag, svg: c.svg}
+			if c.svg == "svg" && (svgBreakoutElements[e.name] || end && isHTMLOnlyElement(e.name)) {
+				// The tag can end the SVG content by closing the elements
+				// enclosing it, which the escaper does not track.
+				return context{
+					state: stateError,
+					err:   errorf(ErrBadHTML, nil, 0, "HTML element %q must not occur in SVG content", e.name),
+				}, len(s)
+			}
 			// Element name not needed if we are at the end of the element.
 			if !end {
 				ret.element = e
+			} else if c.svg == "svg" && e.name == "svg" {
+				ret.svg = ""
+			} else if c.svg != "svg" && c.svg == e.name {
+				// The end of an HTML integration point returns to SVG content.
+				ret.svg = "svg"
 			}
 			return ret, j
 		}
@@ -69,6 +90,66 @@ var specialElements = map[string]bool{
 	"title":    true,
 }
 
+// svgBreakoutElements contains the names of HTML elements whose start tags
+// end SVG content, returning the parser to HTML content.
+// https://html.spec.whatwg.org/multipage/parsing.html#parsing-main-inforeign
+var svgBreakoutElements = map[string]bool{
+	"b":          true,
+	"big":        true,
+	"blockquote": true,
+	"body":       true,
+	"br":         true,
+	"center":     true,
+	"code":       true,
+	"dd":         true,
+	"div":        true,
+	"dl":         true,
+	"dt":         true,
+	"em":         true,
+	"embed":      true,
+	"font":       true,
+	"h1":         true,
+	"h2":         true,
+	"h3":         true,
+	"h4":         true,
+	"h5":         true,
+	"h6":         true,
+	"head":       true,
+	"hr":         true,
+	"i":          true,
+	"img":        true,
+	"li":         true,
+	"listing":    true,
+	"menu":       true,
+	"meta":       true,
+	"nobr":       true,
+	"ol":         true,
+	"p":          true,
+	"pre":        true,
+	"ruby":       true,
+	"s":          true,
+	"small":      true,
+	"span":       true,
+	"strike":     true,
+	"strong":     true,
+	"sub":        true,
+	"sup":        true,
+	"table":      true,
+	"tt":         true,
+	"u":          true,
+	"ul":         true,
+	"var":        true,
+}
+
+// svgHTMLIntegrationPoints contains the names of SVG elements whose content is
+// parsed as HTML content.
+// https://html.spec.whatwg.org/multipage/parsing.html#html-integration-point
+var svgHTMLIntegrationPoints = map[string]bool{
+	"desc":          true,
+	"foreignobject": true,
+	"title":         true,
+}
+
 // voidElements contains the names of all void elements.
 // https://www.w3.org/TR/html5/syntax.html#void-elements
 var voidElements = map[string]bool{
@@ -89,6 +170,14 @@ var voidElements = map[string]bool{
 	"wbr":    true,
 }
 
+// isHTMLOnlyElement reports whether name is the name of an HTML element that
+// is not also an SVG element. Special elements are excluded, since their
+// content cannot contain SVG content.
+func isHTMLOnlyElement(name string) bool {
+	_, ok := elementContentSanitizationContext[name]
+	return (ok || voidElements[name]) && !svgElements[name] && !specialElements[name]
+}
+
 // tTag is the context transition function for the tag state.
 func tTag(c context, s []byte) (context, int) {
 	// Find the attribute name.
@@ -96,14 +185,34 @@ func tTag(c context, s []byte) (context, int) {
 	if i == len(s) {
 		return c, len(s)
 	}
+	selfClosing := false
+	if s[i] == '/' && i+1 < len(s) && s[i+1] == '>' && (c.svg == "svg" || c.element.name == "svg") {
+		// Self-closing start tags only end elements in SVG content.
+		selfClosing, i = true, i+1
+	}
 	if s[i] == '>' {
 		ret := context{
-			state:      stateText,
-			element:    c.element,
-			scriptType: c.scriptType,
-			linkRel:    c.linkRel,
+			state:         stateText,
+			element:       c.element,
+			scriptType:    c.scriptType,
+			linkRel:       c.linkRel,
+			metaHTTPEquiv: c.metaHTTPEquiv,
+			svg:           c.svg,
+		}
+		if c.element.name == "svg" && !selfClosing {
+			if c.svg != "" {
+				return context{
+					state: stateError,
+					err:   errorf(ErrBadHTML, nil, 0, "nested svg elements are not supported"),
+				}, len(s)
+			}
+			ret.svg = "svg"
+		} else if c.svg == "svg" && svgHTMLIntegrationPoints[c.element.name] && !selfClosing {
+			ret.svg = c.element.name
 		}
-		if specialElements[c.element.name] {
+		if selfClosing {
+			ret.element = element{}
+		} else if specialElements[c.element.name] && c.svg != "svg" {
 			ret.state = stateSpecialElementBody
 		}
 		if c.element.name != "" && voidElements[c.element.name] {
@@ -112,6 +221,7 @@ func tTag(c context, s []byte) (context, int) {
 			ret.element = element{}
 			ret.scriptType = ""
 			ret.linkRel = ""
+			ret.metaHTTPEquiv = ""
 		}
 		return ret, i + 1
 	}
@@ -132,11 +242,16 @@ func tTag(c context, s []byte) (context, int) {
 	} else {
 		state = stateAfterName
 	}
+	attrName := strings.ToLower(string(s[i:j]))
 	return context{
-		state:   state,
-		element: c.element,
-		attr:    attr{name: strings.ToLower(string(s[i:j]))},
-		linkRel: c.linkRel,
+		state:         state,
+		element:       c.element,
+		attr:          attr{name: attrName},
+		scriptType:    c.scriptType,
+		linkRel:       c.linkRel,
+		metaHTTPEquiv: c.metaHTTPEquiv,
+		nonceAttr:     c.nonceAttr || attrName == "nonce",
+		svg:           c.svg,
 	}, j
 }
 
@@ -189,7 +304,7 @@ func tBeforeValue(c context, s []byte) (context, int) {
 // tHTMLCmt is the context transition function for stateHTMLCmt.
 func tHTMLCmt(c context, s []byte) (context, int) {
 	if i := bytes.Index(s, commentEnd); i != -1 {
-		return context{}, i + 3
+		return context{svg: c.svg}, i + 3
 	}
 	return c, len(s)
 }
@@ -202,9 +317,37 @@ var (
 // tSpecialTagEnd is the context transition function for raw text, RCDATA
 // script data, and stylesheet element states.
 func tSpecialTagEnd(c context, s []byte) (context, int) {
-	if specialElements[c.element.name] {
+	// SVG elements with the names of special elements, such as style, are
+	// not special, unless they are HTML elements in an HTML integration point.
+	if specialElements[c.element.name] && (c.svg == "" || c.state == stateSpecialElementBody) {
 		if i := indexTagEnd(s, []byte(c.element.name)); i != -1 {
-			return context{}, i
+			return context{svg: c.svg}, i
+		}
+	}
+	if c.element.name == "script" && jsonScriptTypes[c.scriptType] {
+		return tJSON(c, s)
+	}
+	return c, len(s)
+}
+
+// tJSON is the context transition function for the body of a script element
+// that contains JSON data. It tracks whether the body ends inside a string
+// literal, where actions must be escaped as string content.
+func tJSON(c context, s []byte) (context, int) {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case !c.jsonString:
+			c.jsonString = s[i] == '"'
+		case s[i] == '\\':
+			if i+1 == len(s) {
+				return context{
+					state: stateError,
+					err:   errorf(ErrBadHTML, nil, 0, "actions must not follow a backslash in a JSON string: %q", s),
+				}, len(s)
+			}
+			i++
+		case s[i] == '"':
+			c.jsonString = false
 		}
 	}
 	return c, len(s)
@@ -15,6 +15,8 @@ import (
 	"io/fs"
 	"os"
 	"path"
+
+	"github.com/google/safehtml/internal/template/raw"
 )
 
 // A TrustedFS is an immutable type referencing a filesystem (fs.FS)
@@ -36,11 +38,26 @@ func TrustedFSFromEmbed(fsys embed.FS) TrustedFS {
 }
 
 // TrustedFSFromTrustedSource constructs a TrustedFS from the string in the
-// TrustedSource, which should refer to a directory.
+// TrustedSource, which should refer to a directory. The TrustedFS is backed by
+// os.DirFS, so this can be used to load templates from a directory chosen by
+// application configuration, e.g. with TrustedSourceFromFlag.
+//
+// To wrap an fs.FS other than an embed.FS or a directory, use
+// uncheckedconversions.TrustedFSFromFSKnownToSatisfyTypeContract.
 func TrustedFSFromTrustedSource(ts TrustedSource) TrustedFS {
 	return TrustedFS{fsys: os.DirFS(ts.src)}
 }
 
+// trustedFSRaw is used by package uncheckedconversions (via package raw) to
+// create TrustedFS values from arbitrary filesystems.
+func trustedFSRaw(fsys fs.FS) TrustedFS {
+	return TrustedFS{fsys: fsys}
+}
+
+func init() {
+	raw.TrustedFS = trustedFSRaw
+}
+
 // Sub returns a TrustedFS at a subdirectory of the receiver.
 // It works by calling fs.Sub on the receiver's fs.FS.
 func (tf TrustedFS) Sub(dir TrustedSource) (TrustedFS, error) {
@@ -70,6 +87,46 @@ func (t *Template) ParseFS(tfs TrustedFS, patterns ...string) (*Template, error)
 	return parseFS(t, tfs.fsys, patterns)
 }
 
+// ParseFSFiles is like ParseFiles but reads from the TrustedFS instead of the
+// host operating system's file system. Unlike ParseFS, filenames are not
+// treated as glob patterns, and the files are parsed in the order given, so
+// later files can redefine templates defined by earlier ones.
+//
+// The same behaviors listed for ParseFiles() apply to ParseFSFiles too (e.g.
+// using the base name of the file as the template name).
+func ParseFSFiles(tfs TrustedFS, filenames ...string) (*Template, error) {
+	return parseFiles(nil, readFileFS(tfs.fsys), filenames...)
+}
+
+// ParseFSFiles is like ParseFiles but reads from the TrustedFS instead of the
+// host operating system's file system. Unlike ParseFS, filenames are not
+// treated as glob patterns, and the files are parsed in the order given, so
+// later files can redefine templates defined by earlier ones.
+//
+// The same behaviors listed for ParseFiles() apply to ParseFSFiles too (e.g.
+// using the base name of the file as the template name).
+func (t *Template) ParseFSFiles(tfs TrustedFS, filenames ...string) (*Template, error) {
+	return parseFiles(t, readFileFS(tfs.fsys), filenames...)
+}
+
+// ParseGlobFS creates a new Template and parses the template definitions from the
+// files in the TrustedFS identified by the pattern, which must match at least one
+// file. The pattern is processed by fs.Glob. ParseGlobFS is equivalent to calling
+// ParseFS with the single pattern.
+func ParseGlobFS(tfs TrustedFS, pattern string) (*Template, error) {
+	return parseFS(nil, tfs.fsys, []string{pattern})
+}
+
+// ParseGlobFS parses the template definitions in the files in the TrustedFS
+// identified by the pattern and associates the resulting templates with t. The
+// pattern is processed by fs.Glob and must match at least one file. ParseGlobFS
+// is equivalent to calling t.ParseFS with the single pattern.
+//
+// ParseGlobFS returns an error if t or any associated template has already been executed.
+func (t *Template) ParseGlobFS(tfs TrustedFS, pattern string) (*Template, error) {
+	return parseFS(t, tfs.fsys, []string{pattern})
+}
+
 // Copied from
 // https://go.googlesource.com/go/+/refs/tags/go1.17.1/src/text/template/helper.go.
 func parseFS(t *Template, fsys fs.FS, patterns []string) (*Template, error) {
@@ -77,7 +134,7 @@ func parseFS(t *Template, fsys fs.FS, patterns []string) (*Template, error) {
 	for _, pattern := range patterns {
 		list, err := fs.Glob(fsys, pattern)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("template: pattern %#q: %w", pattern, err)
 		}
 		if len(list) == 0 {
 			return nil, fmt.Errorf("template: pattern matches no files: %#q", pattern)
@@ -11,7 +11,10 @@ package template
 
 import (
 	"embed"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 //go:embed testdata
@@ -37,3 +40,116 @@ func TestSub(t *testing.T) {
 		t.Errorf("expected ParseFS to update template")
 	}
 }
+
+func TestParseGlobFS(t *testing.T) {
+	tfs := TrustedFSFromEmbed(testFS)
+	tmpl := New("root")
+	parsedTmpl, err := tmpl.ParseGlobFS(tfs, "testdata/glob_*.tmpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsedTmpl != tmpl {
+		t.Errorf("expected ParseGlobFS to update template")
+	}
+	for _, name := range []string{"glob_t0.tmpl", "glob_t1.tmpl", "glob_t2.tmpl"} {
+		if tmpl.Lookup(name) == nil {
+			t.Errorf("expected template %q to be defined", name)
+		}
+	}
+
+	tmpl, err = ParseGlobFS(tfs, "testdata/glob_*.tmpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tmpl.Name(), "glob_t0.tmpl"; got != want {
+		t.Errorf("ParseGlobFS returned template named %q, want %q", got, want)
+	}
+
+	if _, err := ParseGlobFS(tfs, "testdata/nonexistent_*.tmpl"); err == nil {
+		t.Errorf("expected error for pattern matching no files")
+	} else if want := "pattern matches no files"; !strings.Contains(err.Error(), want) {
+		t.Errorf("got error %q, want error containing %q", err, want)
+	}
+}
+
+func TestTrustedFSFromTrustedSource(t *testing.T) {
+	tfs := TrustedFSFromTrustedSource(TrustedSourceFromConstant("testdata"))
+	tmpl, err := ParseFS(tfs, "dir1/parsefiles_t1.tmpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tmpl.Name(), "parsefiles_t1.tmpl"; got != want {
+		t.Errorf("ParseFS returned template named %q, want %q", got, want)
+	}
+}
+
+func TestParseFSFiles(t *testing.T) {
+	tfs := trustedFSRaw(fstest.MapFS{
+		"base.tmpl":     {Data: []byte(`<p>{{block "content" .}}base{{end}}</p>`)},
+		"override.tmpl": {Data: []byte(`{{define "content"}}override{{end}}`)},
+	})
+	for _, test := range [...]struct {
+		filenames []string
+		want      string
+	}{
+		{[]string{"base.tmpl"}, "<p>base</p>"},
+		{[]string{"base.tmpl", "override.tmpl"}, "<p>override</p>"},
+	} {
+		tmpl, err := ParseFSFiles(tfs, test.filenames...)
+		if err != nil {
+			t.Errorf("ParseFSFiles(%q) returned unexpected error: %s", test.filenames, err)
+			continue
+		}
+		if got := tmpl.Name(); got != test.filenames[0] {
+			t.Errorf("ParseFSFiles(%q) returned template named %q, want %q", test.filenames, got, test.filenames[0])
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, nil); err != nil {
+			t.Errorf("executing template parsed from %q: %s", test.filenames, err)
+		} else if got := b.String(); got != test.want {
+			t.Errorf("template parsed from %q produced %q, want %q", test.filenames, got, test.want)
+		}
+	}
+
+	// Filenames are not treated as glob patterns.
+	if _, err := ParseFSFiles(tfs, "*.tmpl"); err == nil {
+		t.Errorf("expected error for glob pattern")
+	}
+
+	tmpl := New("root")
+	if _, err := tmpl.ParseFSFiles(tfs, "base.tmpl", "missing.tmpl"); err == nil {
+		t.Errorf("expected error for missing file")
+	} else if want := "missing.tmpl"; !strings.Contains(err.Error(), want) {
+		t.Errorf("got error %q, want error naming %q", err, want)
+	}
+}
+
+func TestDefinedTemplateNamesFS(t *testing.T) {
+	tfs := trustedFSRaw(fstest.MapFS{
+		"page.tmpl":     {Data: []byte(`{{ template "header" }}`)},
+		"partials.tmpl": {Data: []byte(`{{ define "header" }}<h1>{{ end }}`)},
+	})
+	tmpl := Must(ParseFS(tfs, "*.tmpl"))
+	want := []string{"header", "page.tmpl", "partials.tmpl"}
+	if got := tmpl.DefinedTemplateNames(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseFSErrorIncludesFilename(t *testing.T) {
+	tfs := trustedFSRaw(fstest.MapFS{
+		"a/page.tmpl": {Data: []byte(`ok`)},
+		"b/page.tmpl": {Data: []byte(`{{ end }}`)},
+	})
+	want := `html/template: parsing file "b/page.tmpl": template: page.tmpl:1: unexpected {{end}}`
+	if _, err := ParseFS(tfs, "*/page.tmpl"); err == nil || err.Error() != want {
+		t.Errorf("ParseFS: got error %v, want %s", err, want)
+	}
+	if _, err := ParseFSFiles(tfs, "a/page.tmpl", "b/page.tmpl"); err == nil || err.Error() != want {
+		t.Errorf("ParseFSFiles: got error %v, want %s", err, want)
+	}
+	want = "template: pattern `[`: syntax error in pattern"
+	if _, err := ParseFS(tfs, "["); err == nil || err.Error() != want {
+		t.Errorf("ParseFS: got error %v, want %s", err, want)
+	}
+}
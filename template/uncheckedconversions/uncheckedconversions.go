@@ -11,6 +11,7 @@
 package uncheckedconversions
 
 import (
+	"github.com/google/safehtml/internal/audit"
 	"github.com/google/safehtml/internal/template/raw"
 	"github.com/google/safehtml/template"
 )
@@ -20,10 +21,12 @@ var trustedTemplate = raw.TrustedTemplate.(func(string) template.TrustedTemplate
 
 // TrustedSourceFromStringKnownToSatisfyTypeContract converts a string into a TrustedSource.
 func TrustedSourceFromStringKnownToSatisfyTypeContract(s string) template.TrustedSource {
+	audit.Record()
 	return trustedSource(s)
 }
 
 // TrustedTemplateFromStringKnownToSatisfyTypeContract converts a string into a TrustedTemplate.
 func TrustedTemplateFromStringKnownToSatisfyTypeContract(s string) template.TrustedTemplate {
+	audit.Record()
 	return trustedTemplate(s)
 }
@@ -0,0 +1,28 @@
+// Copyright (c) 2021 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+//go:build go1.16
+// +build go1.16
+
+package uncheckedconversions
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/safehtml/template"
+)
+
+func TestTrustedFSFromFSKnownToSatisfyTypeContract(t *testing.T) {
+	tfs := TrustedFSFromFSKnownToSatisfyTypeContract(os.DirFS("../testdata"))
+	tmpl, err := template.ParseFS(tfs, "glob_*.tmpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tmpl.Name(), "glob_t0.tmpl"; got != want {
+		t.Errorf("ParseFS returned template named %q, want %q", got, want)
+	}
+}
@@ -7,8 +7,10 @@ package template
 import (
 	"fmt"
 	"html"
+	"net/url"
 	"regexp"
 	"strings"
+	"text/template"
 
 	"github.com/google/safehtml/internal/safehtmlutil"
 	"github.com/google/safehtml"
@@ -16,7 +18,7 @@ import (
 
 // urlPrefixValidators maps URL and TrustedResourceURL sanitization contexts to functions return an error
 // if the given string is unsafe to use as a URL prefix in that sanitization context.
-var urlPrefixValidators = map[sanitizationContext]func(string) error{
+var urlPrefixValidators = map[sanitizationContext]func(string, *safehtml.URLSanitizerConfig) error{
 	sanitizationContextURL:                     validateURLPrefix,
 	sanitizationContextTrustedResourceURLOrURL: validateURLPrefix,
 	sanitizationContextTrustedResourceURL:      validateTrustedResourceURLPrefix,
@@ -33,19 +35,19 @@ var startsWithFullySpecifiedSchemePattern = regexp.MustCompile(
 // or percent-encoding character triplet.
 //
 // If the prefix contains a fully-specified scheme component, it is considered safe only if
-// it starts with an allowed scheme. See safehtml.URLSanitized for more details.
+// it starts with a scheme allowed by urlSanitizer. See safehtml.URLSanitized for more details.
 //
 // Otherwise, the prefix is safe only if it contains '/', '?', or '#', since the presence of any
 // of these runes ensures that this prefix, when combined with some arbitrary suffix, cannot be
 // interpreted as a part of a scheme.
-func validateURLPrefix(prefix string) error {
+func validateURLPrefix(prefix string, urlSanitizer *safehtml.URLSanitizerConfig) error {
 	decoded, err := decodeURLPrefix(prefix)
 	if err != nil {
 		return err
 	}
 	switch {
 	case startsWithFullySpecifiedSchemePattern.MatchString(decoded):
-		if safehtml.URLSanitized(decoded).String() != decoded {
+		if urlSanitizer.Sanitize(decoded).String() != decoded {
 			return fmt.Errorf("URL prefix %q contains an unsafe scheme", prefix)
 		}
 	case !strings.ContainsAny(decoded, "/?#"):
@@ -65,7 +67,7 @@ func validateURLPrefix(prefix string) error {
 // or percent-encoding character triplet.
 //
 // See safehtmlutil.IsSafeTrustedResourceURLPrefix for details on how the prefix is validated.
-func validateTrustedResourceURLPrefix(prefix string) error {
+func validateTrustedResourceURLPrefix(prefix string, _ *safehtml.URLSanitizerConfig) error {
 	decoded, err := decodeURLPrefix(prefix)
 	if err != nil {
 		return err
@@ -120,3 +122,154 @@ func validateTrustedResourceURLSubstitution(args ...interface{}) (string, error)
 	}
 	return input, nil
 }
+
+// urlWithParams implements the urlWithParams template builtin. It returns base,
+// which must be a safehtml.URL or a string that is sanitized with
+// safehtml.URLSanitized, with the key-value pairs in params added as
+// percent-encoded query parameters. params must be a map[string]string,
+// url.Values or map[string][]string.
+//
+// For url.Values, the first values of all keys are appended before the second
+// values of any key, and so on, so that the order of appended parameters is
+// stable. As in safehtml.URLWithParams, empty keys and values are ignored.
+func urlWithParams(base interface{}, params interface{}) (safehtml.URL, error) {
+	var u safehtml.URL
+	switch b := safehtmlutil.Indirect(base).(type) {
+	case safehtml.URL:
+		u = b
+	case string:
+		u = safehtml.URLSanitized(b)
+	default:
+		return safehtml.URL{}, fmt.Errorf("urlWithParams: expected a safehtml.URL or string base URL, got %T", base)
+	}
+	var values map[string][]string
+	switch p := safehtmlutil.Indirect(params).(type) {
+	case map[string]string:
+		return safehtml.URLWithParams(u, p), nil
+	case url.Values:
+		values = p
+	case map[string][]string:
+		values = p
+	default:
+		return safehtml.URL{}, fmt.Errorf("urlWithParams: expected a map[string]string or url.Values, got %T", params)
+	}
+	for i := 0; ; i++ {
+		round := make(map[string]string)
+		for k, vs := range values {
+			if i < len(vs) {
+				round[k] = vs[i]
+			}
+		}
+		if len(round) == 0 {
+			return u, nil
+		}
+		u = safehtml.URLWithParams(u, round)
+	}
+}
+
+// srcset implements the srcset template builtin. It returns a srcset attribute
+// value made up of the image candidates in args, which must alternate between
+// a safehtml.TrustedResourceURL and its descriptor string, as returned by
+// safehtml.URLSetFromTrustedResourceURLs.
+func srcset(args ...interface{}) (safehtml.URLSet, error) {
+	if len(args)%2 != 0 {
+		return safehtml.URLSet{}, fmt.Errorf("srcset: expected pairs of URLs and descriptors, got %d arguments", len(args))
+	}
+	candidates := make([]safehtml.ImageCandidate, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		u, ok := safehtmlutil.Indirect(args[i]).(safehtml.TrustedResourceURL)
+		if !ok {
+			return safehtml.URLSet{}, fmt.Errorf("srcset: expected a safehtml.TrustedResourceURL as argument %d, got %T", i, args[i])
+		}
+		descriptor, ok := safehtmlutil.Indirect(args[i+1]).(string)
+		if !ok {
+			return safehtml.URLSet{}, fmt.Errorf("srcset: expected a string descriptor as argument %d, got %T", i+1, args[i+1])
+		}
+		candidates = append(candidates, safehtml.ImageCandidate{URL: u, Descriptor: descriptor})
+	}
+	set, err := safehtml.URLSetFromTrustedResourceURLs(candidates...)
+	if err != nil {
+		return safehtml.URLSet{}, fmt.Errorf("srcset: %v", err)
+	}
+	return set, nil
+}
+
+// anchorOpenTag implements the anchorOpenTag template builtin. It returns an
+// <a> start tag whose href attribute is link, which must be a safehtml.URL or a
+// string that is sanitized with safehtml.URLSanitized.
+//
+// If link may navigate to a document outside origin, which must be an http or
+// https origin such as "https://example.com", the tag also has a
+// rel="noopener noreferrer" attribute, so that the linked document cannot
+// access the linking window through window.opener or learn its URL from the
+// Referer header. Relative URLs that are not scheme-relative are always
+// same-origin.
+func anchorOpenTag(link interface{}, origin string) (safehtml.HTML, error) {
+	var u safehtml.URL
+	switch l := safehtmlutil.Indirect(link).(type) {
+	case safehtml.URL:
+		u = l
+	case string:
+		u = safehtml.URLSanitized(l)
+	default:
+		return safehtml.HTML{}, fmt.Errorf("anchorOpenTag: expected a safehtml.URL or string link, got %T", link)
+	}
+	o, err := url.Parse(origin)
+	if err != nil || o.Scheme != "http" && o.Scheme != "https" || o.Host == "" || o.Opaque != "" || o.User != nil ||
+		o.Path != "" && o.Path != "/" || o.RawQuery != "" || o.Fragment != "" {
+		return safehtml.HTML{}, fmt.Errorf("anchorOpenTag: %q is not an http or https origin", origin)
+	}
+	tag := `<a href="` + html.EscapeString(u.String()) + `"`
+	if !isSameOrigin(u, o.Scheme, safehtmlutil.NormalizeHost(o.Scheme, o.Host)) {
+		tag += ` rel="noopener noreferrer"`
+	}
+	return rawHTML(tag + ">"), nil
+}
+
+// isSameOrigin reports whether navigating to link, resolved against a document
+// with the given origin scheme and normalized host, stays within that origin.
+// It errs on the side of reporting false for URLs that browsers may parse
+// differently.
+func isSameOrigin(link safehtml.URL, originScheme, originHost string) bool {
+	scheme := link.Scheme()
+	var rest string
+	switch scheme {
+	case "":
+		// Browsers strip leading spaces from URLs.
+		rest = strings.TrimLeft(link.String(), " ")
+		if len(rest) < 2 || !safehtmlutil.IsURLSlash(rest[0]) || !safehtmlutil.IsURLSlash(rest[1]) {
+			// Path-, absolute-path-, query- or fragment-relative URLs.
+			return true
+		}
+		scheme = originScheme
+	case "http", "https":
+		rest = link.String()[len(scheme)+len(":"):]
+	default:
+		return false
+	}
+	// Browsers treat '\' like '/', and ignore any number of slashes before
+	// the authority, in URLs with special schemes such as http and https.
+	rest = strings.TrimLeft(rest, `/\`)
+	authority := rest
+	if i := strings.IndexAny(rest, `/\?#`); i != -1 {
+		authority = rest[:i]
+	}
+	if i := strings.LastIndexByte(authority, '@'); i != -1 {
+		authority = authority[i+1:]
+	}
+	return scheme == originScheme && safehtmlutil.NormalizeHost(scheme, authority) == originHost
+}
+
+// urlSanitizerFuncs returns URL sanitizers that sanitize URLs with urlSanitizer
+// instead of safehtml.URLSanitized. They replace the corresponding functions in
+// funcs for templates configured with AllowURLSchemes.
+func urlSanitizerFuncs(urlSanitizer *safehtml.URLSanitizerConfig) template.FuncMap {
+	return template.FuncMap{
+		sanitizeTrustedResourceURLOrURLFuncName: func(args ...interface{}) (string, error) {
+			return sanitizeTrustedResourceURLOrURLWith(urlSanitizer, args...)
+		},
+		sanitizeURLFuncName: func(args ...interface{}) (string, error) {
+			return sanitizeURLWith(urlSanitizer, args...)
+		},
+	}
+}
@@ -5,8 +5,13 @@
 package template
 
 import (
+	"fmt"
+	"net/url"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/google/safehtml"
 )
 
 func TestValidateURLPrefix(t *testing.T) {
@@ -33,6 +38,10 @@ func TestValidateURLPrefix(t *testing.T) {
 		{`data:text/html;base64,abc`, false},
 		// No scheme, but not a scheme prefix.
 		{`//www.foo.com/`, true},
+		{`//[::1]:`, true},
+		{`//[::1]:8080/`, true},
+		{`http://[::1]:`, true},
+		{`https://[2001:db8::1]:8443/`, true},
 		{`/path`, true},
 		{`/path/x`, true},
 		{`/path#x`, true},
@@ -56,7 +65,7 @@ func TestValidateURLPrefix(t *testing.T) {
 		// unsafe scheme after HTML-unescaping.
 		{`javascript&#58`, false},
 	} {
-		err := validateURLPrefix(test.in)
+		err := validateURLPrefix(test.in, defaultURLSanitizerConfig)
 		if err != nil && test.valid {
 			t.Errorf("validateURLPrefix(%q) failed: %s", test.in, err)
 		} else if err == nil && !test.valid {
@@ -87,7 +96,7 @@ func TestValidateTrustedResourceURLPrefix(t *testing.T) {
 		// unsafe scheme after HTML-unescaping.
 		{`javascript&#58`, false},
 	} {
-		err := validateTrustedResourceURLPrefix(test.in)
+		err := validateTrustedResourceURLPrefix(test.in, nil)
 		if err != nil && test.valid {
 			t.Errorf("validateTrustedResourceURLPrefix(%q) failed: %s", test.in, err)
 		} else if err == nil && !test.valid {
@@ -146,3 +155,353 @@ func TestDecodeURLPrefix(t *testing.T) {
 		}
 	}
 }
+
+func TestURLWithParamsBuiltin(t *testing.T) {
+	for _, test := range [...]struct {
+		desc, tmpl string
+		data       interface{}
+		want, err  string
+	}{
+		{
+			"map with reserved characters",
+			`<a href="{{ urlWithParams "/search" . }}">x</a>`,
+			map[string]string{"q": "a&b=c d", "hl": "en"},
+			`<a href="/search?hl=en&amp;q=a%26b%3dc%20d">x</a>`, ``,
+		},
+		{
+			"url.Values with multiple values",
+			`<a href="{{ urlWithParams "https://example.com/?x=1#frag" . }}">x</a>`,
+			url.Values{"k": {"v1", "v 2"}, "a": {"="}},
+			`<a href="https://example.com/?x=1&amp;a=%3d&amp;k=v1&amp;k=v%202#frag">x</a>`, ``,
+		},
+		{
+			"unsafe base URL sanitized",
+			`<a href="{{ urlWithParams "javascript:alert(1)" . }}">x</a>`,
+			map[string]string{"q": "1"},
+			`<a href="about:invalid#zGoSafez">x</a>`, ``,
+		},
+		{
+			"safehtml.URL base",
+			`<img src="{{ urlWithParams .Base .Params }}">`,
+			struct {
+				Base   safehtml.URL
+				Params map[string]string
+			}{safehtml.URLSanitized("/img.png"), map[string]string{"w": "100"}},
+			`<img src="/img.png?w=100">`, ``,
+		},
+		{
+			"unsupported params type",
+			`<a href="{{ urlWithParams "/search" . }}">x</a>`,
+			[]string{"q"},
+			``, `urlWithParams: expected a map[string]string or url.Values, got []string`,
+		},
+	} {
+		tmpl := Must(New(test.desc).Parse(stringConstant(test.tmpl)))
+		var b strings.Builder
+		err := tmpl.Execute(&b, test.data)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want error containing %q", test.desc, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.desc, err)
+		} else if got := b.String(); got != test.want {
+			t.Errorf("%s: got:\n\t%s\nwant:\n\t%s", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestAnchorOpenTag(t *testing.T) {
+	const origin = "https://example.com"
+	const hardened = `" rel="noopener noreferrer">`
+	for _, test := range [...]struct {
+		link  string
+		cross bool
+	}{
+		// Same-origin URLs.
+		{`/path`, false},
+		{`path?q=a:b`, false},
+		{`?q`, false},
+		{`#top`, false},
+		{`https://example.com/a`, false},
+		{`HTTPS://EXAMPLE.COM/a`, false},
+		{`https://example.com:443/a`, false},
+		{`https://user@example.com/a`, false},
+		{`//example.com/a`, false},
+		// Cross-origin URLs.
+		{`https://evil.com/`, true},
+		{`http://example.com/`, true},
+		{`https://example.com:8443/`, true},
+		{`https://example.com.evil.com/`, true},
+		{`https://example.com@evil.com/`, true},
+		{`//evil.com/a`, true},
+		{` //evil.com/a`, true},
+		{`/\evil.com/a`, true},
+		{`https:\\evil.com`, true},
+		{`https:evil.com`, true},
+		{`mailto:a@example.com`, true},
+		{`javascript:alert(1)`, true}, // Sanitized to InnocuousURL.
+	} {
+		got, err := anchorOpenTag(test.link, origin)
+		if err != nil {
+			t.Errorf("anchorOpenTag(%q, %q) returned unexpected error: %s", test.link, origin, err)
+			continue
+		}
+		if cross := strings.HasSuffix(got.String(), hardened); cross != test.cross {
+			t.Errorf("anchorOpenTag(%q, %q) = %q, want rel hardening %t", test.link, origin, got, test.cross)
+		}
+	}
+
+	for _, origin := range [...]string{"", "example.com", "ftp://example.com", "https://", "https://example.com/path", "https://user@example.com", "https://example.com?q"} {
+		if _, err := anchorOpenTag("/path", origin); err == nil {
+			t.Errorf("anchorOpenTag(%q, %q) returned no error", "/path", origin)
+		}
+	}
+	if _, err := anchorOpenTag(1, origin); err == nil || err.Error() != "anchorOpenTag: expected a safehtml.URL or string link, got int" {
+		t.Errorf("anchorOpenTag(1, %q) returned error %v", origin, err)
+	}
+}
+
+func TestAnchorOpenTagBuiltin(t *testing.T) {
+	tmpl := Must(New("t").Parse(`<li>{{ anchorOpenTag .Link .Origin }}{{ .Text }}</a></li>`))
+	for _, test := range [...]struct {
+		link interface{}
+		want string
+	}{
+		{"/docs?a=1&b=2", `<li><a href="/docs?a=1&amp;b=2">&lt;docs&gt;</a></li>`},
+		{safehtml.URLSanitized(`https://other.example/"><script>`), `<li><a href="https://other.example/&#34;&gt;&lt;script&gt;" rel="noopener noreferrer">&lt;docs&gt;</a></li>`},
+		{"javascript:alert(1)", `<li><a href="about:invalid#zGoSafez" rel="noopener noreferrer">&lt;docs&gt;</a></li>`},
+	} {
+		var b strings.Builder
+		data := struct {
+			Link         interface{}
+			Origin, Text string
+		}{test.link, "https://example.com", "<docs>"}
+		if err := tmpl.Execute(&b, data); err != nil {
+			t.Errorf("%v: unexpected error: %s", test.link, err)
+		} else if got := b.String(); got != test.want {
+			t.Errorf("%v: got:\n\t%s\nwant:\n\t%s", test.link, got, test.want)
+		}
+	}
+}
+
+func TestSrcsetBuiltin(t *testing.T) {
+	tmpl := Must(New("t").Parse(`<img srcset="{{ srcset .Small .SmallDesc .Large .LargeDesc }}">`))
+	small := safehtml.TrustedResourceURLFromConstant("https://example.com/small.png")
+	large := safehtml.TrustedResourceURLFromConstant("https://example.com/large image.png")
+	for _, test := range [...]struct {
+		small, large         interface{}
+		smallDesc, largeDesc string
+		want, wantErr        string
+	}{
+		{
+			small: small, smallDesc: "1x", large: large, largeDesc: "2x",
+			want: `<img srcset="https://example.com/small.png 1x , https://example.com/large%20image.png 2x">`,
+		},
+		{
+			small: &small, smallDesc: "480w", large: large, largeDesc: "1080w",
+			want: `<img srcset="https://example.com/small.png 480w , https://example.com/large%20image.png 1080w">`,
+		},
+		{
+			small: small, smallDesc: "1x", large: large, largeDesc: `2x"><script>alert(1)</script>`,
+			wantErr: `srcset: image candidate "https://example.com/large image.png" has an invalid descriptor`,
+		},
+		{
+			small: small, smallDesc: "1x", large: large, largeDesc: "1x",
+			wantErr: `srcset: more than one image candidate has the descriptor "1x"`,
+		},
+		{
+			small: "https://example.com/small.png", smallDesc: "1x", large: large, largeDesc: "2x",
+			wantErr: "srcset: expected a safehtml.TrustedResourceURL as argument 0, got string",
+		},
+	} {
+		var b strings.Builder
+		data := struct {
+			Small, Large         interface{}
+			SmallDesc, LargeDesc string
+		}{test.small, test.large, test.smallDesc, test.largeDesc}
+		err := tmpl.Execute(&b, data)
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%q, %q: got error %v, want error containing %q", test.smallDesc, test.largeDesc, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q, %q: unexpected error: %s", test.smallDesc, test.largeDesc, err)
+		} else if got := b.String(); got != test.want {
+			t.Errorf("%q, %q: got:\n\t%s\nwant:\n\t%s", test.smallDesc, test.largeDesc, got, test.want)
+		}
+	}
+
+	if _, err := srcset(small); err == nil || err.Error() != "srcset: expected pairs of URLs and descriptors, got 1 arguments" {
+		t.Errorf("srcset(%q) returned error %v", small, err)
+	}
+}
+
+func TestURLMissingKeyOption(t *testing.T) {
+	type page struct {
+		Link   string
+		URL    safehtml.URL
+		Script safehtml.TrustedResourceURL
+		ID     string
+	}
+	for _, test := range [...]struct {
+		desc       string
+		tmpl       stringConstant
+		missingkey string
+		data       interface{}
+		want, err  string
+	}{
+		{
+			desc: "present",
+			tmpl: `<a href="{{ .Link }}">`, data: page{Link: "/a"},
+			want: `<a href="/a">`,
+		},
+		{
+			desc: "present safehtml.URL",
+			tmpl: `<a href="{{ .URL }}">`, data: page{URL: safehtml.URLSanitized("/a")},
+			want: `<a href="/a">`,
+		},
+		{
+			desc: "empty field",
+			tmpl: `<a href="{{ .Link }}">`, data: page{},
+			err: "empty value for URL",
+		},
+		{
+			desc: "zero safehtml.URL",
+			tmpl: `<a href="{{ .URL }}">`, data: page{},
+			err: "empty value for URL",
+		},
+		{
+			desc: "zero safehtml.TrustedResourceURL",
+			tmpl: `<script src="{{ .Script }}"></script>`, data: page{},
+			err: "empty value for URL",
+		},
+		{
+			desc: "empty substitution after prefix",
+			tmpl: `<a href="/user/{{ .ID }}">`, data: page{},
+			err: "empty value for URL",
+		},
+		{
+			desc: "empty substitution in query",
+			tmpl: `<a href="/search?q={{ .ID }}">`, data: page{},
+			err: "empty value for URL",
+		},
+		{
+			desc: "missing map key",
+			tmpl: `<a href="{{ .Link }}">`, data: map[string]string{},
+			err: "missing value for URL",
+		},
+		{
+			desc: "missing map key with missingkey=zero",
+			tmpl: `<a href="{{ .Link }}">`, data: map[string]string{}, missingkey: "missingkey=zero",
+			err: "empty value for URL",
+		},
+		{
+			desc: "missing map key with missingkey=error",
+			tmpl: `<a href="{{ .Link }}">`, data: map[string]string{}, missingkey: "missingkey=error",
+			err: `map has no entry for key "Link"`,
+		},
+		{
+			desc: "other contexts are unaffected",
+			tmpl: `<a title="{{ .ID }}">{{ .ID }}</a>`, data: page{},
+			want: `<a title=""></a>`,
+		},
+	} {
+		tmpl := New("t").Option("urlmissingkey=error")
+		if test.missingkey != "" {
+			tmpl.Option(test.missingkey)
+		}
+		Must(tmpl.Parse(test.tmpl))
+		var b strings.Builder
+		err := tmpl.Execute(&b, test.data)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want error containing %q", test.desc, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.desc, err)
+		} else if b.String() != test.want {
+			t.Errorf("%s: got %q, want %q", test.desc, b.String(), test.want)
+		}
+	}
+}
+
+func TestURLMissingKeyOptionDefault(t *testing.T) {
+	for _, opts := range [][]string{nil, {"urlmissingkey=error", "urlmissingkey=default"}} {
+		tmpl := Must(New("t").Option(opts...).Parse(`<a href="{{ .Link }}">`))
+		var b strings.Builder
+		if err := tmpl.Execute(&b, struct{ Link string }{}); err != nil {
+			t.Errorf("options %q: unexpected error: %v", opts, err)
+		} else if want := `<a href="">`; b.String() != want {
+			t.Errorf("options %q: got %q, want %q", opts, b.String(), want)
+		}
+	}
+}
+
+func TestURLMissingKeyOptionClone(t *testing.T) {
+	tmpl := Must(New("t").Option("urlmissingkey=error").Parse(`<a href="{{ .Link }}">`))
+	clone := Must(tmpl.Clone())
+	if err := clone.Execute(&strings.Builder{}, struct{ Link string }{}); err == nil {
+		t.Errorf("expected clone to inherit urlmissingkey=error")
+	}
+}
+
+func TestURLMissingKeyOptionPanics(t *testing.T) {
+	for _, test := range [...]struct {
+		desc string
+		f    func()
+		want string
+	}{
+		{
+			"invalid value",
+			func() { New("t").Option("urlmissingkey=zero") },
+			"unrecognized option: urlmissingkey=zero",
+		},
+		{
+			"after execution",
+			func() {
+				tmpl := Must(New("t").Parse(`foo`))
+				tmpl.Execute(&strings.Builder{}, nil)
+				tmpl.Option("urlmissingkey=error")
+			},
+			"cannot set urlmissingkey after Execute",
+		},
+	} {
+		func() {
+			defer func() {
+				r := recover()
+				if r == nil || !strings.Contains(fmt.Sprint(r), test.want) {
+					t.Errorf("%s: got panic %v, want panic containing %q", test.desc, r, test.want)
+				}
+			}()
+			test.f()
+		}()
+	}
+}
+
+func TestOnUnsafeURL(t *testing.T) {
+	var got []string
+	safehtml.OnUnsafeURL = func(url string) { got = append(got, url) }
+	defer func() { safehtml.OnUnsafeURL = nil }()
+	tmpl := Must(New("").Parse(`<a href="{{.}}">a</a><img src="/img/{{.}}">`))
+	var b strings.Builder
+	if err := tmpl.Execute(&b, "javascript:alert(1)"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"javascript:alert(1)"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("OnUnsafeURL called with %q, want %q", got, want)
+	}
+	got = nil
+	if err := tmpl.Execute(&b, "https://example.com/"); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("OnUnsafeURL called with %q for a safe URL", got)
+	}
+}
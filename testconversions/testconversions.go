@@ -4,7 +4,7 @@
 // license that can be found in the LICENSE file or at
 // https://developers.google.com/open-source/licenses/bsd
 
-// Package testconversions provides functions to to create arbitrary values of
+// Package testconversions provides functions to create arbitrary values of
 // package safehtml types for use by tests only. Note that the created values may
 // violate type contracts.
 //
@@ -9,7 +9,6 @@ package safehtml
 import (
 	"fmt"
 	"regexp"
-	"sort"
 	"strings"
 
 	"flag"
@@ -53,40 +52,29 @@ type TrustedResourceURL struct {
 // Map entries with empty keys or values are ignored. The order of appended
 // keys is guaranteed to be stable but may differ from the order in input.
 func TrustedResourceURLWithParams(t TrustedResourceURL, params map[string]string) TrustedResourceURL {
-	url := t.str
-	var fragment string
-	if i := strings.IndexByte(url, '#'); i != -1 {
-		// The fragment identifier component will always appear at the end
-		// of the URL after the query segment. It is therefore safe to
-		// trim the fragment from the tail of the URL and re-append it after
-		// all query parameters have been added.
-		// See https://tools.ietf.org/html/rfc3986#appendix-A.
-		fragment = url[i:]
-		url = url[:i]
-	}
-	sep := "?"
-	if i := strings.IndexRune(url, '?'); i != -1 {
-		// The first "?" in a URL indicates the start of the query component.
-		// See https://tools.ietf.org/html/rfc3986#section-3.4
-		if i == len(url)-1 {
-			sep = ""
-		} else {
-			sep = "&"
-		}
-	}
-	stringParams := make([]string, 0, len(params))
-	for k, v := range params {
-		if k == "" || v == "" {
-			continue
-		}
-		stringParam := safehtmlutil.QueryEscapeURL(k) + "=" + safehtmlutil.QueryEscapeURL(v)
-		stringParams = append(stringParams, stringParam)
+	return TrustedResourceURL{appendQueryParams(t.str, params)}
+}
+
+// TrustedResourceURLWithParam constructs a new TrustedResourceURL with the
+// given key-value pair added as a query parameter after any existing query
+// parameters and before any fragment. key and value are percent-encoded, so the
+// parameter cannot change the scheme, origin or path of t. This is useful for
+// adding a computed version to a constant asset URL:
+//
+//	TrustedResourceURLWithParam(TrustedResourceURLFromConstant("/static/app.js"), "v", hash)
+//
+// It returns an error if key is empty, or if key or value contains '&' or
+// '=', since such values usually indicate an attempt to add more than one
+// parameter. Use TrustedResourceURLWithParams to add several parameters.
+func TrustedResourceURLWithParam(t TrustedResourceURL, key, value string) (TrustedResourceURL, error) {
+	if key == "" {
+		return TrustedResourceURL{}, fmt.Errorf("cannot add a query parameter with an empty key to TrustedResourceURL %q", t)
 	}
-	if len(stringParams) > 0 {
-		sort.Strings(stringParams)
-		url += sep + strings.Join(stringParams, "&")
+	if strings.ContainsAny(key, "&=") || strings.ContainsAny(value, "&=") {
+		return TrustedResourceURL{}, fmt.Errorf("query parameter key %q or value %q contains '&' or '='", key, value)
 	}
-	return TrustedResourceURL{url + fragment}
+	param := safehtmlutil.QueryEscapeURL(key) + "=" + safehtmlutil.QueryEscapeURL(value)
+	return TrustedResourceURL{appendQuery(t.str, []string{param})}, nil
 }
 
 // TrustedResourceURLFromConstant constructs a TrustedResourceURL with its underlying
@@ -129,14 +117,40 @@ func TrustedResourceURLFormatFromFlag(format flag.Value, args map[string]string)
 	return trustedResourceURLFormat(fmt.Sprint(format.String()), args)
 }
 
+// TrustedResourceURLFormatFromConstantWithArgs is a variant of
+// TrustedResourceURLFormatFromConstant that accepts typed arguments.
+//
+// Each argument must be either a string or a TrustedResourceURL. String arguments
+// are URL-escaped and must not contain the ".." dot-segment, exactly as in
+// TrustedResourceURLFormatFromConstant. TrustedResourceURL arguments are inserted
+// verbatim, which allows callers to compose a TrustedResourceURL from other
+// TrustedResourceURLs (e.g. a trusted path or query component). Since verbatim
+// insertion may itself alter the prefix of the URL, the substituted result must
+// also have one of the prefixes allowed for the format string.
+//
+// See TrustedResourceURLFormatFromConstant for more details about format
+// string markers and validation.
+func TrustedResourceURLFormatFromConstantWithArgs(format stringConstant, args map[string]interface{}) (TrustedResourceURL, error) {
+	return trustedResourceURLFormatWithArgs(string(format), args)
+}
+
 func trustedResourceURLFormat(format string, args map[string]string) (TrustedResourceURL, error) {
+	typedArgs := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		typedArgs[k] = v
+	}
+	return trustedResourceURLFormatWithArgs(format, typedArgs)
+}
+
+func trustedResourceURLFormatWithArgs(format string, args map[string]interface{}) (TrustedResourceURL, error) {
 	if !safehtmlutil.IsSafeTrustedResourceURLPrefix(format) {
 		return TrustedResourceURL{}, fmt.Errorf("%q is a disallowed TrustedResourceURL format string", format)
 	}
 	var err error
+	insertedVerbatim := false
 	ret := trustedResourceURLFormatMarkerPattern.ReplaceAllStringFunc(format, func(match string) string {
 		argName := match[len("%{") : len(match)-len("}")]
-		argVal, ok := args[argName]
+		arg, ok := args[argName]
 		if !ok {
 			if err == nil {
 				// Report an error for the first missing argument.
@@ -144,17 +158,31 @@ func trustedResourceURLFormat(format string, args map[string]string) (TrustedRes
 			}
 			return ""
 		}
-		if safehtmlutil.URLContainsDoubleDotSegment(argVal) {
-			// Reject values containing the ".." dot-segment to prevent the final TrustedResourceURL from referencing
-			// a resource higher up in the path name hierarchy than the path specified in the prefix.
-			err = fmt.Errorf(`argument %q with value %q must not contain ".."`, argName, argVal)
+		switch argVal := arg.(type) {
+		case string:
+			if safehtmlutil.URLContainsDoubleDotSegment(argVal) {
+				// Reject values containing the ".." dot-segment to prevent the final TrustedResourceURL from referencing
+				// a resource higher up in the path name hierarchy than the path specified in the prefix.
+				err = fmt.Errorf(`argument %q with value %q must not contain ".."`, argName, argVal)
+				return ""
+			}
+			// QueryEscapeURL escapes some non-reserved characters in the path
+			// segment (e.g. '/' and '?') in order to prevent the injection of any new path
+			// segments or URL components.
+			return safehtmlutil.QueryEscapeURL(argVal)
+		case TrustedResourceURL:
+			insertedVerbatim = true
+			return argVal.str
+		default:
+			if err == nil {
+				err = fmt.Errorf("argument %q has unsupported type %T", argName, arg)
+			}
 			return ""
 		}
-		// QueryEscapeURL escapes some non-reserved characters in the path
-		// segment (e.g. '/' and '?') in order to prevent the injection of any new path
-		// segments or URL components.
-		return safehtmlutil.QueryEscapeURL(argVal)
 	})
+	if err == nil && insertedVerbatim && !safehtmlutil.IsSafeTrustedResourceURLPrefix(ret) {
+		return TrustedResourceURL{}, fmt.Errorf("%q is a disallowed TrustedResourceURL after substituting arguments", ret)
+	}
 	return TrustedResourceURL{ret}, err
 }
 
@@ -68,6 +68,64 @@ func TestTrustedResourceURLWithParams(t *testing.T) {
 	}
 }
 
+func TestTrustedResourceURLWithParam(t *testing.T) {
+	for _, test := range [...]struct {
+		tru        TrustedResourceURL
+		key, value string
+		want, err  string
+	}{
+		{
+			TrustedResourceURLFromConstant(`/static/app.js`),
+			`v`, `3f2a9c`,
+			`/static/app.js?v=3f2a9c`, ``,
+		},
+		{
+			TrustedResourceURLFromConstant(`https://cdn.example.com/app.js?lang=en#main`),
+			`v`, `3f2a9c`,
+			`https://cdn.example.com/app.js?lang=en&v=3f2a9c#main`, ``,
+		},
+		{
+			TrustedResourceURLFromConstant(`/static/app.js?`),
+			`v`, ``,
+			`/static/app.js?v=`, ``,
+		},
+		{
+			// Runes that could change the path, origin or fragment are escaped.
+			TrustedResourceURLFromConstant(`/static/app.js`),
+			`v`, `1/../../evil.js#?//evil.com`,
+			`/static/app.js?v=1%2f..%2f..%2fevil.js%23%3f%2f%2fevil.com`, ``,
+		},
+		{
+			TrustedResourceURLFromConstant(`/static/app.js`),
+			`v`, `1&admin=true`,
+			``, `query parameter key "v" or value "1&admin=true" contains '&' or '='`,
+		},
+		{
+			TrustedResourceURLFromConstant(`/static/app.js`),
+			`v=1`, `2`,
+			``, `query parameter key "v=1" or value "2" contains '&' or '='`,
+		},
+		{
+			TrustedResourceURLFromConstant(`/static/app.js`),
+			``, `3f2a9c`,
+			``, `cannot add a query parameter with an empty key to TrustedResourceURL "/static/app.js"`,
+		},
+	} {
+		got, err := TrustedResourceURLWithParam(test.tru, test.key, test.value)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("TrustedResourceURLWithParam(%q, %q, %q) error = %v, want %q", test.tru, test.key, test.value, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("TrustedResourceURLWithParam(%q, %q, %q) unexpected error: %v", test.tru, test.key, test.value, err)
+		} else if got.String() != test.want {
+			t.Errorf("TrustedResourceURLWithParam(%q, %q, %q) = %q, want %q", test.tru, test.key, test.value, got, test.want)
+		}
+	}
+}
+
 type testFlagValue string
 
 func (t *testFlagValue) String() string { return string(*t) }
@@ -186,6 +244,82 @@ func TestTrustedResourceURLFormat(t *testing.T) {
 	}
 }
 
+func TestTrustedResourceURLFormatWithArgs(t *testing.T) {
+	for _, test := range [...]struct {
+		desc, format string
+		args         map[string]interface{}
+		want, err    string
+	}{
+		{
+			"string arg escaped",
+			`/path/%{path}/`,
+			map[string]interface{}{"path": `d%/?#=`},
+			`/path/d%25%2f%3f%23%3d/`,
+			``,
+		},
+		{
+			"TrustedResourceURL arg inserted verbatim",
+			`/static/%{file}?v=%{version}`,
+			map[string]interface{}{"file": TrustedResourceURLFromConstant(`js/main.js`), "version": `1 2`},
+			`/static/js/main.js?v=1%202`,
+			``,
+		},
+		{
+			"TrustedResourceURL arg may contain dot segments",
+			`https://example.com/%{path}`,
+			map[string]interface{}{"path": TrustedResourceURLFromConstant(`a/../b`)},
+			`https://example.com/a/../b`,
+			``,
+		},
+		{
+			"TrustedResourceURL arg cannot change the prefix",
+			`/%{path}`,
+			map[string]interface{}{"path": TrustedResourceURLFromConstant(`\\evil.com/`)},
+			``,
+			`"/\\\\evil.com/" is a disallowed TrustedResourceURL after substituting arguments`,
+		},
+		{
+			"string arg with double dot segment",
+			`/path/%{path}`,
+			map[string]interface{}{"path": `..`},
+			``,
+			`argument "path" with value ".." must not contain ".."`,
+		},
+		{
+			"unsupported arg type",
+			`/path/%{path}`,
+			map[string]interface{}{"path": 42},
+			``,
+			`argument "path" has unsupported type int`,
+		},
+		{
+			"URL arg not supported",
+			`/path/%{path}`,
+			map[string]interface{}{"path": URLSanitized("foo")},
+			``,
+			`argument "path" has unsupported type safehtml.URL`,
+		},
+		{
+			"missing arg",
+			`/path/%{path}`,
+			nil,
+			``,
+			`expected argument named "path"`,
+		},
+	} {
+		got, err := trustedResourceURLFormatWithArgs(test.format, test.args)
+		if test.err != "" && err == nil {
+			t.Errorf("%s: expected error, unexpectedly got output: %s", test.desc, got)
+		} else if test.err == "" && err != nil {
+			t.Errorf("%s: unexpected error: %s", test.desc, err)
+		} else if test.err != "" && err.Error() != test.err {
+			t.Errorf("%s: got error:\n\t%s\nwant:\n\t%s", test.desc, err, test.err)
+		} else if test.err == "" && got.String() != test.want {
+			t.Errorf("%s: got:\n\t%s\nwant:\n\t%s", test.desc, got, test.want)
+		}
+	}
+}
+
 func TestTrustedResourceURLAppend(t *testing.T) {
 	for _, tc := range []struct {
 		name, toAppend, wantURL string
@@ -0,0 +1,42 @@
+// Copyright (c) 2021 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package uncheckedconversions
+
+import "github.com/google/safehtml/internal/audit"
+
+// Conversion describes a call to a function in this package or in package
+// safehtml/template/uncheckedconversions.
+type Conversion = audit.Conversion
+
+// AuditEnabled reports whether the program was built with the safehtml_audit
+// build tag.
+//
+// When the tag is set, every call to a function in this package or in package
+// safehtml/template/uncheckedconversions is recorded along with its stack
+// trace, so that tests can audit every unchecked conversion a program makes.
+// Conversions made internally by the safehtml packages are not recorded. When
+// the tag is not set, nothing is recorded and auditing has no cost.
+const AuditEnabled = audit.Enabled
+
+// AuditedConversions returns the conversions recorded since the program
+// started or ResetAuditedConversions was last called, in the order in which
+// they were made. It returns nil if AuditEnabled is false.
+func AuditedConversions() []Conversion {
+	return audit.Conversions()
+}
+
+// ResetAuditedConversions discards all recorded conversions.
+func ResetAuditedConversions() {
+	audit.Reset()
+}
+
+// SetAuditHook sets a function that is called synchronously with each
+// conversion as it is recorded, for example to log it. A nil hook removes the
+// previous hook. The hook is never called if AuditEnabled is false.
+func SetAuditHook(hook func(Conversion)) {
+	audit.SetHook(hook)
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2021 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package uncheckedconversions
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/safehtml/template"
+	tuc "github.com/google/safehtml/template/uncheckedconversions"
+)
+
+// Run with and without -tags safehtml_audit.
+func TestAudit(t *testing.T) {
+	ResetAuditedConversions()
+	var hooked []Conversion
+	SetAuditHook(func(c Conversion) { hooked = append(hooked, c) })
+	defer SetAuditHook(nil)
+
+	HTMLFromStringKnownToSatisfyTypeContract("<b>")
+	tuc.TrustedSourceFromStringKnownToSatisfyTypeContract("/templates")
+	// Conversions made internally by the template package are not recorded.
+	if _, err := template.New("t").Parse(`{{ . }}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := template.Must(template.New("t").Parse(`{{ . }}`)).ExecuteToHTML("x"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := AuditedConversions()
+	if !AuditEnabled {
+		if len(got) != 0 || len(hooked) != 0 {
+			t.Errorf("got %d recorded and %d hooked conversions without the safehtml_audit tag, want none", len(got), len(hooked))
+		}
+		return
+	}
+	wantFuncs := []string{
+		"github.com/google/safehtml/uncheckedconversions.HTMLFromStringKnownToSatisfyTypeContract",
+		"github.com/google/safehtml/template/uncheckedconversions.TrustedSourceFromStringKnownToSatisfyTypeContract",
+	}
+	if len(got) != len(wantFuncs) {
+		t.Fatalf("got %d recorded conversions, want %d: %+v", len(got), len(wantFuncs), got)
+	}
+	for i, c := range got {
+		if c.Func != wantFuncs[i] {
+			t.Errorf("conversion %d: got Func %q, want %q", i, c.Func, wantFuncs[i])
+		}
+		if !strings.HasPrefix(c.Stack, "github.com/google/safehtml/uncheckedconversions.TestAudit(...)\n\t") ||
+			!strings.Contains(c.Stack, "audit_test.go:") {
+			t.Errorf("conversion %d: got stack %q, want stack starting at TestAudit", i, c.Stack)
+		}
+	}
+	if len(hooked) != len(got) {
+		t.Errorf("hook called %d times, want %d", len(hooked), len(got))
+	}
+	ResetAuditedConversions()
+	if got := AuditedConversions(); len(got) != 0 {
+		t.Errorf("got %d conversions after reset, want none", len(got))
+	}
+}
@@ -19,9 +19,13 @@
 //   - Wrapping the result of rendering strictly contextually autoescaping
 //     templates (assuming the template's autoescaping implementation is indeed
 //     strict enough to support the type contract).
+//
+// Programs built with the safehtml_audit build tag record every call to
+// functions in this package; see AuditEnabled.
 package uncheckedconversions
 
 import (
+	"github.com/google/safehtml/internal/audit"
 	"github.com/google/safehtml/internal/raw"
 	"github.com/google/safehtml"
 )
@@ -36,6 +40,7 @@ var identifier = raw.Identifier.(func(string) safehtml.Identifier)
 
 // HTMLFromStringKnownToSatisfyTypeContract converts a string into a HTML.
 func HTMLFromStringKnownToSatisfyTypeContract(s string) safehtml.HTML {
+	audit.Record()
 	return html(s)
 }
 
@@ -51,6 +56,7 @@ func HTMLFromStringKnownToSatisfyTypeContract(s string) safehtml.HTML {
 // element, HTML character references, such as "&lt;" are not allowed. See
 // http://www.w3.org/TR/html5/scripting-1.html#restrictions-for-contents-of-script-elements.
 func ScriptFromStringKnownToSatisfyTypeContract(s string) safehtml.Script {
+	audit.Record()
 	return script(s)
 }
 
@@ -94,6 +100,7 @@ func ScriptFromStringKnownToSatisfyTypeContract(s string) safehtml.Script {
 //
 // See also http://www.w3.org/TR/css3-syntax/.
 func StyleFromStringKnownToSatisfyTypeContract(s string) safehtml.Style {
+	audit.Record()
 	return style(s)
 }
 
@@ -110,20 +117,24 @@ func StyleFromStringKnownToSatisfyTypeContract(s string) safehtml.Style {
 // http://www.w3.org/TR/html5/scripting-1.html#restrictions-for-contents-of-script-elements
 // (Similar considerations apply to the style element.)
 func StyleSheetFromStringKnownToSatisfyTypeContract(s string) safehtml.StyleSheet {
+	audit.Record()
 	return styleSheet(s)
 }
 
 // URLFromStringKnownToSatisfyTypeContract converts a string into a URL.
 func URLFromStringKnownToSatisfyTypeContract(s string) safehtml.URL {
+	audit.Record()
 	return url(s)
 }
 
 // TrustedResourceURLFromStringKnownToSatisfyTypeContract converts a string into a TrustedResourceURL.
 func TrustedResourceURLFromStringKnownToSatisfyTypeContract(s string) safehtml.TrustedResourceURL {
+	audit.Record()
 	return trustedResourceURL(s)
 }
 
 // IdentifierFromStringKnownToSatisfyTypeContract converts a string into a Identifier.
 func IdentifierFromStringKnownToSatisfyTypeContract(s string) safehtml.Identifier {
+	audit.Record()
 	return identifier(s)
 }
@@ -7,8 +7,15 @@
 package safehtml
 
 import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"regexp"
+	"sort"
 	"strings"
+
+	"github.com/google/safehtml/internal/safehtmlutil"
 )
 
 // A URL is an immutable string-like type that is safe to use in URL contexts in
@@ -45,6 +52,26 @@ type URL struct {
 // well-known.
 const InnocuousURL = "about:invalid#zGoSafez"
 
+// OnUnsafeURL, if non-nil, is called with each input that URLSanitized or a
+// URLSanitizerConfig rejects and replaces with InnocuousURL, or with the URL
+// set with URLSanitizerConfig.WithInnocuousURL. This includes URLs rejected
+// while executing templates. It is never called for URLs that are accepted, or
+// that are InnocuousURL themselves. It can be used to monitor rejected URLs,
+// for example by logging them.
+//
+// OnUnsafeURL must only be set during program initialization, such as in an
+// init function, before URLs are sanitized. It may be called concurrently
+// from multiple goroutines, and must not call URLSanitized or the methods of
+// URLSanitizerConfig.
+var OnUnsafeURL func(url string)
+
+// reportUnsafeURL calls OnUnsafeURL, if set, with url.
+func reportUnsafeURL(url string) {
+	if OnUnsafeURL != nil {
+		OnUnsafeURL(url)
+	}
+}
+
 // URLSanitized returns a URL whose value is url, validating that the input string matches
 // a pattern of commonly used safe URLs. If url fails validation, this method returns a
 // URL containing InnocuousURL.
@@ -54,76 +81,537 @@ const InnocuousURL = "about:invalid#zGoSafez"
 // absolute-path-relative, or path-relative. See
 // http://url.spec.whatwg.org/#concept-relative-url.
 //
+// The empty string is a path-relative URL that refers to the current document,
+// and is accepted and returned unchanged. To reject it, use a
+// URLSanitizerConfig with RejectEmptyURLs set.
+//
+// As in browsers, '\' is treated like '/' at the start of relative URLs. For
+// example, "\path\to\file" is absolute-path-relative, while "\\server\share"
+// and "\/example.com" are scheme-relative and refer to the hosts server and
+// example.com, just like "//server/share" and "//example.com". Such URLs are
+// accepted; use URLSanitizedToHosts to restrict the hosts that URLs may refer
+// to. However, a '\' does not end the search for a scheme, so URLs such as
+// "\\example.com:8080/" are rejected, as if "\\example.com" were a scheme.
+//
 // url may also be a base64 data URL with an allowed audio, image or video MIME type.
 //
+// Hosts may be internationalized domain names, such as "bücher.example", which
+// are accepted unchanged. See URLSanitizerConfig.NormalizeIDNHosts to convert
+// them to their ASCII form.
+//
+// url must not contain ASCII control characters (U+0000 to U+001F, and U+007F),
+// including TAB, LF and CR, which browsers strip or treat inconsistently when
+// parsing URLs.
+//
+// url may also be InnocuousURL, which is returned unchanged without further
+// validation, so that sanitizing the string form of an already-sanitized URL
+// never fails.
+//
+// URLSanitized is idempotent: URLSanitized(URLSanitized(url).String()) always
+// equals URLSanitized(url), so sanitized URLs may be safely stored, cached and
+// sanitized again. The same holds for every URLSanitizerConfig.
+//
 // No attempt is made at validating that the URL percent-decodes to structurally valid or
 // interchange-valid UTF-8 since the percent-decoded representation is unsafe to use in an
 // HTML context regardless of UTF-8 validity.
+//
+// Use a URLSanitizerConfig to accept absolute URLs with a different set of schemes.
+// For example, click-to-call links can be allowed with
+//
+//	c, err := NewURLSanitizerConfig("http", "https", "mailto", "ftp", "tel", "sms")
 func URLSanitized(url string) URL {
-	if !isSafeURL(url) {
-		return URL{InnocuousURL}
-	}
-	return URL{url}
+	return defaultURLSanitizerConfig.Sanitize(url)
 }
 
-// safeURLPattern matches URLs that
+// URLSanitizedOrError is like URLSanitized, but also returns an *UnsafeURLError
+// describing why url failed validation. If url fails validation, the returned
+// URL contains InnocuousURL.
 //
-//	(a) Start with a scheme in an allowlist (http, https, mailto, ftp); or
-//	(b) Contain no scheme. To ensure that the URL cannot be interpreted as a
-//	    disallowed scheme URL, ':' may only appear after one of the runes [/?#].
-//
-// The origin (RFC 6454) in which a URL is loaded depends on
-// its scheme.  We assume that the scheme used by the current document is HTTPS, HTTP, or
-// something equivalent.  We allow relative URLs unless in a particularly sensitive context
-// called a "TrustedResourceUrl" context. In a non-TrustedResourceURL context we allow absolute
-// URLs whose scheme is on a white-list.
+// This is useful at API boundaries that must reject unsafe input rather than
+// silently replace it.
+func URLSanitizedOrError(url string) (URL, error) {
+	return defaultURLSanitizerConfig.SanitizeOrError(url)
+}
+
+// URLSanitizedList is like URLSanitized, but sanitizes each of urls. It returns
+// the sanitized URLs, in the same order as urls, and the indices in urls of the
+// inputs that failed validation, in increasing order. The returned URLs at
+// those indices contain InnocuousURL.
+func URLSanitizedList(urls []string) ([]URL, []int) {
+	return defaultURLSanitizerConfig.SanitizeList(urls)
+}
+
+// URLSanitizedToHosts is like URLSanitized, but also returns a URL containing
+// InnocuousURL unless the sanitized URL is relative to the current host or
+// refers to one of allowedHosts. This is useful to prevent open redirects, such
+// as through a next= query parameter.
 //
-// The position of the first colon (':') character determines whether a URL is absolute or relative.
-// Looking at the prefix leading up to the first colon allows us to identify relative and absolute URLs,
-// extract the scheme, and minimize the risk of a user-agent concluding a URL specifies a scheme not in
-// our allowlist.
+// Path-, absolute-path-, query- and fragment-relative URLs are always
+// accepted. Absolute and scheme-relative URLs are accepted only if their host
+// is one of allowedHosts, matched case-insensitively. URLs such as mailto and
+// data URLs that have no host are rejected. Browsers treat '\' like '/' and
+// ignore any number of slashes before the host in http and https URLs, so
+// URLs such as "/\evil.com" and "https:evil.com" are checked against the
+// host they navigate to.
 //
-// According to RFC 3986 Section 3, the normative interpretation of the canonicial WHATWG specification
-// (https://url.spec.whatwg.org/#url-scheme-string), colons can appear in a URL in these locations:
-//   - A colon after a non-empty run of (ALPHA *( ALPHA / DIGIT / "+" / "-" / "." )) ends a scheme.
-//     If the colon after the scheme is not followed by "//" then any subsequent colons are part
-//     of an opaque URI body.
-//   - Otherwise, a colon after a hash (#) must be in the fragment.
-//   - Otherwise, a colon after a (?) must be in the query.
-//   - Otherwise, a colon after a single solidus ("/") must be in the path.
-//   - Otherwise, a colon after a double solidus ("//") must be in the authority (before port).
-//   - Otherwise, a colon after a valid protocol must be in the opaque part of the URL.
-var safeURLPattern = regexp.MustCompile(`^(?:(?:https?|mailto|ftp):|[^:/?#]*(?:[/?#]|$))`)
+// An allowed host may include a port, as in "example.com:8443", to accept
+// only that port. An allowed host without a port accepts only URLs with no
+// port or the default port of their scheme. Subdomains are not matched
+// implicitly: "example.com" does not accept "www.example.com", which must be
+// listed separately.
+func URLSanitizedToHosts(url string, allowedHosts []string) URL {
+	return defaultURLSanitizerConfig.SanitizeToHosts(url, allowedHosts)
+}
+
+// URLSanitizedForImage is like URLSanitized, but only accepts data URLs with an
+// image MIME type. It is intended for sinks such as the src attribute of an
+// img element, so that, for example, an avatar URL cannot carry a video.
+// Relative URLs and URLs with other schemes are accepted as by URLSanitized.
+func URLSanitizedForImage(url string) URL {
+	return defaultURLSanitizerConfig.SanitizeForMedia(url, MediaFamilyImage)
+}
+
+// URLSanitizedForAudio is like URLSanitizedForImage, but only accepts data URLs
+// with an audio MIME type.
+func URLSanitizedForAudio(url string) URL {
+	return defaultURLSanitizerConfig.SanitizeForMedia(url, MediaFamilyAudio)
+}
+
+// URLSanitizedForVideo is like URLSanitizedForImage, but only accepts data URLs
+// with a video MIME type.
+func URLSanitizedForVideo(url string) URL {
+	return defaultURLSanitizerConfig.SanitizeForMedia(url, MediaFamilyVideo)
+}
 
 // dataURLPattern matches base-64 data URLs (RFC 2397), with the first capture group being the media type
 // specification given as a MIME type.
 //
-// Note: this pattern does not match data URLs containig media type specifications with optional parameters,
-// such as `data:text/javascript;charset=UTF-8;base64,...`. This is ok since this pattern only needs to
-// match audio, image and video MIME types in its capture group.
-var dataURLPattern = regexp.MustCompile(`^data:([^;,]*);base64,[a-z0-9+/]+=*$`)
+// The MIME type may be followed by any number of parameters of the form `;attribute=value`, such as
+// `data:image/png;charset=UTF-8;base64,...`. Attributes and values may only contain a conservative
+// subset of the token runes defined in RFC 2045 Section 5.1, which excludes ',', ';', whitespace and
+// control characters, so that parameters cannot be used to smuggle in additional URL components.
+//
+// The scheme, media type specification and base64 token are matched case-insensitively. The base64-encoded
+// data is case-sensitive, and is matched against its original case so that the validated string is exactly
+// the string that ends up in the URL. Callers must lowercase the captured MIME type before inspecting it.
+var dataURLPattern = regexp.MustCompile(`^(?i:data:([^;,]*)(?:;` + dataURLParamToken + `=` + dataURLParamToken + `)*;base64),[A-Za-z0-9+/]+=*$`)
+
+// dataURLParamToken matches an attribute or value of a data URL media type parameter.
+const dataURLParamToken = `[a-z0-9!$*+.^_~-]+`
 
 // safeMIMETypePattern matches MIME types that are safe to include in a data URL.
 var safeMIMETypePattern = regexp.MustCompile(`^(?:audio/(?:3gpp2|3gpp|aac|midi|mp3|mp4|mpeg|oga|ogg|opus|x-m4a|x-matroska|x-wav|wav|webm)|image/(?:bmp|gif|jpeg|jpg|png|tiff|webp|x-icon)|video/(?:mpeg|mp4|ogg|webm|x-matroska))$`)
 
-// isSafeURL matches url to a subset of URLs that will not cause script execution if used in
-// a URL context within a HTML document. Specifically, this method returns true if url:
+// IsSafeURL reports whether url is accepted by URLSanitized, that is, whether
+// URLSanitized(url) returns a URL whose value is url rather than InnocuousURL.
+//
+// IsSafeURL does not allocate a URL value, so it is suitable for validating
+// input before deciding whether to store it verbatim.
+func IsSafeURL(url string) bool {
+	return defaultURLSanitizerConfig.isSafeURL(url)
+}
+
+// URLAppendPath returns a URL formed by appending segment as a new path segment
+// to the path of u. segment is percent-encoded, so it cannot introduce further
+// path segments, a query, a fragment or a scheme. A '/' is inserted between the
+// existing path and segment unless the path already ends in '/'. Any query and
+// fragment of u are preserved after the new path segment.
 //
-//	(a) Starts with a scheme in the default allowlist (http, https, mailto, ftp); or
-//	(b) Contains no scheme. To ensure that the URL cannot be interpreted as a
-//	    disallowed scheme URL, the runes ':', and '&' may only appear
-//	    after one of the runes [/?#].
-func isSafeURL(url string) bool {
-	// Ignore case.
-	url = strings.ToLower(url)
-	if safeURLPattern.MatchString(url) {
-		return true
+// If u is InnocuousURL, or is a data URL, which does not have a path that can
+// be appended to, URLAppendPath returns a URL containing InnocuousURL.
+func URLAppendPath(u URL, segment string) URL {
+	if !isAppendableURL(u.str) {
+		return URL{InnocuousURL}
 	}
-	submatches := dataURLPattern.FindStringSubmatch(url)
-	return len(submatches) == 2 && safeMIMETypePattern.MatchString(submatches[1])
+	url, suffix := splitURLSuffix(u.str, "?#")
+	if !strings.HasSuffix(url, "/") {
+		url += "/"
+	}
+	return URL{url + safehtmlutil.QueryEscapeURL(segment) + suffix}
+}
+
+// URLWithParams returns a URL formed by adding the given key-value pairs to the
+// query of u. Keys and values are percent-encoded, and any fragment of u is
+// preserved after the query.
+//
+// Map entries with empty keys or values are ignored. The order of appended
+// keys is guaranteed to be stable but may differ from the order in input.
+//
+// If u is InnocuousURL, or is a data URL, which does not have a query that can
+// be appended to, URLWithParams returns a URL containing InnocuousURL.
+func URLWithParams(u URL, params map[string]string) URL {
+	if !isAppendableURL(u.str) {
+		return URL{InnocuousURL}
+	}
+	return URL{appendQueryParams(u.str, params)}
+}
+
+// isAppendableURL reports whether path segments and query parameters can be
+// appended to url.
+func isAppendableURL(url string) bool {
+	return url != InnocuousURL && urlScheme(strings.ToLower(url)) != "data"
+}
+
+// WithFragment returns u with its fragment, if any, replaced by frag. frag is
+// percent-encoded, so it cannot change how the rest of the URL is interpreted.
+// The path and query of u are preserved.
+//
+// If u is InnocuousURL or a data URL, WithFragment returns u unchanged.
+func (u URL) WithFragment(frag string) URL {
+	if !isAppendableURL(u.str) {
+		return u
+	}
+	url, _ := splitURLSuffix(u.str, "#")
+	return URL{url + "#" + safehtmlutil.QueryEscapeURL(frag)}
+}
+
+// WithoutFragment returns u with its fragment, if any, removed.
+//
+// If u is InnocuousURL or a data URL, WithoutFragment returns u unchanged.
+func (u URL) WithoutFragment() URL {
+	if !isAppendableURL(u.str) {
+		return u
+	}
+	url, _ := splitURLSuffix(u.str, "#")
+	return URL{url}
+}
+
+// Normalized returns u with its path, query and fragment in a canonical
+// percent-encoded form, so that URLs that browsers treat identically compare
+// equal.
+//
+// Normalized uppercases the hexadecimal digits of percent-encoded bytes and
+// percent-encodes spaces, the runes "<>`{}, and all non-ASCII bytes. It never
+// decodes percent-encoded bytes, and leaves the scheme and authority of u
+// unchanged, so the result is accepted by URLSanitized if and only if u is.
+// Normalized is idempotent.
+//
+// If u is InnocuousURL or a data URL, Normalized returns u unchanged.
+func (u URL) Normalized() URL {
+	if !isAppendableURL(u.str) {
+		return u
+	}
+	// The scheme and authority cannot be percent-encoded without changing
+	// their meaning.
+	start := authorityEnd(u.str)
+	return URL{u.str[:start] + normalizePercentEncoding(u.str[start:])}
+}
+
+// authorityEnd returns the index in url of the end of its scheme and
+// authority, if any, which is the start of its path.
+func authorityEnd(url string) int {
+	scheme := urlScheme(url)
+	start := 0
+	if scheme != "" {
+		start = len(scheme) + len(":")
+	}
+	rest, delims := url[start:], "/?#"
+	switch {
+	case (scheme == "" || specialSchemes[strings.ToLower(scheme)]) && len(rest) >= 2 && safehtmlutil.IsURLSlash(rest[0]) && safehtmlutil.IsURLSlash(rest[1]):
+		// Browsers treat '\' like '/', and ignore any number of slashes
+		// before the authority, in relative URLs and URLs with special
+		// schemes, so "\\example.com" has the authority "example.com".
+		start += len(rest) - len(strings.TrimLeft(rest, `/\`))
+		delims = `/\?#`
+	case strings.HasPrefix(rest, "//"):
+		start += len("//")
+	default:
+		return start
+	}
+	if i := strings.IndexAny(url[start:], delims); i != -1 {
+		return start + i
+	}
+	return len(url)
+}
+
+// normalizePercentEncoding implements URL.Normalized for the path, query and
+// fragment of a URL.
+func normalizePercentEncoding(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b bytes.Buffer
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			b.WriteByte('%')
+			b.WriteByte(upperHex(s[i+1]))
+			b.WriteByte(upperHex(s[i+2]))
+			i += 2
+		case c == ' ', c == '"', c == '<', c == '>', c == '`', c == '{', c == '}', c >= 0x80:
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0xF])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// isHex reports whether c is a hexadecimal digit.
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// upperHex returns the uppercase form of the hexadecimal digit c.
+func upperHex(c byte) byte {
+	if 'a' <= c && c <= 'f' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
+
+// splitURLSuffix splits url immediately before the first occurrence of any of
+// the bytes in chars. If none are present, suffix is empty.
+func splitURLSuffix(url, chars string) (prefix, suffix string) {
+	if i := strings.IndexAny(url, chars); i != -1 {
+		return url[:i], url[i:]
+	}
+	return url, ""
+}
+
+// appendQueryParams returns url with the given key-value pairs appended to its
+// query component. See TrustedResourceURLWithParams.
+func appendQueryParams(url string, params map[string]string) string {
+	stringParams := make([]string, 0, len(params))
+	for k, v := range params {
+		if k == "" || v == "" {
+			continue
+		}
+		stringParam := safehtmlutil.QueryEscapeURL(k) + "=" + safehtmlutil.QueryEscapeURL(v)
+		stringParams = append(stringParams, stringParam)
+	}
+	sort.Strings(stringParams)
+	return appendQuery(url, stringParams)
+}
+
+// appendQuery returns url with the given percent-encoded key=value pairs
+// added to its query, in order, before any fragment.
+func appendQuery(url string, stringParams []string) string {
+	if len(stringParams) == 0 {
+		return url
+	}
+	// The fragment identifier component will always appear at the end
+	// of the URL after the query segment. It is therefore safe to
+	// trim the fragment from the tail of the URL and re-append it after
+	// all query parameters have been added.
+	// See https://tools.ietf.org/html/rfc3986#appendix-A.
+	url, fragment := splitURLSuffix(url, "#")
+	sep := "?"
+	if i := strings.IndexRune(url, '?'); i != -1 {
+		// The first "?" in a URL indicates the start of the query component.
+		// See https://tools.ietf.org/html/rfc3986#section-3.4
+		if i == len(url)-1 {
+			sep = ""
+		} else {
+			sep = "&"
+		}
+	}
+	return url + sep + strings.Join(stringParams, "&") + fragment
 }
 
 // String returns the string form of the URL.
 func (u URL) String() string {
 	return u.str
 }
+
+// AppendTo appends the string form of u to dst and returns the extended buffer.
+// Like append(dst, u.String()...), it does not allocate if dst has enough
+// capacity, which makes it convenient for building large buffers of URLs.
+func (u URL) AppendTo(dst []byte) []byte {
+	return append(dst, u.str...)
+}
+
+// Scheme returns the lowercase scheme of u, or the empty string if u is a
+// relative URL.
+//
+// As in URLSanitized, the scheme is the text preceding the first ':' in u,
+// provided that no '/', '?' or '#' precedes that ':'. For example, the scheme
+// of "mailto:a@example.com" is "mailto", the scheme of a data URL is "data",
+// the scheme of InnocuousURL is "about", and scheme-relative URLs such as
+// "//example.com/a:b" have no scheme.
+func (u URL) Scheme() string {
+	return strings.ToLower(urlScheme(u.str))
+}
+
+// IsRelative reports whether u is a relative URL, that is, whether it has no
+// scheme. Scheme-relative URLs such as "//example.com/" are relative URLs,
+// since their scheme is that of the document they are resolved against.
+func (u URL) IsRelative() bool {
+	return urlScheme(u.str) == ""
+}
+
+// DataURLMIMEType returns the lowercase MIME type of u, such as "image/png",
+// and true if u is a base64 data URL. Any parameters following the MIME type are
+// excluded, and the MIME type is empty if u omits it. It returns false if u is
+// not a data URL, or is a data URL that URLSanitized would not parse, such as
+// one accepted by URLSanitizerConfig.UnknownSchemeHook that is not base64.
+func (u URL) DataURLMIMEType() (mime string, ok bool) {
+	return dataURLMIMEType(u.str)
+}
+
+// dataURLMIMEType returns the lowercase MIME type of url and true if url
+// matches dataURLPattern.
+func dataURLMIMEType(url string) (string, bool) {
+	submatches := dataURLPattern.FindStringSubmatch(url)
+	if len(submatches) != 2 {
+		return "", false
+	}
+	return strings.ToLower(submatches[1]), true
+}
+
+// Host returns the host of u as a browser would parse it, or "" if u has no
+// authority, as is the case for relative URLs such as "/path" and URLs such as
+// mailto URLs, or if its host is not a valid ASCII host.
+//
+// Host follows https://url.spec.whatwg.org/#url-parsing, as URLSanitizedToHosts
+// does. Any userinfo and port are excluded, so the host of
+// "https://user@evil.com@example.com:8080/" is "example.com", and IPv6
+// addresses keep their brackets, as in "[::1]". Percent-encoded bytes in the host
+// are decoded and the result is lowercased. Browsers treat '\' like '/' in
+// scheme-relative URLs and URLs with special schemes such as http, so the host
+// of "https://example.com\@evil.com/" is "example.com", and they ignore tabs and
+// newlines, which URLSanitized rejects.
+func (u URL) Host() string {
+	_, authority, ok := urlAuthority(u.str)
+	if !ok {
+		return ""
+	}
+	if i := strings.LastIndexByte(authority, '@'); i != -1 {
+		authority = authority[i+1:]
+	}
+	host, _, ok := splitHostPort(authority)
+	if !ok {
+		return ""
+	}
+	if strings.HasPrefix(host, "[") {
+		if !ipv6HostPattern.MatchString(host) {
+			return ""
+		}
+		return host
+	}
+	host, ok = percentDecode(host)
+	if !ok {
+		return ""
+	}
+	if host, ok = asciiToLower(host); !ok || strings.IndexFunc(host, isForbiddenHostRune) != -1 {
+		return ""
+	}
+	return host
+}
+
+// ipv6HostPattern matches bracketed IPv6 address hosts.
+var ipv6HostPattern = regexp.MustCompile(`^\[[0-9a-f:.]+\]$`)
+
+// isForbiddenHostRune reports whether r is a forbidden domain code point, as
+// defined in https://url.spec.whatwg.org/#forbidden-domain-code-point.
+func isForbiddenHostRune(r rune) bool {
+	return r <= ' ' || r == 0x7f || strings.ContainsRune(`#%/:<>?@[\]^|`, r)
+}
+
+// percentDecode returns s with its percent-encoded bytes decoded. It reports
+// false if s contains a '%' that does not start a percent-encoded byte.
+func percentDecode(s string) (string, bool) {
+	if !strings.Contains(s, "%") {
+		return s, true
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			return "", false
+		}
+		b.WriteByte(unhex(s[i+1])<<4 | unhex(s[i+2]))
+		i += 2
+	}
+	return b.String(), true
+}
+
+// unhex returns the value of the hexadecimal digit c.
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	}
+	return c - 'A' + 10
+}
+
+// Equal reports whether u and other have the same string form.
+//
+// URLs containing InnocuousURL compare equal to each other, regardless of the
+// unsafe inputs they were sanitized from.
+func (u URL) Equal(other URL) bool {
+	return u.str == other.str
+}
+
+// MarshalJSON returns the string form of u encoded as a JSON string.
+func (u URL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(u.str)
+}
+
+// UnmarshalJSON decodes data, which must be a JSON string, and sets u to the
+// result of passing the decoded string to URLSanitized. Unsafe URLs therefore
+// decode to InnocuousURL.
+func (u *URL) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '"' {
+		return fmt.Errorf("cannot unmarshal non-string JSON value %.32q into a URL", data)
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*u = URLSanitized(s)
+	return nil
+}
+
+// MarshalText returns the string form of u.
+func (u URL) MarshalText() ([]byte, error) {
+	return []byte(u.str), nil
+}
+
+// UnmarshalText sets u to the result of passing text to URLSanitized.
+// Unsafe URLs therefore decode to InnocuousURL.
+func (u *URL) UnmarshalText(text []byte) error {
+	*u = URLSanitized(string(text))
+	return nil
+}
+
+// MarshalBinary returns the string form of u.
+func (u URL) MarshalBinary() ([]byte, error) {
+	return []byte(u.str), nil
+}
+
+// UnmarshalBinary sets u to the result of passing data to URLSanitized, so
+// that URL values decoded by binary codecs such as encoding/gob are safe even
+// if the encoded data has been tampered with. Unsafe URLs therefore decode to
+// InnocuousURL.
+func (u *URL) UnmarshalBinary(data []byte) error {
+	*u = URLSanitized(string(data))
+	return nil
+}
+
+// Value implements driver.Valuer by returning the string form of u.
+func (u URL) Value() (driver.Value, error) {
+	return u.str, nil
+}
+
+// Scan implements sql.Scanner by setting u to the result of passing src, which
+// must be a string or []byte, to URLSanitized. Unsafe URLs read from the
+// database are therefore replaced by InnocuousURL.
+func (u *URL) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case string:
+		*u = URLSanitized(src)
+	case []byte:
+		*u = URLSanitized(string(src))
+	default:
+		return fmt.Errorf("cannot scan value of type %T into a URL", src)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2017 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+//go:build go1.18
+// +build go1.18
+
+package safehtml
+
+import (
+	"strings"
+	"testing"
+)
+
+func FuzzIsSafeURL(f *testing.F) {
+	for _, seed := range [...]string{
+		"http://www.example.com",
+		"/path?q=a:b#frag",
+		"javascript:alert(1)",
+		"JaVaScRiPt:alert(1)",
+		" javascript:alert(1)",
+		"\x01javascript:alert(1)",
+		"java\tscript:alert(1)",
+		"java\nscript:alert(1)",
+		"java\rscript:alert(1)",
+		"java\x00script:alert(1)",
+		"&#x6a;avascript:alert(1)",
+		"javascript&colon;alert(1)",
+		"vbscript:msgbox(1)",
+		"data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==",
+		"data:image/svg+xml;base64,PHN2Zz4=",
+		"data:image/png;base64,iVBORw0KGgo=",
+		"DATA:IMAGE/PNG;BASE64,iVBORw0KGgo=",
+		"data:image/png;charset=utf-8;base64,iVBORw0KGgo=",
+		"//example.com/a:b",
+		"\\\\example.com",
+		"mailto:foo@example.com",
+		InnocuousURL,
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, url string) {
+		if !containsASCIIControl(url) {
+			got, want := defaultURLSanitizerConfig.validate(url), defaultURLSanitizerConfig.validatePatterns(url)
+			if (got == nil) != (want == nil) || got != nil && *got != *want {
+				t.Errorf("validate(%q) = %v, validatePatterns(%q) = %v", url, got, url, want)
+			}
+		}
+		normalized := URL{url}.Normalized().String()
+		if IsSafeURL(normalized) != IsSafeURL(url) {
+			t.Errorf("IsSafeURL(%q) = %t, but IsSafeURL of its normalized form %q = %t", url, IsSafeURL(url), normalized, !IsSafeURL(url))
+		}
+		if got := (URL{normalized}).Normalized().String(); got != normalized {
+			t.Errorf("URL{%q}.Normalized() = %q is not idempotent, normalizing again gives %q", url, normalized, got)
+		}
+		if once := URLSanitized(url).String(); URLSanitized(once).String() != once {
+			t.Errorf("URLSanitized(%q) = %q is not idempotent, sanitizing again gives %q", url, once, URLSanitized(once))
+		}
+		if !IsSafeURL(url) {
+			return
+		}
+		if got := URLSanitized(url).String(); got != url {
+			t.Errorf("IsSafeURL(%q) = true, but URLSanitized returned %q", url, got)
+		}
+		if scheme := (URL{url}).Scheme(); url != InnocuousURL && scheme != "" && scheme != "data" && !defaultURLSanitizerConfig.allowsScheme(scheme) {
+			t.Errorf("IsSafeURL(%q) = true, but its scheme %q is not allowed", url, scheme)
+		}
+		stripped := strings.ToLower(browserStripURL(url))
+		for _, prefix := range [...]string{"javascript:", "vbscript:"} {
+			if strings.HasPrefix(stripped, prefix) {
+				t.Errorf("IsSafeURL(%q) = true, but the URL has the disallowed scheme %q after whitespace stripping", url, prefix)
+			}
+		}
+		if strings.HasPrefix(stripped, "data:") {
+			submatches := dataURLPattern.FindStringSubmatch(url)
+			if len(submatches) != 2 || !safeMIMETypePattern.MatchString(strings.ToLower(submatches[1])) {
+				t.Errorf("IsSafeURL(%q) = true, but it is not an allowed media data URL", url)
+			}
+		}
+	})
+}
+
+// browserStripURL approximates the preprocessing browsers apply to a URL before
+// parsing it, as specified in https://url.spec.whatwg.org/#concept-basic-url-parser:
+// all ASCII tab and newline runes are removed, and leading and trailing C0 control
+// or space runes are trimmed.
+func browserStripURL(url string) string {
+	url = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, url)
+	return strings.TrimFunc(url, func(r rune) bool {
+		return r <= ' '
+	})
+}
@@ -0,0 +1,201 @@
+// Copyright (c) 2017 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package safehtml
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// upstreamGoldenFile records the classification of upstreamURLCorpus by the
+// upstream google/safehtml URL sanitizer.
+const upstreamGoldenFile = "testdata/upstream_urls.golden"
+
+// upstreamIsSafeURL is the URL classification implemented by URLSanitized in
+// github.com/google/safehtml at the point this package was forked. It is used
+// only to regenerate upstreamGoldenFile.
+func upstreamIsSafeURL(url string) bool {
+	var (
+		safeURLPattern      = regexp.MustCompile(`^(?:(?:https?|mailto|ftp):|[^:/?#]*(?:[/?#]|$))`)
+		dataURLPattern      = regexp.MustCompile(`^data:([^;,]*);base64,[a-z0-9+/]+=*$`)
+		safeMIMETypePattern = regexp.MustCompile(`^(?:audio/(?:3gpp2|3gpp|aac|midi|mp3|mp4|mpeg|oga|ogg|opus|x-m4a|x-matroska|x-wav|wav|webm)|image/(?:bmp|gif|jpeg|jpg|png|tiff|webp|x-icon)|video/(?:mpeg|mp4|ogg|webm|x-matroska))$`)
+	)
+	url = strings.ToLower(url)
+	if safeURLPattern.MatchString(url) {
+		return true
+	}
+	submatches := dataURLPattern.FindStringSubmatch(url)
+	return len(submatches) == 2 && safeMIMETypePattern.MatchString(submatches[1])
+}
+
+// upstreamDeviations documents the intentional differences between
+// URLSanitized and the upstream classification. Each deviation reports
+// whether it explains why url is classified as safe by this package.
+var upstreamDeviations = [...]struct {
+	desc    string
+	explain func(url string, safe bool) bool
+}{
+	{
+		"URLs containing ASCII control characters are rejected",
+		func(url string, safe bool) bool { return !safe && containsASCIIControl(url) },
+	},
+	{
+		"InnocuousURL is accepted unchanged",
+		func(url string, safe bool) bool { return safe && url == InnocuousURL },
+	},
+	{
+		"data URLs may contain media type parameters",
+		func(url string, safe bool) bool {
+			submatches := dataURLPattern.FindStringSubmatch(url)
+			return safe && len(submatches) == 2 && strings.ContainsRune(url[:strings.IndexByte(url, ',')], '=')
+		},
+	},
+}
+
+// upstreamURLCorpus returns the URLs whose classification is compared against
+// upstream, in sorted order.
+func upstreamURLCorpus() []string {
+	schemes := []string{
+		"", "http:", "HTTP:", "https:", "mailto:", "ftp:", "Ftp:", "javascript:", "JaVaScRiPt:",
+		"vbscript:", "data:", "tel:", "sms:", "blob:", "file:", "ws:", "wss:", "about:", "chrome:",
+		"android-app:", "intent:", " javascript:", "java\tscript:", "java\nscript:", "\x00javascript:",
+		"javascript\x7f:", "java&#115;cript:", "java%73cript:", "\u00a0javascript:", "ja\u200bvascript:",
+		"http\x00:", "h:", "1http:", "+http:", "a.b-c+d:",
+	}
+	bodies := []string{
+		"", "//example.com/", "//example.com:8080/a:b?c:d#e:f", "//[::1]:80/", "//user:pass@example.com/",
+		"alert(1)", "alert(1)//", "%0aalert(1)", "/path", "path", "?q=a:b", "#frag:x", "foo@example.com",
+		"+1-555-0100", "\\\\example.com", "/\\example.com", "//example.com/\n",
+		"image/png;base64,iVBORw0KGgo=", "text/html;base64,PHNjcmlwdD4=",
+	}
+	urls := map[string]bool{}
+	for _, s := range schemes {
+		for _, b := range bodies {
+			urls[s+b] = true
+		}
+	}
+	for _, u := range []string{
+		// Relative URLs.
+		"", "/", "//", "./a:b", "../a", "a/b:c", "a?b:c", "a#b:c", "a:b", ":", ":a", "&colon;", "a&b:c",
+		"café", "café:x", "/café", "é:x",
+		// Data URLs.
+		"data:image/png;base64,iVBORw0KGgo=",
+		"data:IMAGE/PNG;BASE64,iVBORw0KGgo=",
+		"data:image/png;base64,iVBORw0KGgo",
+		"data:image/png;base64,iVBORw0KGgo==",
+		"data:image/png;base64,iVBOR w0KGgo=",
+		"data:image/png;base64,iVBORw0\nKGgo=",
+		"data:image/png;base64,",
+		"data:image/png,iVBORw0KGgo=",
+		"data:image/png;charset=utf-8;base64,iVBORw0KGgo=",
+		"data:image/png;name=a.png;base64,iVBORw0KGgo=",
+		"data:image/png;name=;base64,iVBORw0KGgo=",
+		"data:image/svg+xml;base64,PHN2Zz4=",
+		"data:image/webp;base64,UklGRg==",
+		"data:image/x-icon;base64,AAABAA==",
+		"data:video/mp4;base64,AAAAIGZ0eXA=",
+		"data:video/x-matroska;base64,GkXfow==",
+		"data:audio/ogg;base64,T2dnUw==",
+		"data:audio/L16;base64,AAAA",
+		"data:font/woff2;base64,d09GMg==",
+		"data:text/html;base64,PHNjcmlwdD4=",
+		"data:text/javascript;base64,YWxlcnQoMSk=",
+		"data:application/javascript;base64,YWxlcnQoMSk=",
+		"data:;base64,AAAA",
+		"data:image/png;base64,iVBORw0KGgo=#frag",
+		"data:image/png;base64,iVBORw0KGgo=?q",
+		" data:image/png;base64,iVBORw0KGgo=",
+		InnocuousURL,
+	} {
+		urls[u] = true
+	}
+	var ret []string
+	for u := range urls {
+		ret = append(ret, u)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func TestURLSanitizedMatchesUpstream(t *testing.T) {
+	corpus := upstreamURLCorpus()
+	if *update {
+		var b bytes.Buffer
+		b.WriteString("# Classification of URLs by the upstream google/safehtml URLSanitized.\n")
+		b.WriteString("# Regenerate with: go test -run TestURLSanitizedMatchesUpstream -update\n")
+		for _, url := range corpus {
+			verdict := "unsafe"
+			if upstreamIsSafeURL(url) {
+				verdict = "safe"
+			}
+			fmt.Fprintf(&b, "%s\t%s\n", verdict, strconv.Quote(url))
+		}
+		if err := ioutil.WriteFile(upstreamGoldenFile, b.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Open(upstreamGoldenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	golden := map[string]bool{}
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		if s.Text() == "" || strings.HasPrefix(s.Text(), "#") {
+			continue
+		}
+		fields := strings.SplitN(s.Text(), "\t", 2)
+		if len(fields) != 2 || fields[0] != "safe" && fields[0] != "unsafe" {
+			t.Fatalf("%s:%d: malformed line %q", upstreamGoldenFile, line, s.Text())
+		}
+		url, err := strconv.Unquote(fields[1])
+		if err != nil {
+			t.Fatalf("%s:%d: malformed URL %s: %v", upstreamGoldenFile, line, fields[1], err)
+		}
+		golden[url] = fields[0] == "safe"
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(golden) != len(corpus) {
+		t.Fatalf("%s contains %d URLs, but the corpus contains %d; run with -update", upstreamGoldenFile, len(golden), len(corpus))
+	}
+
+	for _, url := range corpus {
+		upstream, ok := golden[url]
+		if !ok {
+			t.Fatalf("%s does not contain %q; run with -update", upstreamGoldenFile, url)
+		}
+		safe := IsSafeURL(url)
+		if safe == upstream {
+			continue
+		}
+		explained := false
+		for _, d := range upstreamDeviations {
+			if d.explain(url, safe) {
+				explained = true
+				break
+			}
+		}
+		if !explained {
+			t.Errorf("IsSafeURL(%q) = %t, but upstream classifies it as %t; document any intentional deviation in upstreamDeviations", url, safe, upstream)
+		}
+	}
+}
@@ -0,0 +1,1069 @@
+// Copyright (c) 2017 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package safehtml
+
+import (
+	"fmt"
+	neturl "net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/safehtml/internal/safehtmlutil"
+	"golang.org/x/net/idna"
+)
+
+// A URLSanitizerConfig determines which URLs are accepted by its Sanitize method.
+//
+// Relative URLs and base64 data URLs with an allowed audio, image or video MIME
+// type are always accepted. Absolute URLs are accepted only if their scheme is
+// one of the schemes the URLSanitizerConfig was constructed with, or is
+// accepted by UnknownSchemeHook.
+//
+// A URLSanitizerConfig must be constructed using NewURLSanitizerConfig.
+type URLSanitizerConfig struct {
+	// schemes contains the lowercase schemes allowed in absolute URLs.
+	schemes []string
+	// safeURLPattern matches URLs that start with a scheme in schemes, or that
+	// contain no scheme. See compileSafeURLPattern for details.
+	safeURLPattern *regexp.Regexp
+	// dataMIMETypes contains the lowercase MIME types added with
+	// WithDataMIMETypes. It is never modified in place, since copies of c
+	// share it.
+	dataMIMETypes []string
+	// innocuousURL, if non-empty, replaces InnocuousURL as the URL returned
+	// for unsafe URLs. See WithInnocuousURL.
+	innocuousURL string
+
+	// AllowFontDataURLs causes base64 data URLs with a font MIME type (font/woff2,
+	// font/woff, font/ttf, font/otf, or application/font-woff) to be accepted,
+	// in addition to data URLs with the audio, image and video MIME types
+	// accepted by URLSanitized.
+	AllowFontDataURLs bool
+
+	// UnknownSchemeHook, if non-nil, is called with the lowercase scheme of an
+	// absolute URL whose scheme is not allowed by this URLSanitizerConfig, and
+	// of a data URL that is not otherwise accepted. The URL is accepted if the
+	// hook returns true and the URL is well-formed for its scheme, as described
+	// in NewURLSanitizerConfig.
+	//
+	// The hook is never called for relative URLs, for URLs with allowed
+	// schemes, or for URLs whose scheme does not conform to the scheme grammar
+	// in RFC 3986 Section 3.1.
+	//
+	// WARNING: the hook is a security-critical decision point. Returning true
+	// for schemes such as javascript, vbscript or data allows URLs that
+	// execute arbitrary script when navigated to, defeating the purpose of URL
+	// sanitization. Hooks should return true only for explicitly reviewed
+	// schemes and return false for everything else, including on errors.
+	UnknownSchemeHook func(scheme string) bool
+
+	// MaxLength, if positive, is the maximum length in bytes of accepted URLs.
+	// Longer URLs are rejected before any other validation. A MaxLength of 0
+	// accepts URLs of any length.
+	MaxLength int
+
+	// SanitizeSVGDataURLs enables the sanitization of base64 data URLs with
+	// MIME type image/svg+xml, which are otherwise rejected because SVG
+	// documents can contain scripts. If set, such URLs are replaced with data
+	// URLs whose SVG document keeps only an allowlist of elements and
+	// attributes: scripts, event handlers, stylesheets, embedded content and
+	// references to resources outside the document are removed. URLs whose
+	// payload is not valid base64 or not a well-formed SVG document are
+	// rejected.
+	//
+	// This is intended for SVG documents generated by trusted code; the
+	// sanitized document may render differently from the original.
+	SanitizeSVGDataURLs bool
+
+	// StrictMailto enables strict validation of mailto URLs, which otherwise
+	// only need to start with "mailto:". If set, each header in the query of a
+	// mailto URL must be named in MailtoHeaders, and the recipients and header
+	// values must not contain percent-encoded ASCII control characters, such
+	// as %0D%0A, which could be used to inject further email headers if the
+	// URL is reflected in a mail client.
+	StrictMailto bool
+
+	// MailtoHeaders lists the names of the headers allowed in mailto URLs if
+	// StrictMailto is set, matched case-insensitively. If nil, the subject and
+	// body headers are allowed. Headers such as cc and bcc that add
+	// recipients must be listed explicitly.
+	MailtoHeaders []string
+
+	// NormalizeIDNHosts enables the conversion of internationalized domain
+	// names in the hosts of accepted URLs to their ASCII form, as browsers do
+	// when loading them, so that "http://bücher.example/" is returned as
+	// "http://xn--bcher-kva.example/". This applies to URLs with special
+	// schemes such as http and https, and to scheme-relative URLs. URLs whose
+	// host is not a valid internationalized domain name are rejected.
+	//
+	// Hosts containing non-ASCII characters are otherwise accepted unchanged,
+	// since they cannot cause script execution. Normalization makes equivalent
+	// hosts compare equal, but does not detect homographs such as a Cyrillic
+	// "а" in place of a Latin "a".
+	NormalizeIDNHosts bool
+
+	// RejectEmptyURLs causes the empty string to be rejected. It is otherwise
+	// accepted as a relative URL that refers to the current document, which
+	// may be undesirable for sinks such as the href of a link that should
+	// navigate elsewhere.
+	RejectEmptyURLs bool
+
+	// RejectSchemeRelativeURLs causes scheme-relative URLs such as
+	// "//example.com/path" to be rejected. They are otherwise accepted as
+	// relative URLs, but they may refer to any host, which is undesirable
+	// for sinks such as a "return to" link that should stay on the current
+	// host. As in browsers, leading spaces are ignored and '\' is treated
+	// like '/', so URLs such as " \\example.com" are rejected too.
+	// Absolute-path-relative URLs such as "/path" and path-relative URLs such
+	// as "path" are still accepted.
+	RejectSchemeRelativeURLs bool
+
+	// RevalidateDecodedURLs causes URLs to be rejected if they would be
+	// rejected after decoding one layer of percent-encoding, in addition to
+	// the URL itself being validated. Browsers do not decode URLs before
+	// determining their scheme, so "%6A%61%76%61%73%63%72%69%70%74%3Aalert(1)"
+	// is otherwise accepted as a harmless relative URL, but a sink that
+	// decodes URLs once before using them, such as a redirect handler that
+	// reads a URL from an encoded query parameter, would see a javascript URL.
+	//
+	// Valid escapes are decoded, and any other '%' is kept, so that sinks which
+	// decode leniently are also covered. Since the decoded URL is validated
+	// with the same options, a URL such as "/%2F%2Fexample.com" is rejected if
+	// RejectSchemeRelativeURLs is also set. Some safe URLs are rejected too,
+	// such as those with a percent-encoded ':' in their first path segment or
+	// a percent-encoded newline in their query.
+	RevalidateDecodedURLs bool
+}
+
+// defaultMailtoHeaders contains the headers allowed in mailto URLs by a
+// URLSanitizerConfig with StrictMailto set and MailtoHeaders nil.
+var defaultMailtoHeaders = []string{"subject", "body"}
+
+// defaultURLSchemes contains the schemes allowed by URLSanitized.
+var defaultURLSchemes = []string{"http", "https", "mailto", "ftp"}
+
+// defaultURLSanitizerConfig is the URLSanitizerConfig used by URLSanitized.
+var defaultURLSanitizerConfig = mustNewURLSanitizerConfig(defaultURLSchemes...)
+
+// schemePattern matches strings that conform to the RFC 3986 scheme grammar:
+//
+//	scheme = ALPHA *( ALPHA / DIGIT / "+" / "-" / "." )
+//
+// See https://tools.ietf.org/html/rfc3986#section-3.1.
+var schemePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*$`)
+
+// NewURLSanitizerConfig returns a URLSanitizerConfig that accepts absolute URLs
+// with any of the given schemes, in addition to relative URLs and data URLs
+// accepted by URLSanitized.
+//
+// Schemes are matched case-insensitively and must be given without the trailing
+// ':'. Duplicate schemes are ignored. It returns an error if any scheme does not
+// conform to the scheme grammar in RFC 3986 Section 3.1.
+//
+// Some schemes impose additional restrictions on the rest of the URL. For
+// example, tel URLs must contain a telephone number as specified by RFC 3966,
+// sms URLs must contain telephone numbers and a query as specified by RFC 5724,
+// blob URLs must contain an http, https or opaque origin followed by a UUID
+// as returned by URL.createObjectURL, ws and wss URLs must contain a host, and
+// file URLs must have an empty or localhost authority and an absolute path
+// that does not start with another slash or contain backslashes, so that they
+// cannot reference network shares such as file://attacker/share or
+// file:////attacker/share. android-app and ios-app deep links must contain a
+// valid Android package name or numeric App Store ID, optionally followed by a
+// scheme and a path, and must not contain colons after their scheme or
+// fragments. The URL is never normalized to fit these restrictions.
+//
+// WARNING: allowing the file scheme lets URLs reference arbitrary files on
+// the machine that renders them. Only allow it for content rendered in a
+// trusted desktop or embedded webview context, never for web pages, where
+// file URLs can be used to probe or exfiltrate local files.
+//
+// Note that NewURLSanitizerConfig does not include the schemes allowed by
+// URLSanitized by default; callers must supply them explicitly if needed.
+// Allowing schemes such as javascript or vbscript that cause script
+// execution when navigated to defeats the purpose of URL sanitization.
+func NewURLSanitizerConfig(schemes ...string) (*URLSanitizerConfig, error) {
+	lowered := make([]string, 0, len(schemes))
+	for _, scheme := range schemes {
+		if !schemePattern.MatchString(scheme) {
+			return nil, fmt.Errorf("scheme %q does not conform to the RFC 3986 scheme grammar", scheme)
+		}
+		if scheme = strings.ToLower(scheme); !containsString(lowered, scheme) {
+			lowered = append(lowered, scheme)
+		}
+	}
+	return &URLSanitizerConfig{
+		schemes:        lowered,
+		safeURLPattern: compileSafeURLPattern(lowered),
+	}, nil
+}
+
+// DefaultURLSanitizerConfig returns a new URLSanitizerConfig that accepts exactly
+// the URLs accepted by URLSanitized. Callers may modify the returned config
+// without affecting URLSanitized.
+func DefaultURLSanitizerConfig() *URLSanitizerConfig {
+	c := *defaultURLSanitizerConfig
+	return &c
+}
+
+// WithSchemes returns a new URLSanitizerConfig that accepts absolute URLs with
+// the schemes allowed by c and with any of the given schemes, and is otherwise
+// identical to c. c is not modified.
+//
+// It returns an error if any scheme does not conform to the scheme grammar in
+// RFC 3986 Section 3.1. See NewURLSanitizerConfig for more details.
+func (c *URLSanitizerConfig) WithSchemes(schemes ...string) (*URLSanitizerConfig, error) {
+	n, err := NewURLSanitizerConfig(append(c.Schemes(), schemes...)...)
+	if err != nil {
+		return nil, err
+	}
+	ret := *c
+	ret.schemes, ret.safeURLPattern = n.schemes, n.safeURLPattern
+	return &ret, nil
+}
+
+// WithInnocuousURL returns a new URLSanitizerConfig that returns a URL
+// containing url instead of InnocuousURL when passed an unsafe URL, and is
+// otherwise identical to c. c is not modified. This makes it possible to use
+// a marker such as "about:invalid#blocked-by-policy" that is easy to find in
+// rendered pages.
+//
+// url must be an about:invalid URL, optionally followed by a fragment, or a
+// non-empty URL accepted by c without modification. Otherwise, WithInnocuousURL returns
+// an error. url is itself returned unchanged by the Sanitize methods of the
+// new config, like InnocuousURL.
+func (c *URLSanitizerConfig) WithInnocuousURL(url string) (*URLSanitizerConfig, error) {
+	if url == "" {
+		return nil, fmt.Errorf("innocuous URL must not be empty")
+	}
+	if !aboutInvalidURLPattern.MatchString(url) && !c.isSafeURL(url) {
+		return nil, fmt.Errorf("innocuous URL %q is not an about:invalid URL or a URL accepted by the config", url)
+	}
+	ret := *c
+	ret.innocuousURL = url
+	return &ret, nil
+}
+
+// aboutInvalidURLPattern matches about:invalid URLs, which browsers never
+// navigate to, with an optional fragment containing only URL code points.
+var aboutInvalidURLPattern = regexp.MustCompile(`^about:invalid(?:#[A-Za-z0-9!$&'()*+,./:;=?@_~-]*)?$`)
+
+// innocuous returns the URL that c returns for unsafe URLs.
+func (c *URLSanitizerConfig) innocuous() URL {
+	if c.innocuousURL != "" {
+		return URL{c.innocuousURL}
+	}
+	return URL{InnocuousURL}
+}
+
+// isInnocuous reports whether url is InnocuousURL or the URL that c returns
+// for unsafe URLs.
+func (c *URLSanitizerConfig) isInnocuous(url string) bool {
+	return url == InnocuousURL || c.innocuousURL != "" && url == c.innocuousURL
+}
+
+// mustNewURLSanitizerConfig is like NewURLSanitizerConfig but panics on error.
+func mustNewURLSanitizerConfig(schemes ...string) *URLSanitizerConfig {
+	c, err := NewURLSanitizerConfig(schemes...)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// compileSafeURLPattern returns a pattern that matches URLs that
+//
+//	(a) Start with one of the given schemes, which must already have been
+//	    validated against schemePattern; or
+//	(b) Contain no scheme. To ensure that the URL cannot be interpreted as a
+//	    disallowed scheme URL, ':' may only appear after one of the runes [/?#].
+//
+// The origin (RFC 6454) in which a URL is loaded depends on
+// its scheme.  We assume that the scheme used by the current document is HTTPS, HTTP, or
+// something equivalent.  We allow relative URLs unless in a particularly sensitive context
+// called a "TrustedResourceUrl" context. In a non-TrustedResourceURL context we allow absolute
+// URLs whose scheme is on a white-list.
+//
+// The position of the first colon (':') character determines whether a URL is absolute or relative.
+// Looking at the prefix leading up to the first colon allows us to identify relative and absolute URLs,
+// extract the scheme, and minimize the risk of a user-agent concluding a URL specifies a scheme not in
+// our allowlist.
+//
+// According to RFC 3986 Section 3, the normative interpretation of the canonicial WHATWG specification
+// (https://url.spec.whatwg.org/#url-scheme-string), colons can appear in a URL in these locations:
+//   - A colon after a non-empty run of (ALPHA *( ALPHA / DIGIT / "+" / "-" / "." )) ends a scheme.
+//     If the colon after the scheme is not followed by "//" then any subsequent colons are part
+//     of an opaque URI body.
+//   - Otherwise, a colon after a hash (#) must be in the fragment.
+//   - Otherwise, a colon after a (?) must be in the query.
+//   - Otherwise, a colon after a single solidus ("/") must be in the path.
+//   - Otherwise, a colon after a double solidus ("//") must be in the authority (before port).
+//   - Otherwise, a colon after a valid protocol must be in the opaque part of the URL.
+func compileSafeURLPattern(schemes []string) *regexp.Regexp {
+	if len(schemes) == 0 {
+		// An empty alternation would match URLs starting with ':', so only
+		// match URLs without a scheme.
+		return regexp.MustCompile(`^[^:/?#]*(?:[/?#]|$)`)
+	}
+	quoted := make([]string, 0, len(schemes))
+	for _, scheme := range schemes {
+		quoted = append(quoted, regexp.QuoteMeta(scheme))
+	}
+	return regexp.MustCompile(`^(?:(?:` + strings.Join(quoted, "|") + `):|[^:/?#]*(?:[/?#]|$))`)
+}
+
+// Schemes returns the lowercase schemes allowed by c in absolute URLs.
+func (c *URLSanitizerConfig) Schemes() []string {
+	return append([]string(nil), c.schemes...)
+}
+
+// Sanitize returns a URL whose value is url, validating that the input string
+// is a relative URL, an absolute URL with a scheme allowed by c, a data URL
+// accepted by URLSanitized, or InnocuousURL. If url fails validation, this method returns a URL
+// containing InnocuousURL, or the URL set with WithInnocuousURL.
+//
+// Like URLSanitized, Sanitize is idempotent. See URLSanitized for more details.
+func (c *URLSanitizerConfig) Sanitize(url string) URL {
+	u, _ := c.SanitizeOrError(url)
+	return u
+}
+
+// SanitizeOrError is like Sanitize, but also returns an *UnsafeURLError
+// describing why url failed validation. If url fails validation, the returned
+// URL contains InnocuousURL.
+func (c *URLSanitizerConfig) SanitizeOrError(url string) (URL, error) {
+	if c.isInnocuous(url) {
+		return URL{url}, nil
+	}
+	if err := c.validate(url); err != nil {
+		if svg, ok := c.sanitizeSVGDataURL(err); ok {
+			return URL{svg}, nil
+		}
+		reportUnsafeURL(url)
+		return c.innocuous(), err
+	}
+	if c.NormalizeIDNHosts {
+		normalized, ok := normalizeIDNHost(url)
+		if !ok {
+			reportUnsafeURL(url)
+			return c.innocuous(), &UnsafeURLError{URL: url, Scheme: urlScheme(strings.ToLower(url)), Reason: UnsafeURLInvalidHost}
+		}
+		return URL{normalized}, nil
+	}
+	return URL{url}, nil
+}
+
+// A MediaFamily is the top-level type of a MIME type, such as "image" in
+// "image/png". It restricts the data URLs accepted for a sink that loads a
+// particular kind of media.
+type MediaFamily string
+
+// MediaFamily values accepted by SanitizeForMedia.
+const (
+	MediaFamilyAudio MediaFamily = "audio"
+	MediaFamilyFont  MediaFamily = "font"
+	MediaFamilyImage MediaFamily = "image"
+	MediaFamilyVideo MediaFamily = "video"
+)
+
+// SanitizeForMedia is like Sanitize, but also returns a URL containing
+// InnocuousURL if the sanitized URL is a data URL whose MIME type is not in
+// the given media family. URLs with other schemes and relative URLs are
+// accepted as by Sanitize.
+//
+// For example, SanitizeForMedia(url, MediaFamilyImage) accepts
+// "data:image/png;base64,..." but not "data:video/mp4;base64,...", which
+// Sanitize accepts for any sink.
+func (c *URLSanitizerConfig) SanitizeForMedia(url string, family MediaFamily) URL {
+	u, _ := c.SanitizeForMediaOrError(url, family)
+	return u
+}
+
+// SanitizeForMediaOrError is like SanitizeForMedia, but also returns an
+// *UnsafeURLError describing why url failed validation. If url fails
+// validation, the returned URL contains InnocuousURL.
+func (c *URLSanitizerConfig) SanitizeForMediaOrError(url string, family MediaFamily) (URL, error) {
+	u, err := c.SanitizeOrError(url)
+	if err != nil {
+		return u, err
+	}
+	if u.Scheme() != "data" {
+		return u, nil
+	}
+	// Data URLs accepted by UnknownSchemeHook need not match dataURLPattern,
+	// and are rejected since their MIME type cannot be determined reliably.
+	if mime, ok := dataURLMIMEType(u.str); !ok || !strings.HasPrefix(mime, string(family)+"/") {
+		reportUnsafeURL(url)
+		return c.innocuous(), &UnsafeURLError{URL: url, Scheme: "data", Reason: UnsafeURLDisallowedDataURL}
+	}
+	return u, nil
+}
+
+// SanitizeList is like Sanitize, but sanitizes each of urls. It returns the
+// sanitized URLs, in the same order as urls, and the indices in urls of the
+// inputs that failed validation, in increasing order. The returned URLs at
+// those indices contain InnocuousURL.
+func (c *URLSanitizerConfig) SanitizeList(urls []string) ([]URL, []int) {
+	ret := make([]URL, len(urls))
+	var rejected []int
+	for i, url := range urls {
+		u, err := c.SanitizeOrError(url)
+		if err != nil {
+			rejected = append(rejected, i)
+		}
+		ret[i] = u
+	}
+	return ret, rejected
+}
+
+// SanitizeToHosts is like Sanitize, but also returns a URL containing
+// InnocuousURL unless the sanitized URL is relative to the current host or
+// refers to one of allowedHosts. See URLSanitizedToHosts for details.
+func (c *URLSanitizerConfig) SanitizeToHosts(url string, allowedHosts []string) URL {
+	u := c.Sanitize(url)
+	if c.isInnocuous(u.str) {
+		return c.innocuous()
+	}
+	if !hasAllowedHost(u.str, allowedHosts) {
+		reportUnsafeURL(url)
+		return c.innocuous()
+	}
+	return u
+}
+
+// specialSchemes contains the schemes in which browsers treat '\' like '/' and
+// ignore any number of slashes before the authority.
+// See https://url.spec.whatwg.org/#special-scheme.
+var specialSchemes = map[string]bool{"ftp": true, "file": true, "http": true, "https": true, "ws": true, "wss": true}
+
+// hasAllowedHost reports whether url, which must be a safe URL, does not
+// refer to a host or refers to a host in allowedHosts, as described in
+// URLSanitizedToHosts. It errs on the side of reporting false for URLs that
+// browsers may parse differently.
+func hasAllowedHost(url string, allowedHosts []string) bool {
+	scheme, authority, ok := urlAuthority(url)
+	if !ok {
+		// Path-, absolute-path-, query- or fragment-relative URLs are
+		// allowed, but URLs such as mailto URLs that have no authority are not.
+		return scheme == ""
+	}
+	if i := strings.LastIndexByte(authority, '@'); i != -1 {
+		authority = authority[i+1:]
+	}
+	host, port, ok := splitHostPort(authority)
+	if !ok || host == "" {
+		return false
+	}
+	if port == safehtmlutil.DefaultPort(scheme) {
+		port = ""
+	}
+	for _, allowed := range allowedHosts {
+		if allowedHost, allowedPort, ok := splitHostPort(allowed); ok && allowedHost == host && allowedPort == port {
+			return true
+		}
+	}
+	return false
+}
+
+// urlAuthority returns the lowercase scheme of url and its authority, which may
+// contain userinfo and a port, as browsers parse them. It reports false if url
+// has no authority.
+//
+// Browsers remove tabs and newlines from URLs and strip leading spaces from
+// them, and in relative URLs and URLs with special schemes, treat '\' like '/'
+// and ignore any number of slashes before the authority.
+func urlAuthority(url string) (scheme, authority string, ok bool) {
+	url = strings.NewReplacer("\t", "", "\n", "", "\r", "").Replace(url)
+	url = strings.TrimLeft(url, " ")
+	scheme, _ = asciiToLower(urlScheme(url))
+	var rest string
+	switch {
+	case scheme == "":
+		rest = url
+		if len(rest) < 2 || !safehtmlutil.IsURLSlash(rest[0]) || !safehtmlutil.IsURLSlash(rest[1]) {
+			return "", "", false
+		}
+	case specialSchemes[scheme]:
+		rest = url[len(scheme)+len(":"):]
+	default:
+		rest = url[len(scheme)+len(":"):]
+		if !strings.HasPrefix(rest, "//") {
+			return scheme, "", false
+		}
+		authority = rest[len("//"):]
+		if i := strings.IndexAny(authority, "/?#"); i != -1 {
+			authority = authority[:i]
+		}
+		return scheme, authority, true
+	}
+	authority = strings.TrimLeft(rest, `/\`)
+	if i := strings.IndexAny(authority, `/\?#`); i != -1 {
+		authority = authority[:i]
+	}
+	return scheme, authority, true
+}
+
+// splitHostPort returns the lowercase host and the port of authority, which
+// must not contain userinfo. It reports false if the host contains non-ASCII
+// bytes or the port is not a decimal number.
+func splitHostPort(authority string) (host, port string, ok bool) {
+	host = authority
+	if i := strings.LastIndexByte(authority, ':'); i != -1 && !strings.Contains(authority[i:], "]") {
+		host, port = authority[:i], authority[i+1:]
+		for j := 0; j < len(port); j++ {
+			if port[j] < '0' || port[j] > '9' {
+				return "", "", false
+			}
+		}
+		// Browsers ignore leading zeros in ports.
+		if port = strings.TrimLeft(port, "0"); port == "" && i+1 < len(authority) {
+			port = "0"
+		}
+	}
+	host, ok = asciiToLower(host)
+	return host, port, ok
+}
+
+// idnaProfile converts internationalized domain names to their ASCII form
+// like browsers, which unlike DNS lookups allow characters such as '_'.
+// See https://url.spec.whatwg.org/#concept-domain-to-ascii.
+var idnaProfile = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.StrictDomainName(false))
+
+// normalizeIDNHost returns url, which must be accepted by validate, with any
+// internationalized domain name in its host converted to its ASCII form by
+// idnaProfile, after decoding any percent-encoded bytes in the host. It reports
+// false if the host is not a valid internationalized domain name. URLs without a host, or with a scheme that is not special, are
+// returned unchanged.
+func normalizeIDNHost(url string) (string, bool) {
+	scheme, authority, ok := urlAuthority(url)
+	if !ok || scheme != "" && !specialSchemes[scheme] || isASCII(authority) && !strings.Contains(authority, "%") {
+		return url, true
+	}
+	// url contains no tabs or newlines, so the authority starts after any
+	// leading spaces, the scheme and the slashes that urlAuthority skips.
+	rest := strings.TrimLeft(url, " ")
+	if scheme != "" {
+		rest = rest[len(scheme)+len(":"):]
+	}
+	start := len(url) - len(strings.TrimLeft(rest, `/\`))
+	if i := strings.LastIndexByte(authority, '@'); i != -1 {
+		start += i + 1
+		authority = authority[i+1:]
+	}
+	host := authority
+	if i := strings.LastIndexByte(host, ':'); i != -1 {
+		host = host[:i]
+	}
+	decoded, ok := percentDecode(host)
+	if !ok {
+		return "", false
+	}
+	if isASCII(decoded) {
+		return url, true
+	}
+	ascii, err := idnaProfile.ToASCII(decoded)
+	if err != nil || ascii == "" || strings.IndexFunc(ascii, isForbiddenHostRune) != -1 {
+		return "", false
+	}
+	return url[:start] + ascii + url[start+len(host):], true
+}
+
+// isASCII reports whether s contains only ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// isSafeURL reports whether url is accepted by c without modification.
+func (c *URLSanitizerConfig) isSafeURL(url string) bool {
+	if c.isInnocuous(url) {
+		return true
+	}
+	err := c.validate(url)
+	if err == nil {
+		if c.NormalizeIDNHosts {
+			normalized, ok := normalizeIDNHost(url)
+			return ok && normalized == url
+		}
+		return true
+	}
+	svg, ok := c.sanitizeSVGDataURL(err)
+	return ok && svg == url
+}
+
+// sanitizeSVGDataURL returns the sanitized form of the URL rejected with err
+// if c.SanitizeSVGDataURLs is set and err.URL is a valid SVG data URL.
+// Otherwise, it returns false, updating err.Reason if the URL is an SVG data
+// URL that could not be sanitized.
+func (c *URLSanitizerConfig) sanitizeSVGDataURL(err *UnsafeURLError) (string, bool) {
+	if !c.SanitizeSVGDataURLs || err.Reason != UnsafeURLDisallowedDataURL {
+		return "", false
+	}
+	if mime, ok := dataURLMIMEType(err.URL); !ok || mime != svgMIMEType {
+		return "", false
+	}
+	svg, sanitizeErr := sanitizeBase64SVG(err.URL[strings.IndexByte(err.URL, ',')+1:])
+	if sanitizeErr != nil {
+		err.Reason = UnsafeURLInvalidSVG
+		return "", false
+	}
+	return svg, true
+}
+
+// validate matches url to a subset of URLs that will not cause script execution if used in
+// a URL context within a HTML document. Specifically, this method returns nil if url:
+//
+//	(a) Starts with a scheme allowed by c or accepted by c.UnknownSchemeHook,
+//	    and is well-formed for that scheme; or
+//	(b) Contains no scheme. To ensure that the URL cannot be interpreted as a
+//	    disallowed scheme URL, the rune ':' may only appear after one of the
+//	    runes [/?#]; or
+//	(c) Is a base64 data URL with an allowed audio, image or video MIME type, or
+//	    a font MIME type if c.AllowFontDataURLs is set.
+//
+// In all cases, url must not contain ASCII control characters, including TAB,
+// LF and CR, must not be longer than c.MaxLength, if set, must not be empty
+// if c.RejectEmptyURLs is set, and must not be scheme-relative if
+// c.RejectSchemeRelativeURLs is set. If c.RevalidateDecodedURLs is set, url
+// must also satisfy these conditions after one layer of percent-decoding.
+// Otherwise, it returns an error describing why url is unsafe.
+func (c *URLSanitizerConfig) validate(url string) *UnsafeURLError {
+	if err := c.validateOnce(url); err != nil {
+		return err
+	}
+	if c.RevalidateDecodedURLs && strings.IndexByte(url, '%') != -1 {
+		if err := c.validateOnce(percentDecodeValidEscapes(url)); err != nil {
+			return &UnsafeURLError{URL: url, Scheme: err.Scheme, Reason: UnsafeURLUnsafeWhenDecoded}
+		}
+	}
+	return nil
+}
+
+// validateOnce is like validate, but ignores c.RevalidateDecodedURLs.
+func (c *URLSanitizerConfig) validateOnce(url string) *UnsafeURLError {
+	if url == "" && c.RejectEmptyURLs {
+		return &UnsafeURLError{URL: url, Reason: UnsafeURLEmpty}
+	}
+	if c.MaxLength > 0 && len(url) > c.MaxLength {
+		// Do not report the scheme, since finding it may require scanning
+		// the entire URL.
+		return &UnsafeURLError{URL: url, Reason: UnsafeURLTooLong}
+	}
+	if containsASCIIControl(url) {
+		// Browsers strip TAB, LF and CR from URLs before parsing them, and
+		// treat other control characters inconsistently, so a URL such as
+		// "java\tscript:alert(1)" may be interpreted as having a scheme that
+		// differs from the one seen here. Rather than emulating browsers,
+		// reject such URLs outright.
+		return &UnsafeURLError{URL: url, Scheme: urlScheme(strings.ToLower(url)), Reason: UnsafeURLControlCharacter}
+	}
+	if c.RejectSchemeRelativeURLs {
+		if scheme, _, ok := urlAuthority(url); ok && scheme == "" {
+			return &UnsafeURLError{URL: url, Reason: UnsafeURLSchemeRelative}
+		}
+	}
+	// Fast path for the common cases, which avoids lowercasing url and
+	// matching it against regular expressions. It must classify URLs exactly
+	// as validatePatterns does.
+	i := strings.IndexAny(url, ":/?#")
+	if i == -1 || url[i] != ':' {
+		// A relative URL, as in case (b).
+		return nil
+	}
+	if scheme, ok := asciiToLower(url[:i]); ok && scheme != "data" {
+		if _, ok := schemeSpecificPatterns[scheme]; !ok {
+			if c.allowsScheme(scheme) {
+				return c.validateMailto(url, scheme)
+			}
+			return c.validateUnknownScheme(url, scheme, UnsafeURLDisallowedScheme)
+		}
+	}
+	return c.validatePatterns(url)
+}
+
+// validatePatterns is like validate, but matches url only against regular
+// expressions. It assumes that url contains no ASCII control characters.
+func (c *URLSanitizerConfig) validatePatterns(url string) *UnsafeURLError {
+	// Ignore case when matching schemes.
+	lower := strings.ToLower(url)
+	if c.safeURLPattern.MatchString(lower) {
+		scheme := urlScheme(lower)
+		if p, ok := schemeSpecificPatterns[scheme]; ok && !p.MatchString(lower) {
+			return &UnsafeURLError{URL: url, Scheme: scheme, Reason: UnsafeURLMalformed}
+		}
+		return c.validateMailto(url, scheme)
+	}
+	// Since url did not match safeURLPattern, it must contain a ':' that
+	// precedes any of the runes [/?#].
+	scheme := lower[:strings.IndexByte(lower, ':')]
+	if scheme != "data" {
+		return c.validateUnknownScheme(url, scheme, UnsafeURLDisallowedScheme)
+	}
+	// Match the original URL rather than its lowercase form, since base64 data is case-sensitive.
+	if mime, ok := dataURLMIMEType(url); !ok || !c.isSafeDataURLMIMEType(mime) {
+		return c.validateUnknownScheme(url, scheme, UnsafeURLDisallowedDataURL)
+	}
+	return nil
+}
+
+// validateUnknownScheme validates url, which has the given lowercase scheme
+// and was rejected by c for the given reason, using c.UnknownSchemeHook.
+func (c *URLSanitizerConfig) validateUnknownScheme(url, scheme string, reason UnsafeURLReason) *UnsafeURLError {
+	if c.UnknownSchemeHook == nil || !schemePattern.MatchString(scheme) || !c.UnknownSchemeHook(scheme) {
+		return &UnsafeURLError{URL: url, Scheme: scheme, Reason: reason}
+	}
+	if p, ok := schemeSpecificPatterns[scheme]; ok && !p.MatchString(strings.ToLower(url)) {
+		return &UnsafeURLError{URL: url, Scheme: scheme, Reason: UnsafeURLMalformed}
+	}
+	return c.validateMailto(url, scheme)
+}
+
+// validateMailto validates url, which has the given lowercase scheme and is
+// otherwise accepted by c, if it is a mailto URL and c.StrictMailto is set.
+func (c *URLSanitizerConfig) validateMailto(url, scheme string) *UnsafeURLError {
+	if scheme != "mailto" || !c.StrictMailto {
+		return nil
+	}
+	rest := url[len("mailto:"):]
+	if i := strings.IndexByte(rest, '#'); i != -1 {
+		rest = rest[:i]
+	}
+	to, query := rest, ""
+	if i := strings.IndexByte(rest, '?'); i != -1 {
+		to, query = rest[:i], rest[i+1:]
+	}
+	if !isPercentDecodedWithoutControl(to) {
+		return &UnsafeURLError{URL: url, Scheme: scheme, Reason: UnsafeURLMalformed}
+	}
+	if query == "" {
+		return nil
+	}
+	allowed := c.MailtoHeaders
+	if allowed == nil {
+		allowed = defaultMailtoHeaders
+	}
+	for _, hfield := range strings.Split(query, "&") {
+		name, value := hfield, ""
+		if i := strings.IndexByte(hfield, '='); i != -1 {
+			name, value = hfield[:i], hfield[i+1:]
+		}
+		decoded, err := neturl.PathUnescape(name)
+		if err != nil || !containsFold(allowed, decoded) {
+			return &UnsafeURLError{URL: url, Scheme: scheme, Reason: UnsafeURLDisallowedMailtoHeader}
+		}
+		if !isPercentDecodedWithoutControl(value) {
+			return &UnsafeURLError{URL: url, Scheme: scheme, Reason: UnsafeURLMalformed}
+		}
+	}
+	return nil
+}
+
+// isPercentDecodedWithoutControl reports whether s is validly percent-encoded
+// and contains no ASCII control characters after percent-decoding.
+func isPercentDecodedWithoutControl(s string) bool {
+	decoded, err := neturl.PathUnescape(s)
+	return err == nil && !containsASCIIControl(decoded)
+}
+
+// containsFold reports whether s is in strs, ignoring case.
+func containsFold(strs []string, s string) bool {
+	for _, str := range strs {
+		if strings.EqualFold(str, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSafeDataURLMIMEType reports whether a data URL with the given lowercase
+// MIME type is accepted by c.
+func (c *URLSanitizerConfig) isSafeDataURLMIMEType(mimeType string) bool {
+	return safeMIMETypePattern.MatchString(mimeType) ||
+		c.AllowFontDataURLs && fontMIMETypePattern.MatchString(mimeType) ||
+		containsString(c.dataMIMETypes, mimeType)
+}
+
+// WithDataMIMETypes returns a new URLSanitizerConfig that accepts base64 data
+// URLs with any of the given MIME types, such as "image/avif", in addition to
+// the URLs accepted by c, and is otherwise identical to c. c is not modified.
+// The MIME types are matched case-insensitively and must not have parameters.
+//
+// It returns an error if a MIME type is not of the form type/subtype, where
+// type is audio, font, image or video and subtype contains only ASCII letters,
+// digits and the runes [.+_-], or if it is an SVG or XML type, since such
+// documents can contain scripts. See SanitizeSVGDataURLs for SVG images.
+func (c *URLSanitizerConfig) WithDataMIMETypes(mimeTypes ...string) (*URLSanitizerConfig, error) {
+	dataMIMETypes := append([]string(nil), c.dataMIMETypes...)
+	for _, mimeType := range mimeTypes {
+		lower := strings.ToLower(mimeType)
+		if !dataMIMETypeGrammarPattern.MatchString(lower) {
+			return nil, fmt.Errorf("MIME type %q is not an audio, font, image or video type of the form type/subtype", mimeType)
+		}
+		if strings.Contains(lower, "svg") || strings.Contains(lower, "xml") {
+			return nil, fmt.Errorf("MIME type %q is an SVG or XML type, which can contain scripts", mimeType)
+		}
+		if !containsString(dataMIMETypes, lower) {
+			dataMIMETypes = append(dataMIMETypes, lower)
+		}
+	}
+	ret := *c
+	ret.dataMIMETypes = dataMIMETypes
+	return &ret, nil
+}
+
+// dataMIMETypeGrammarPattern matches lowercase MIME types that may be added
+// with WithDataMIMETypes. The subtype is a subset of the restricted-name
+// grammar of RFC 6838 Section 4.2, which excludes runes such as '#' and '&'
+// that have a meaning in URLs.
+var dataMIMETypeGrammarPattern = regexp.MustCompile(`^(?:audio|font|image|video)/[a-z0-9][a-z0-9.+_-]{0,126}$`)
+
+// fontMIMETypePattern matches font MIME types that are safe to include in a data URL
+// if AllowFontDataURLs is set.
+var fontMIMETypePattern = regexp.MustCompile(`^(?:font/(?:woff2|woff|ttf|otf)|application/font-woff)$`)
+
+// allowsScheme reports whether c allows absolute URLs with the given lowercase scheme.
+func (c *URLSanitizerConfig) allowsScheme(scheme string) bool {
+	return containsString(c.schemes, scheme)
+}
+
+// containsString reports whether s is in strs.
+func containsString(strs []string, s string) bool {
+	for _, str := range strs {
+		if str == s {
+			return true
+		}
+	}
+	return false
+}
+
+// asciiToLower returns s with ASCII uppercase letters mapped to lowercase,
+// without allocating if s is already lowercase. It reports false if s contains
+// non-ASCII bytes, whose case mapping is left to strings.ToLower.
+func asciiToLower(s string) (string, bool) {
+	hasUpper := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 0x80 {
+			return "", false
+		}
+		hasUpper = hasUpper || 'A' <= c && c <= 'Z'
+	}
+	if !hasUpper {
+		return s, true
+	}
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b), true
+}
+
+// percentDecodeValidEscapes returns s with each percent-encoded byte, that is,
+// each '%' followed by two hexadecimal digits, decoded. Any other '%' is kept.
+func percentDecodeValidEscapes(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			b.WriteByte(unhex(s[i+1])<<4 | unhex(s[i+2]))
+			i += 2
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// containsASCIIControl reports whether s contains an ASCII control character,
+// that is, a byte in the range 0x00-0x1F or the byte 0x7F.
+func containsASCIIControl(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] == 0x7F {
+			return true
+		}
+	}
+	return false
+}
+
+// urlScheme returns the scheme of url, or the empty string if url has no scheme.
+//
+// As in compileSafeURLPattern, url has a scheme only if a ':' occurs before any
+// of the runes [/?#].
+func urlScheme(url string) string {
+	if i := strings.IndexAny(url, ":/?#"); i != -1 && url[i] == ':' {
+		return url[:i]
+	}
+	return ""
+}
+
+// websocketURLPattern returns a pattern that matches lowercase ws or wss URLs
+// with the given scheme.
+func websocketURLPattern(scheme string) *regexp.Regexp {
+	return regexp.MustCompile(`^` + scheme + `://[^/?#\\]+(?:[/?#].*)?$`)
+}
+
+// schemeSpecificPatterns[x] matches lowercase URLs with scheme x that are
+// well-formed for that scheme. URLs whose scheme is allowed by a
+// URLSanitizerConfig must also match the corresponding pattern, if any.
+var schemeSpecificPatterns = map[string]*regexp.Regexp{
+	// tel URLs contain a telephone number optionally followed by parameters.
+	// See https://tools.ietf.org/html/rfc3966#section-3.
+	"tel": regexp.MustCompile(`^tel:` + telephoneNumberPattern + `(?:;[a-z0-9-]+(?:=(?:[a-z0-9+().-]|%[0-9a-f]{2})+)?)*$`),
+	// sms URLs contain zero or more comma-separated telephone numbers optionally
+	// followed by a query containing the message body.
+	// See https://tools.ietf.org/html/rfc5724#section-2.2.
+	"sms": regexp.MustCompile(`^sms:(?:` + telephoneNumberPattern + `(?:,` + telephoneNumberPattern + `)*)?(?:\?[a-z]+=` + smsQueryValuePattern + `(?:&[a-z]+=` + smsQueryValuePattern + `)*)?$`),
+	// blob URLs contain the origin of the document that created the blob,
+	// which is either a tuple origin with an http or https scheme or the opaque
+	// origin "null", followed by a UUID and an optional fragment. Since the
+	// only scheme that can follow "blob:" is http or https, the rest of the
+	// URL cannot be interpreted as a URL with another scheme.
+	// See https://w3c.github.io/FileAPI/#url.
+	"blob": regexp.MustCompile(`^blob:(?:https?://(?:[a-z0-9.-]+|\[[0-9a-f:.]+\])(?::[0-9]+)?|null)/[0-9a-f-]+(?:#.*)?$`),
+	// ws and wss URLs must be hierarchical URLs with a non-empty authority, just
+	// like the http and https URLs they are upgraded from. This rejects opaque
+	// URLs such as "wss:%0ajavascript:alert(1)", which browsers do not connect to.
+	// See https://websockets.spec.whatwg.org/#websocket-server-url.
+	"ws":  websocketURLPattern("ws"),
+	"wss": websocketURLPattern("wss"),
+	// file URLs must have an empty or localhost host, since other hosts are
+	// resolved as UNC paths to network shares on Windows. For the same reason,
+	// the path must not start with a second slash, and must not contain
+	// backslashes, which browsers treat as slashes, or their percent-encoded
+	// forms. The required "//" also ensures that no other scheme can follow
+	// "file:".
+	// See https://tools.ietf.org/html/rfc8089#section-2.
+	"file": regexp.MustCompile(`^file://(?:localhost)?/(?:` + filePathStartPattern + filePathPattern + `*)?$`),
+	// android-app and ios-app URLs are deep links that open an app, identified
+	// by its Android package name or its numeric App Store ID, optionally
+	// passing it a scheme, host and path as in
+	// "android-app://com.example/https/example.com/path" that the app handles
+	// itself. They must not contain fragments, which Android parses as intent
+	// parameters that may select another component, or colons after the
+	// scheme, so that no other scheme can appear in them.
+	// See https://developer.android.com/reference/android/content/Intent#parseUri.
+	"android-app": regexp.MustCompile(`^android-app://[a-z][a-z0-9_]*(?:\.[a-z][a-z0-9_]*)+` + appDeepLinkPathPattern + `$`),
+	"ios-app":     regexp.MustCompile(`^ios-app://[0-9]+` + appDeepLinkPathPattern + `$`),
+}
+
+const (
+	// telephoneNumberPattern matches a global or local telephone number with
+	// optional visual separators. It does not match '#', which must be
+	// percent-encoded in URLs.
+	telephoneNumberPattern = `\+?(?:[0-9*().-]|%[0-9a-f]{2})+`
+	// appDeepLinkPathPattern matches the optional scheme, host and path, and
+	// query, following the app of an android-app or ios-app URL. It does not
+	// match ':', '\' or '#'.
+	appDeepLinkPathPattern = `(?:/[a-z][a-z0-9+.-]*(?:/[^:?#\\]*)?)?(?:\?[^:#\\]*)?`
+	// smsQueryValuePattern matches a percent-encoded sms query value.
+	smsQueryValuePattern = `(?:[a-z0-9!$'()*+,./:;=@_~-]|%[0-9a-f]{2})*`
+	// filePathStartPattern matches the first rune of a file URL path after
+	// the leading slash, which must not be a slash, backslash, or %2f or %5c.
+	filePathStartPattern = `(?:[^/\\%]|%(?:[013-46-9a-f][0-9a-f]|2[0-9a-e]|5[0-9abd-f]))`
+	// filePathPattern matches a rune in the rest of a file URL, other than a
+	// backslash or %5c.
+	filePathPattern = `(?:[^\\%]|%(?:[0-46-9a-f][0-9a-f]|5[0-9abd-f]))`
+)
+
+// An UnsafeURLError describes why a URL failed validation.
+type UnsafeURLError struct {
+	// URL is the URL that failed validation.
+	URL string
+	// Scheme is the lowercase text preceding the first ':' in URL that
+	// determines the scheme of URL, or the empty string if URL has no scheme.
+	Scheme string
+	// Reason describes the kind of validation failure.
+	Reason UnsafeURLReason
+}
+
+// Error returns a description of e.
+func (e *UnsafeURLError) Error() string {
+	if e.Reason == UnsafeURLTooLong && len(e.URL) > maxErrorURLLength {
+		// Avoid copying URLs that were rejected for their size into logs.
+		return fmt.Sprintf("unsafe URL %q... (%d bytes): %s", e.URL[:maxErrorURLLength], len(e.URL), e.Reason)
+	}
+	return fmt.Sprintf("unsafe URL %q: %s", e.URL, e.Reason)
+}
+
+// maxErrorURLLength is the maximum length of the prefix of a URL included in
+// the message of an UnsafeURLError with reason UnsafeURLTooLong.
+const maxErrorURLLength = 64
+
+// UnsafeURLReason is a code for a kind of URL validation failure.
+type UnsafeURLReason int
+
+const (
+	// UnsafeURLDisallowedScheme indicates that the URL has a scheme that is
+	// not allowed.
+	UnsafeURLDisallowedScheme UnsafeURLReason = iota + 1
+	// UnsafeURLDisallowedDataURL indicates that the URL is a data URL that is
+	// not base64-encoded or does not have an allowed MIME type.
+	UnsafeURLDisallowedDataURL
+	// UnsafeURLMalformed indicates that the URL has an allowed scheme, but
+	// is not well-formed for that scheme.
+	UnsafeURLMalformed
+	// UnsafeURLControlCharacter indicates that the URL contains an ASCII
+	// control character, such as TAB, LF or CR.
+	UnsafeURLControlCharacter
+	// UnsafeURLTooLong indicates that the URL is longer than the MaxLength of
+	// the URLSanitizerConfig.
+	UnsafeURLTooLong
+	// UnsafeURLInvalidSVG indicates that the URL is an SVG data URL whose
+	// payload could not be decoded or sanitized, when SanitizeSVGDataURLs is
+	// set.
+	UnsafeURLInvalidSVG
+	// UnsafeURLDisallowedMailtoHeader indicates that the URL is a mailto URL
+	// with a header that is not allowed, when StrictMailto is set.
+	UnsafeURLDisallowedMailtoHeader
+	// UnsafeURLInvalidHost indicates that the host of the URL is not a valid
+	// internationalized domain name, when NormalizeIDNHosts is set.
+	UnsafeURLInvalidHost
+	// UnsafeURLEmpty indicates that the URL is empty, when RejectEmptyURLs is
+	// set.
+	UnsafeURLEmpty
+	// UnsafeURLSchemeRelative indicates that the URL is scheme-relative, when
+	// RejectSchemeRelativeURLs is set.
+	UnsafeURLSchemeRelative
+	// UnsafeURLUnsafeWhenDecoded indicates that the URL would be rejected
+	// after one layer of percent-decoding, when RevalidateDecodedURLs is set.
+	UnsafeURLUnsafeWhenDecoded
+)
+
+// String returns a human-readable description of r.
+func (r UnsafeURLReason) String() string {
+	switch r {
+	case UnsafeURLDisallowedScheme:
+		return "disallowed scheme"
+	case UnsafeURLDisallowedDataURL:
+		return "disallowed data URL"
+	case UnsafeURLMalformed:
+		return "malformed URL for its scheme"
+	case UnsafeURLControlCharacter:
+		return "contains control character"
+	case UnsafeURLTooLong:
+		return "too long"
+	case UnsafeURLInvalidSVG:
+		return "invalid SVG data URL"
+	case UnsafeURLDisallowedMailtoHeader:
+		return "disallowed mailto header"
+	case UnsafeURLInvalidHost:
+		return "invalid internationalized host"
+	case UnsafeURLEmpty:
+		return "empty URL"
+	case UnsafeURLSchemeRelative:
+		return "scheme-relative URL"
+	case UnsafeURLUnsafeWhenDecoded:
+		return "unsafe after percent-decoding"
+	}
+	return fmt.Sprintf("UnsafeURLReason(%d)", int(r))
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2017 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package safehtml
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// A URLScanner reads newline-delimited URLs from an io.Reader and sanitizes
+// them one at a time, so that large lists of URLs need not be held in memory.
+//
+// Lines may end in "\n" or "\r\n". Leading and trailing spaces and tabs are
+// removed from each line, and lines that are then empty are skipped.
+//
+// A URLScanner must be constructed using NewURLScanner or
+// URLSanitizerConfig.NewURLScanner.
+type URLScanner struct {
+	s        *bufio.Scanner
+	c        *URLSanitizerConfig
+	url      URL
+	line     int
+	rejected bool
+}
+
+// NewURLScanner returns a URLScanner that reads URLs from r and sanitizes them
+// as by URLSanitized.
+func NewURLScanner(r io.Reader) *URLScanner {
+	return defaultURLSanitizerConfig.NewURLScanner(r)
+}
+
+// NewURLScanner returns a URLScanner that reads URLs from r and sanitizes them
+// as by c.Sanitize.
+func (c *URLSanitizerConfig) NewURLScanner(r io.Reader) *URLScanner {
+	return &URLScanner{s: bufio.NewScanner(r), c: c}
+}
+
+// Buffer sets the initial buffer to use when reading lines and the maximum
+// length of a line, as for bufio.Scanner.Buffer. By default, lines longer than
+// bufio.MaxScanTokenSize cause Scan to fail with bufio.ErrTooLong. Buffer
+// panics if it is called after scanning has started.
+func (s *URLScanner) Buffer(buf []byte, max int) {
+	s.s.Buffer(buf, max)
+}
+
+// Scan advances s to the next non-blank line, which is then available through
+// the URL method. It returns false when there are no more lines, either by
+// reaching the end of the input or because of an error, which is then returned
+// by Err.
+func (s *URLScanner) Scan() bool {
+	for s.s.Scan() {
+		s.line++
+		text := strings.Trim(s.s.Text(), " \t")
+		if text == "" {
+			continue
+		}
+		var err error
+		s.url, err = s.c.SanitizeOrError(text)
+		s.rejected = err != nil
+		return true
+	}
+	s.url, s.rejected = URL{}, false
+	return false
+}
+
+// URL returns the sanitized URL read by the most recent call to Scan. It
+// contains InnocuousURL, or the URL set with URLSanitizerConfig.WithInnocuousURL,
+// if the line failed validation.
+func (s *URLScanner) URL() URL {
+	return s.url
+}
+
+// Rejected reports whether the line read by the most recent call to Scan
+// failed validation and was replaced by an innocuous URL.
+func (s *URLScanner) Rejected() bool {
+	return s.rejected
+}
+
+// Line returns the 1-based number of the line read by the most recent call to
+// Scan, counting blank lines.
+func (s *URLScanner) Line() int {
+	return s.line
+}
+
+// Err returns the first error other than io.EOF encountered while reading the
+// input. Lines that fail validation are not errors; see Rejected.
+func (s *URLScanner) Err() error {
+	return s.s.Err()
+}
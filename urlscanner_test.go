@@ -0,0 +1,119 @@
+// Copyright (c) 2017 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package safehtml
+
+import (
+	"bufio"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+type scannedURL struct {
+	line     int
+	url      string
+	rejected bool
+}
+
+func scanAll(s *URLScanner) []scannedURL {
+	var ret []scannedURL
+	for s.Scan() {
+		ret = append(ret, scannedURL{s.Line(), s.URL().String(), s.Rejected()})
+	}
+	return ret
+}
+
+func TestURLScanner(t *testing.T) {
+	const input = "https://example.com/\n" +
+		"javascript:alert(1)\r\n" +
+		"\n" +
+		"\r\n" +
+		"  \t\n" +
+		"  /relative/path\t\r\n" +
+		"data:text/html;base64,PHNjcmlwdD4=\n" +
+		"mailto:a@example.com\r\n" +
+		"tel:+1-555-0100\n" +
+		"\tjava\tscript:alert(1)\n" +
+		InnocuousURL + "\n" +
+		"https://example.com/last"
+	want := []scannedURL{
+		{1, "https://example.com/", false},
+		{2, InnocuousURL, true},
+		{6, "/relative/path", false},
+		{7, InnocuousURL, true},
+		{8, "mailto:a@example.com", false},
+		{9, InnocuousURL, true},
+		{10, InnocuousURL, true},
+		{11, InnocuousURL, false},
+		{12, "https://example.com/last", false},
+	}
+	for _, r := range [...]struct {
+		desc string
+		s    *URLScanner
+	}{
+		{"reader", NewURLScanner(strings.NewReader(input))},
+		// Lines split across reads, including between "\r" and "\n", are
+		// handled.
+		{"one byte reader", NewURLScanner(iotest.OneByteReader(strings.NewReader(input)))},
+	} {
+		if got := scanAll(r.s); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: scanned\n\t%v\nwant\n\t%v", r.desc, got, want)
+		}
+		if err := r.s.Err(); err != nil {
+			t.Errorf("%s: Err() = %v", r.desc, err)
+		}
+		if r.s.Scan() {
+			t.Errorf("%s: Scan() after end of input returned true", r.desc)
+		}
+	}
+
+	c, err := DefaultURLSanitizerConfig().WithSchemes("tel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := scanAll(c.NewURLScanner(strings.NewReader("tel:+1-555-0100\njavascript:alert(1)\n")))
+	if want := []scannedURL{{1, "tel:+1-555-0100", false}, {2, InnocuousURL, true}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("config: scanned\n\t%v\nwant\n\t%v", got, want)
+	}
+}
+
+func TestURLScannerErrors(t *testing.T) {
+	errRead := errors.New("read error")
+	s := NewURLScanner(&errReader{data: "https://example.com/\n/a\n", err: errRead})
+	if got, want := scanAll(s), []scannedURL{{1, "https://example.com/", false}, {2, "/a", false}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("scanned\n\t%v\nwant\n\t%v", got, want)
+	}
+	if err := s.Err(); err != errRead {
+		t.Errorf("Err() = %v, want %v", err, errRead)
+	}
+
+	s = NewURLScanner(strings.NewReader("/" + strings.Repeat("a", 100) + "\n"))
+	s.Buffer(make([]byte, 0, 16), 64)
+	if s.Scan() {
+		t.Errorf("Scan() of long line returned true")
+	}
+	if err := s.Err(); err != bufio.ErrTooLong {
+		t.Errorf("Err() = %v, want %v", err, bufio.ErrTooLong)
+	}
+}
+
+// errReader returns data, followed by err.
+type errReader struct {
+	data string
+	err  error
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
@@ -8,7 +8,10 @@ package safehtml
 
 import (
 	"bytes"
+	"fmt"
+	"regexp"
 	"strconv"
+	"strings"
 )
 
 // https://infra.spec.whatwg.org/#ascii-whitespace
@@ -50,7 +53,7 @@ func URLSetSanitized(str string) URLSet {
 		_, str = consumeIn(str, asciiWhitespace)
 
 		// Append sanitized content onto buffer.
-		if len(url) != 0 && isSafeURL(url) && isOptionalSrcMetadataWellFormed(metadata) {
+		if len(url) != 0 && IsSafeURL(url) && isOptionalSrcMetadataWellFormed(metadata) {
 			if buffer.Len() != 0 {
 				// The space before the comma is necessary because
 				// a comma adjacent to a URL will attach to it.
@@ -78,6 +81,92 @@ func URLSetSanitized(str string) URLSet {
 	return URLSet{buffer.String()}
 }
 
+// An ImageCandidate is an image candidate in a srcset attribute value, made up
+// of a TrustedResourceURL and an optional descriptor.
+type ImageCandidate struct {
+	URL TrustedResourceURL
+	// Descriptor is a width descriptor such as "100w", a pixel density
+	// descriptor such as "2x", or empty, which is equivalent to "1x".
+	Descriptor string
+}
+
+// descriptorPattern matches the width and pixel density descriptors of image
+// candidates.
+//
+// https://html.spec.whatwg.org/multipage/images.html#srcset-attributes
+var descriptorPattern = regexp.MustCompile(`^(?:[0-9]+w|(?:[0-9]+(?:\.[0-9]+)?|\.[0-9]+)(?:[eE][+-]?[0-9]+)?x)$`)
+
+// URLSetFromTrustedResourceURLs returns a srcset attribute value made up of the
+// given image candidates. Whitespace in the URLs, and commas at their start or
+// end, are percent-encoded so that each URL is parsed as a single image
+// candidate URL.
+//
+// It returns an error if there are no candidates, if a URL is empty, if a
+// descriptor is not a positive width or pixel density descriptor, or if two
+// candidates have the same descriptor, or width and pixel density descriptors
+// are mixed.
+func URLSetFromTrustedResourceURLs(candidates ...ImageCandidate) (URLSet, error) {
+	if len(candidates) == 0 {
+		return URLSet{}, fmt.Errorf("no image candidates")
+	}
+	var buffer bytes.Buffer
+	seen := make(map[string]bool, len(candidates))
+	var kind byte
+	for _, c := range candidates {
+		url := c.URL.String()
+		if url == "" {
+			return URLSet{}, fmt.Errorf("image candidate with descriptor %q has an empty URL", c.Descriptor)
+		}
+		descriptor := c.Descriptor
+		if descriptor == "" {
+			descriptor = "1x"
+		}
+		if !descriptorPattern.MatchString(descriptor) {
+			return URLSet{}, fmt.Errorf("image candidate %q has an invalid descriptor %q", url, c.Descriptor)
+		}
+		n := len(descriptor) - 1
+		v, err := strconv.ParseFloat(descriptor[:n], 64)
+		if err != nil || v <= 0 {
+			return URLSet{}, fmt.Errorf("image candidate %q has a non-positive descriptor %q", url, c.Descriptor)
+		}
+		if kind != 0 && descriptor[n] != kind {
+			return URLSet{}, fmt.Errorf("image candidates mix width and pixel density descriptors")
+		}
+		kind = descriptor[n]
+		// Compare descriptor values, so that "2x" and "2.0x" are duplicates.
+		value := strconv.FormatFloat(v, 'g', -1, 64)
+		if seen[value] {
+			return URLSet{}, fmt.Errorf("more than one image candidate has the descriptor %q", descriptor)
+		}
+		seen[value] = true
+		if buffer.Len() != 0 {
+			buffer.WriteString(" , ")
+		}
+		appendURLToSet(escapeASCIIWhitespace(url), &buffer)
+		if c.Descriptor != "" {
+			buffer.WriteByte(' ')
+			buffer.WriteString(c.Descriptor)
+		}
+	}
+	return URLSet{buffer.String()}, nil
+}
+
+// escapeASCIIWhitespace returns url with ASCII whitespace percent-encoded.
+func escapeASCIIWhitespace(url string) string {
+	if _, rest := consumeNotIn(url, asciiWhitespace); rest == "" {
+		return url
+	}
+	var b strings.Builder
+	for i := 0; i < len(url); i++ {
+		if asciiWhitespace[url[i]] {
+			fmt.Fprintf(&b, "%%%02x", url[i])
+		} else {
+			b.WriteByte(url[i])
+		}
+	}
+	return b.String()
+}
+
 // appendURLToSet appends a URL so that it does not start or end with a comma
 //
 // https://html.spec.whatwg.org/multipage/images.html#srcset-attributes
@@ -0,0 +1,99 @@
+// Copyright (c) 2017 The Go Authors. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package safehtml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestURLSetFromTrustedResourceURLs(t *testing.T) {
+	small := TrustedResourceURLFromConstant("https://example.com/small.png")
+	large := TrustedResourceURLFromConstant("https://example.com/large.png")
+	for _, test := range [...]struct {
+		desc       string
+		candidates []ImageCandidate
+		want       string
+	}{
+		{
+			"single candidate without descriptor",
+			[]ImageCandidate{{URL: small}},
+			"https://example.com/small.png",
+		},
+		{
+			"pixel density descriptors",
+			[]ImageCandidate{{URL: small, Descriptor: "1x"}, {URL: large, Descriptor: "2x"}},
+			"https://example.com/small.png 1x , https://example.com/large.png 2x",
+		},
+		{
+			"default descriptor and fractional pixel density",
+			[]ImageCandidate{{URL: small}, {URL: large, Descriptor: "1.5x"}},
+			"https://example.com/small.png , https://example.com/large.png 1.5x",
+		},
+		{
+			"width descriptors",
+			[]ImageCandidate{{URL: small, Descriptor: "320w"}, {URL: large, Descriptor: "1280w"}},
+			"https://example.com/small.png 320w , https://example.com/large.png 1280w",
+		},
+		{
+			"whitespace and trailing comma in URL",
+			[]ImageCandidate{
+				{URL: TrustedResourceURLFromConstant("https://example.com/a b\tc.png"), Descriptor: "1x"},
+				{URL: TrustedResourceURLFromConstant("https://example.com/x,y,"), Descriptor: "2x"},
+			},
+			"https://example.com/a%20b%09c.png 1x , https://example.com/x,y%2c 2x",
+		},
+	} {
+		got, err := URLSetFromTrustedResourceURLs(test.candidates...)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.desc, err)
+			continue
+		}
+		if got.String() != test.want {
+			t.Errorf("%s: got %q, want %q", test.desc, got, test.want)
+		}
+		// The result must be preserved by URLSetSanitized.
+		if sanitized := URLSetSanitized(got.String()).String(); sanitized != test.want {
+			t.Errorf("%s: URLSetSanitized(%q) = %q, want unchanged", test.desc, got, sanitized)
+		}
+	}
+}
+
+func TestURLSetFromTrustedResourceURLsErrors(t *testing.T) {
+	small := TrustedResourceURLFromConstant("https://example.com/small.png")
+	large := TrustedResourceURLFromConstant("https://example.com/large.png")
+	for _, test := range [...]struct {
+		desc       string
+		candidates []ImageCandidate
+		want       string
+	}{
+		{"no candidates", nil, "no image candidates"},
+		{"empty URL", []ImageCandidate{{Descriptor: "1x"}}, "empty URL"},
+		{"malformed descriptor", []ImageCandidate{{URL: small, Descriptor: "2"}}, "invalid descriptor"},
+		{"unit typo", []ImageCandidate{{URL: small, Descriptor: "2X"}}, "invalid descriptor"},
+		{"attribute breakout", []ImageCandidate{{URL: small, Descriptor: `1x" onerror="alert(1)`}}, "invalid descriptor"},
+		{"extra candidate", []ImageCandidate{{URL: small, Descriptor: "1x, javascript:alert(1) 2x"}}, "invalid descriptor"},
+		{"negative", []ImageCandidate{{URL: small, Descriptor: "-1x"}}, "invalid descriptor"},
+		{"zero width", []ImageCandidate{{URL: small, Descriptor: "0w"}}, "non-positive descriptor"},
+		{"fractional width", []ImageCandidate{{URL: small, Descriptor: "1.5w"}}, "invalid descriptor"},
+		{"duplicate", []ImageCandidate{{URL: small, Descriptor: "2x"}, {URL: large, Descriptor: "2.0x"}}, "more than one"},
+		{"duplicate default", []ImageCandidate{{URL: small}, {URL: large, Descriptor: "1x"}}, "more than one"},
+		{"mixed", []ImageCandidate{{URL: small, Descriptor: "1x"}, {URL: large, Descriptor: "800w"}}, "mix width and pixel density"},
+	} {
+		got, err := URLSetFromTrustedResourceURLs(test.candidates...)
+		if err == nil {
+			t.Errorf("%s: got %q, want error", test.desc, got)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: error %q does not contain %q", test.desc, err, test.want)
+		}
+		if got.String() != "" {
+			t.Errorf("%s: got %q with error, want empty URLSet", test.desc, got)
+		}
+	}
+}